@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/service"
+	"github.com/pkg/errors"
+	"os"
+)
+
+// runCommand выполняет команду args[0] с флагами args[1:] вместо запуска сервера
+func runCommand(ctx context.Context, services *service.Service, args []string) error {
+	ctx = service.WithActor(ctx, "cli")
+	switch args[0] {
+	case "reconcile":
+		return reconcileCommand(ctx, services, args[1:])
+	case "verify":
+		return verifyCommand(ctx, services, args[1:])
+	default:
+		return errors.Errorf("unknown command %q, available: reconcile, verify", args[0])
+	}
+}
+
+// reconcileCommand сверяет балансы с журналом и пишет отчет в json. Завершается с ошибкой, если остались
+// неисправленные расхождения или несведенные операции
+func reconcileCommand(ctx context.Context, ledger service.Ledger, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "correct cached users.balance to the ledger")
+	output := flags.String("output", "-", "file for json report, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	reconciliation, err := ledger.Reconcile(ctx, *repair)
+	if err != nil {
+		return errors.Wrap(err, "filed to reconcile")
+	}
+	if *output == "-" {
+		err = pkg.WriteReconciliation(os.Stdout, reconciliation)
+	} else {
+		err = pkg.WriteReconciliationFile(*output, reconciliation)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !reconciliation.Consistent() {
+		return errors.Errorf("ledger is inconsistent: %d discrepancies, %d unbalanced transactions",
+			len(reconciliation.Discrepancies), len(reconciliation.UnbalancedTransactions))
+	}
+	return nil
+}
+
+// verifyCommand проверяет цепочку хэшей журнала аудита и пишет результат в json на stdout. Завершается с ошибкой,
+// если запись изменена или удалена
+func verifyCommand(ctx context.Context, audit service.Audit, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	lastHash := flags.String("last-hash", "", "last_hash of previous verify, to detect entries deleted from the end")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	verification, err := audit.VerifyAuditLog(ctx, *lastHash)
+	if err != nil {
+		return errors.Wrap(err, "filed to verify audit log")
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(verification); err != nil {
+		return err
+	}
+
+	if !verification.Valid {
+		return errors.Errorf("audit log is tampered: %d problems", len(verification.Problems))
+	}
+	return nil
+}
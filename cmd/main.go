@@ -2,20 +2,25 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"for_avito_tech_with_gin/config"
 	"for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/auth"
 	"for_avito_tech_with_gin/pkg/handler"
+	"for_avito_tech_with_gin/pkg/lifecycle"
+	"for_avito_tech_with_gin/pkg/ratelimit"
 	"for_avito_tech_with_gin/pkg/repository"
 	"for_avito_tech_with_gin/pkg/service"
-	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/ginS"
+	"for_avito_tech_with_gin/pkg/tlsconfig"
+	"for_avito_tech_with_gin/pkg/tracing"
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
 // @tittle Balance Service
@@ -23,7 +28,7 @@ import (
 // @description REST API сервис - тестовое задание для стажировки в AvitoTech
 
 // @host localhost:8080
-// @BasePath /api/v1/
+// @BasePath /api
 
 func main() {
 	if err := run(); err != nil {
@@ -32,62 +37,121 @@ func main() {
 }
 
 func run() error {
-	if err := config.Init(); err != nil {
+	configPath := flag.String("config", config.DefaultPath, "path to config file")
+	profile := flag.String("profile", os.Getenv(config.ProfileEnv), "config profile (dev, test, prod), overrides config values with config.<profile>.yaml")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [reconcile [-repair] [-output file] | verify [-last-hash hash]]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath, *profile)
+	if err != nil {
 		return err
 	}
-	if err := config.InitLogger(); err != nil {
+	closeLog, err := config.InitLogger(cfg.Log)
+	if err != nil {
 		return err
 	}
+	defer func() {
+		if err := closeLog(); err != nil {
+			logrus.Error(errors.Wrap(err, "filed to close log file"))
+		}
+	}()
 
-	// Update currencies quotes every 6 hours
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				logrus.Errorf("recovered: %v", err)
-			}
-		}()
-		var calculator pkg.CurrencyCalculator = &pkg.DefaultCurrencyCalculator{}
-		calculator.UpdateRates()
-		for range time.Tick(time.Hour * 6) {
-			calculator.UpdateRates()
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(cfg.Tracing)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize tracing")
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logrus.Error(errors.Wrap(err, "filed to shutdown tracing"))
 		}
 	}()
 
-	// Initialize database
-	postgres, err := repository.NewPostgresDB(config.GetPostgresConfig())
+	// Initialize database, wait for it up to db.connect_timeout
+	postgres, err := repository.NewPostgresDB(cfg.PostgresConfig())
 	if err != nil {
 		return errors.Wrap(err, "failed to initialize db")
 	}
 	defer postgres.Close()
 
-	repositories := repository.NewRepository(postgres)
-	services := service.NewService(repositories)
-	handlers := handler.NewHandler(services)
+	// Balance and history are read from the replica if db.replica_url is set
+	replica, err := repository.NewPostgresReplicaDB(cfg.PostgresConfig())
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize db replica")
+	}
+	if replica != nil {
+		defer replica.Close()
+	}
+
+	repositories := repository.NewRepository(postgres, replica, cfg.Balance.DefaultCreditLimit, cfg.RetryConfig())
+	services := service.NewService(repositories, cfg.DefaultLimits(), cfg.Balance.AllowFrozenCredits, cfg.Users.ImplicitCreation)
 
-	ginS.Use(gin.Logger())
-	ginS.Use(gin.Recovery())
+	// One-off commands run instead of the server
+	if flag.NArg() > 0 {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+		return runCommand(ctx, services, flag.Args())
+	}
 
-	srv := new(pkg.Server)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), cfg.RateLimits())
+	authorizer := auth.NewAuthorizer(cfg.Auth.Clients)
+	handlers := handler.NewHandler(services, limiter, authorizer)
 
-	go func() {
-		if err := srv.Run(config.GetAddress(), handlers.InitRouters()); err != nil {
-			logrus.Fatal(errors.Wrap(err, "filed to init server"))
+	// Initialize TLS, certificates are reloaded when files change
+	var certs *tlsconfig.Reloader
+	var tlsConfig *tls.Config
+	if cfg.TLS.Enabled {
+		certs, err = tlsconfig.NewReloader(cfg.TLS)
+		if err != nil {
+			return errors.Wrap(err, "failed to initialize tls")
 		}
-		//defer func() {
-		//	if err := recover(); err != nil {}
-		//}()
-		// обработка паник в middleware компоненте
-	}()
+		tlsConfig = certs.TLSConfig()
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
-	<-quit
+	// Update currencies quotes every currency.refresh_interval
+	currencyUpdater := pkg.NewCurrencyUpdater(&pkg.DefaultCurrencyCalculator{}, cfg.Currency.RefreshInterval)
 
-	defer func() {
-		if err := srv.Shutdown(context.Background()); err != nil {
-			logrus.Error(errors.Wrap(err, "filed to shutdown server"))
+	// Apply limits, log level and currency refresh interval on config change or SIGHUP
+	reloader := config.NewReloader(*configPath, *profile, cfg, func(c *config.Config) {
+		if err := config.SetLogLevel(c.Log); err != nil {
+			logrus.Error(err)
 		}
-	}()
+		services.SetDefaultLimits(c.DefaultLimits())
+		limiter.SetRoutes(c.RateLimits())
+		authorizer.SetRules(c.Auth.Clients)
+		if certs != nil {
+			if err := certs.Reload(); err != nil {
+				logrus.Error(err)
+			}
+		}
+		currencyUpdater.SetInterval(c.Currency.RefreshInterval)
+	})
+
+	srv := pkg.NewServer(cfg.Address(), handlers.InitRouters(cfg.RouterConfig()), tlsConfig, cfg.HTTPServerConfig())
+
+	// Start workers in order and stop them in reverse order on SIGTERM/SIGINT
+	manager := lifecycle.NewManager(cfg.ShutdownTimeout)
+	manager.Add(lifecycle.Component{Name: "currency updater", Run: currencyUpdater.Run})
+	manager.Add(lifecycle.Component{Name: "reconciler", Run: pkg.NewReconciler(services, cfg.ReconcilerConfig()).Run})
+	manager.Add(lifecycle.Component{Name: "config reloader", Run: func(ctx context.Context) error {
+		reloader.Watch(ctx)
+		<-ctx.Done()
+		return nil
+	}})
+	if certs != nil {
+		manager.Add(lifecycle.Component{Name: "certificate reloader", Run: certs.Run})
+	}
+	manager.Add(lifecycle.Component{Name: "http server", Run: func(ctx context.Context) error {
+		return srv.Run()
+	}, Stop: srv.Shutdown})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	return nil
+	return manager.Run(ctx)
 }
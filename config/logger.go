@@ -0,0 +1,144 @@
+package config
+
+import (
+	"for_avito_tech_with_gin/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+var (
+	formatterMu sync.Mutex
+	formatter   logrus.Formatter = &logrus.JSONFormatter{} // формат из log.format, выбирается только при запуске
+)
+
+// InitLogger настраивает logrus по секции log: формат, уровни, stdout и файл с ротацией.
+// Возвращает функцию, которая останавливает ротацию по времени и закрывает файл
+func InitLogger(c LogConfig) (func() error, error) {
+	var writers []io.Writer
+	if c.Stdout {
+		writers = append(writers, os.Stdout)
+	}
+
+	closeFile := func() error { return nil }
+	if c.File.Path != "" {
+		file := &lumberjack.Logger{
+			Filename:   c.File.Path,
+			MaxSize:    c.File.MaxSizeMB,
+			MaxAge:     c.File.MaxAgeDays,
+			MaxBackups: c.File.MaxBackups,
+			Compress:   c.File.Compress,
+		}
+		// каждый запуск начинается с нового файла, заодно ошибка открытия видна сразу, а не при первой записи
+		if err := file.Rotate(); err != nil {
+			return nil, errors.Wrap(err, "error opening file")
+		}
+		writers = append(writers, file)
+
+		stop := make(chan struct{})
+		if c.File.RotateEvery > 0 {
+			go rotateEvery(file, c.File.RotateEvery, stop)
+		}
+		closeFile = func() error {
+			close(stop)
+			return file.Close()
+		}
+	}
+	if len(writers) == 0 {
+		return nil, errors.New("log: enable stdout or set file.path")
+	}
+
+	formatterMu.Lock()
+	switch c.Format {
+	case LogFormatText:
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	default:
+		formatter = &logrus.JSONFormatter{}
+	}
+	formatterMu.Unlock()
+
+	if err := SetLogLevel(c); err != nil {
+		_ = closeFile()
+		return nil, err
+	}
+	logrus.SetOutput(io.MultiWriter(writers...))
+
+	return closeFile, nil
+}
+
+func rotateEvery(file *lumberjack.Logger, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := file.Rotate(); err != nil {
+				logrus.Error(errors.Wrap(err, "filed to rotate log file"))
+			}
+		}
+	}
+}
+
+// SetLogLevel меняет log.level и log.packages на лету, остальные настройки логов применяются только при запуске
+func SetLogLevel(c LogConfig) error {
+	level, err := logrus.ParseLevel(c.Level)
+	if err != nil {
+		return err
+	}
+	filter := &levelFilter{level: level, packages: make(map[string]logrus.Level, len(c.Packages))}
+	// logrus отбрасывает записи только по уровню логгера, поэтому он выставляется по самому подробному
+	// из уровней, а лишние записи пакетов отбрасывает levelFilter
+	max := level
+	for name, packageLevel := range c.Packages {
+		l, err := logrus.ParseLevel(packageLevel)
+		if err != nil {
+			return errors.Wrapf(err, "package %s", name)
+		}
+		filter.packages[name] = l
+		if l > max {
+			max = l
+		}
+	}
+
+	formatterMu.Lock()
+	filter.Formatter = formatter
+	formatterMu.Unlock()
+
+	logrus.SetFormatter(filter)
+	logrus.SetLevel(max)
+
+	return nil
+}
+
+// levelFilter записи с полем logging.PackageField пишутся, если они не ниже уровня пакета из log.packages,
+// остальные - если не ниже log.level
+type levelFilter struct {
+	logrus.Formatter
+	level    logrus.Level
+	packages map[string]logrus.Level
+}
+
+func (f *levelFilter) Format(entry *logrus.Entry) ([]byte, error) {
+	level := f.level
+	if name, ok := entry.Data[logging.PackageField].(string); ok {
+		if l, ok := f.packages[name]; ok {
+			level = l
+		}
+	}
+	if entry.Level > level {
+		return nil, nil
+	}
+
+	return f.Formatter.Format(entry)
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"for_avito_tech_with_gin/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLevelFilter_Format(t *testing.T) {
+	filter := &levelFilter{
+		Formatter: &logrus.TextFormatter{DisableTimestamp: true},
+		level:     logrus.InfoLevel,
+		packages:  map[string]logrus.Level{"service": logrus.DebugLevel, "currency": logrus.WarnLevel},
+	}
+
+	testData := []struct {
+		name     string
+		pkg      string
+		level    logrus.Level
+		expected bool
+	}{
+		{name: "Default Level", level: logrus.InfoLevel, expected: true},
+		{name: "Below Default Level", level: logrus.DebugLevel},
+		{name: "Package Level", pkg: "service", level: logrus.DebugLevel, expected: true},
+		{name: "Below Package Level", pkg: "currency", level: logrus.InfoLevel},
+		{name: "Package Without Level", pkg: "handler", level: logrus.DebugLevel},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry := logrus.NewEntry(logrus.New())
+			if testCase.pkg != "" {
+				entry = entry.WithField(logging.PackageField, testCase.pkg)
+			}
+			entry.Level = testCase.level
+			entry.Message = "lol kek cheburek."
+
+			out, err := filter.Format(entry)
+
+			// assert
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, bytes.Contains(out, []byte("lol kek cheburek.")))
+		})
+	}
+}
+
+func TestInitLogger(t *testing.T) {
+	std := logrus.StandardLogger()
+	out, level, formatter := std.Out, std.Level, std.Formatter
+	defer func() {
+		std.SetOutput(out)
+		std.SetLevel(level)
+		std.SetFormatter(formatter)
+	}()
+
+	path := filepath.Join(t.TempDir(), "logs", "balance.log")
+	closeLog, err := InitLogger(LogConfig{
+		Level:    "info",
+		Packages: map[string]string{"service": "debug"},
+		Format:   LogFormatText,
+		File:     LogFileConfig{Path: path, MaxSizeMB: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logrus.Debug("skipped")
+	logrus.WithField(logging.PackageField, "service").Debug("written")
+	assert.NoError(t, closeLog())
+
+	// assert
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `level=debug msg=written package=service`)
+	assert.NotContains(t, string(content), "skipped")
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/logging"
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// reloadable настройки, которые применяются без перезапуска, вместе со всеми вложенными ключами
+var reloadable = []string{"log.level", "log.packages", "limits", "rate_limit", "currency.refresh_interval", "auth"}
+
+// Reloader перечитывает конфиг при изменении файлов и по SIGHUP
+type Reloader struct {
+	path    string
+	profile string
+	apply   func(c *Config)
+
+	mu      sync.Mutex
+	running *Config // конфиг, с которым запущен сервис, с ним сравниваются настройки, которые требуют перезапуска
+}
+
+// NewReloader apply вызывается с каждым новым конфигом, который прошел проверку, и должен применить
+// настройки из reloadable
+func NewReloader(path string, profile string, running *Config, apply func(c *Config)) *Reloader {
+	return &Reloader{path: path, profile: profile, running: running, apply: apply}
+}
+
+// Reload перечитывает конфиг и применяет его. Если конфиг не прошел проверку, остаются прежние настройки.
+// Возвращает ключи изменившихся настроек, которые применятся только после перезапуска
+func (r *Reloader) Reload() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := Load(r.path, r.profile)
+	if err != nil {
+		return nil, err
+	}
+	r.apply(c)
+
+	return RestartRequired(r.running, c), nil
+}
+
+// Watch следит за файлами конфига и SIGHUP, пока не отменен ctx. Слежку viper за файлами остановить нельзя,
+// поэтому после отмены ctx изменения файлов игнорируются
+func (r *Reloader) Watch(ctx context.Context) {
+	files := []string{r.path}
+	if r.profile != "" {
+		files = append(files, profileFile(r.path, r.profile))
+	}
+	for _, file := range files {
+		v := viper.New()
+		v.SetConfigFile(file)
+		v.OnConfigChange(func(e fsnotify.Event) {
+			if ctx.Err() == nil {
+				r.reload("file " + e.Name)
+			}
+		})
+		v.WatchConfig()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				r.reload("SIGHUP")
+			}
+		}
+	}()
+}
+
+func (r *Reloader) reload(source string) {
+	log := logrus.WithFields(logrus.Fields{logging.PackageField: "config", "source": source})
+
+	restart, err := r.Reload()
+	if err != nil {
+		log.Error(errors.Wrap(err, "config is not reloaded"))
+		return
+	}
+	if len(restart) > 0 {
+		log.WithField("restart_required", restart).Warn("config reloaded, some changes will be applied after restart")
+		return
+	}
+	log.Info("config reloaded")
+}
+
+// RestartRequired ключи настроек, которые отличаются в old и new и не входят в reloadable
+func RestartRequired(old *Config, new *Config) []string {
+	var keys []string
+	diff(reflect.ValueOf(*old), reflect.ValueOf(*new), "", &keys)
+	return keys
+}
+
+func diff(old reflect.Value, new reflect.Value, prefix string, keys *[]string) {
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + tag
+		if isReloadable(key) {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			diff(old.Field(i), new.Field(i), key+".", keys)
+			continue
+		}
+		if !reflect.DeepEqual(old.Field(i).Interface(), new.Field(i).Interface()) {
+			*keys = append(*keys, key)
+		}
+	}
+}
+
+func isReloadable(key string) bool {
+	for _, r := range reloadable {
+		if key == r || strings.HasPrefix(key, r+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	testData := []struct {
+		name            string
+		config          string
+		expectedRestart []string
+		expectedDebit   float32
+		wantError       bool
+	}{
+		{
+			name:          "Reloadable",
+			config:        strings.Replace(testConfig, "daily_debit: 100", "daily_debit: 200\n  hourly_transfers: 3", 1) + "currency:\n  refresh_interval: \"1h\"\n",
+			expectedDebit: 200,
+		},
+		{
+			name:            "Restart Required",
+			config:          strings.Replace(testConfig, `port: "5433"`, `port: "5434"`, 1) + "host: \"0.0.0.0\"\n",
+			expectedRestart: []string{"host", "db.port"},
+			expectedDebit:   100,
+		},
+		{
+			name:      "Invalid",
+			config:    strings.Replace(testConfig, "daily_debit: 100", "daily_debit: -1", 1),
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// init deps
+			dir := t.TempDir()
+			path := writeFile(t, dir, "config.yaml", testConfig)
+			running, err := Load(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var applied *Config
+			reloader := NewReloader(path, "", running, func(c *Config) { applied = c })
+			writeFile(t, dir, filepath.Base(path), testCase.config)
+
+			restart, err := reloader.Reload()
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+				assert.Nil(t, applied)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedRestart, restart)
+			if assert.NotNil(t, applied) {
+				assert.Equal(t, testCase.expectedDebit, applied.Limits.DailyDebit)
+			}
+		})
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := Config{Host: "localhost", Port: "8000", Log: LogConfig{Level: "debug", Format: "json"}}
+	old.Currency.RefreshInterval = time.Hour
+	new := old
+	new.Log = LogConfig{Level: "info", Packages: map[string]string{"service": "debug"}, Format: "text"}
+	new.Limits.DailyDebit = 100
+	new.Currency.RefreshInterval = time.Minute
+	new.Tracing.Exporter = "otlp"
+	new.Profile = "prod"
+
+	// assert
+	assert.Equal(t, []string{"log.format", "tracing.exporter"}, RestartRequired(&old, &new))
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/x509"
+	"sync"
+)
+
+// Права клиентов API
+const (
+	PermissionRead  = "read"  // GET запросы: балансы, история, юзеры
+	PermissionWrite = "write" // начисления, списания, переводы, отмены, создание и удаление юзеров
+	PermissionAdmin = "admin" // /api/v1/admin: кредитные лимиты, лимиты, заморозка и закрытие счетов, журнал
+)
+
+// Permissions все известные права
+var Permissions = []string{PermissionRead, PermissionWrite, PermissionAdmin}
+
+// Rule права клиентов с сертификатом, у которого subject (например "CN=billing,O=Avito") или common name совпадает
+// с заданным. Если заданы оба - должны совпасть оба
+type Rule struct {
+	Subject     string   `mapstructure:"subject"`
+	CommonName  string   `mapstructure:"common_name"`
+	Permissions []string `mapstructure:"permissions"`
+}
+
+func (r Rule) matches(cert *x509.Certificate) bool {
+	if r.Subject == "" && r.CommonName == "" {
+		return false
+	}
+	return (r.Subject == "" || r.Subject == cert.Subject.String()) &&
+		(r.CommonName == "" || r.CommonName == cert.Subject.CommonName)
+}
+
+// Authorizer сопоставляет клиентские сертификаты с правами. Права клиента - объединение прав всех подошедших правил
+type Authorizer struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+func NewAuthorizer(rules []Rule) *Authorizer {
+	a := &Authorizer{}
+	a.SetRules(rules)
+	return a
+}
+
+// SetRules заменяет правила на лету
+func (a *Authorizer) SetRules(rules []Rule) {
+	a.mu.Lock()
+	a.rules = rules
+	a.mu.Unlock()
+}
+
+// Allowed есть ли у владельца сертификата право permission
+func (a *Authorizer) Allowed(cert *x509.Certificate, permission string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, rule := range a.rules {
+		if !rule.matches(cert) {
+			continue
+		}
+		for _, p := range rule.Permissions {
+			if p == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
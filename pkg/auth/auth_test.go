@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAuthorizer_Allowed(t *testing.T) {
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing", Organization: []string{"Avito"}}}
+	support := &x509.Certificate{Subject: pkix.Name{CommonName: "support"}}
+	authorizer := NewAuthorizer([]Rule{
+		{CommonName: "billing", Permissions: []string{PermissionRead, PermissionWrite}},
+		{Subject: "CN=billing,O=Avito", Permissions: []string{PermissionAdmin}},
+		{Subject: "CN=support,O=Avito", CommonName: "support", Permissions: []string{PermissionRead}},
+		{Permissions: []string{PermissionRead}},
+	})
+
+	testData := []struct {
+		name       string
+		cert       *x509.Certificate
+		permission string
+		expected   bool
+	}{
+		{name: "Common Name", cert: billing, permission: PermissionWrite, expected: true},
+		{name: "Subject", cert: billing, permission: PermissionAdmin, expected: true},
+		{name: "Both Must Match", cert: support, permission: PermissionRead},
+		{name: "Unknown Client", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "lol"}}, permission: PermissionRead},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// assert
+			assert.Equal(t, testCase.expected, authorizer.Allowed(testCase.cert, testCase.permission))
+		})
+	}
+}
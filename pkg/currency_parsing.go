@@ -1,11 +1,15 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
+	"for_avito_tech_with_gin/pkg/logging"
 	"for_avito_tech_with_gin/pkg/model"
 	"for_avito_tech_with_gin/pkg/service"
+	"for_avito_tech_with_gin/pkg/tracing"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"net/http"
 	"reflect"
 )
@@ -19,6 +23,9 @@ type CurrencyCalculator interface {
 
 var list model.CurrencyList
 
+// currencyLog уровень задается в log.packages.currency
+var currencyLog = logrus.WithField(logging.PackageField, "currency")
+
 type DefaultCurrencyCalculator struct{}
 
 func (r *DefaultCurrencyCalculator) UpdateRates() {
@@ -26,7 +33,7 @@ func (r *DefaultCurrencyCalculator) UpdateRates() {
 }
 
 func (r *DefaultCurrencyCalculator) ConvertRubTo(currency string, sum float32) (float64, error) {
-	logrus.Debugf("ConvertRubTo invoke, currency = %s, sum = %f", currency, sum)
+	currencyLog.Debugf("ConvertRubTo invoke, currency = %s, sum = %f", currency, sum)
 	nominal, ok1 := list.List[currency]["Nominal"]
 	value, ok2 := list.List[currency]["Value"]
 	if !ok1 || !ok2 {
@@ -35,29 +42,43 @@ func (r *DefaultCurrencyCalculator) ConvertRubTo(currency string, sum float32) (
 
 	v, err := getFloat(value)
 	if err != nil {
-		logrus.Error(err)
+		currencyLog.Error(err)
 		return 0, &service.InternalServerError{}
 	}
 	n, err := getFloat(nominal)
 	if err != nil {
-		logrus.Error(err)
+		currencyLog.Error(err)
 		return 0, &service.InternalServerError{}
 	}
-	logrus.Debugf("nominal = %f, value = %f", nominal, value)
+	currencyLog.Debugf("nominal = %f, value = %f", nominal, value)
 
 	return float64(sum) / v * n, nil
 }
 
+// currencyClient http клиент для котировок, передает trace context и пишет спан на каждый запрос
+var currencyClient = &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+
 func updateCurrencyJson() {
-	logrus.Debugf("updateCurrencyJson invoke")
-	resp, err := http.Get("https://www.cbr-xml-daily.ru/daily_json.js")
+	currencyLog.Debugf("updateCurrencyJson invoke")
+	// обновление котировок идет по таймеру, а не из запроса, поэтому это корневой спан
+	ctx, span := tracing.Start(context.Background(), "CurrencyCalculator.UpdateRates")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.cbr-xml-daily.ru/daily_json.js", nil)
+	if err != nil {
+		currencyLog.Error(err)
+		return
+	}
+	resp, err := currencyClient.Do(req)
 	if err != nil {
-		logrus.Error(err)
+		currencyLog.Error(err)
+		return
 	}
+	defer resp.Body.Close()
 
 	err = json.NewDecoder(resp.Body).Decode(&list)
 	if err != nil {
-		logrus.Error(err)
+		currencyLog.Error(err)
 	}
 }
 
@@ -0,0 +1,59 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CurrencyUpdater обновляет котировки при запуске и дальше раз в interval. Интервал можно менять на лету
+type CurrencyUpdater struct {
+	calculator CurrencyCalculator
+
+	mu       sync.Mutex
+	interval time.Duration
+	changed  chan struct{}
+}
+
+func NewCurrencyUpdater(calculator CurrencyCalculator, interval time.Duration) *CurrencyUpdater {
+	return &CurrencyUpdater{calculator: calculator, interval: interval, changed: make(chan struct{}, 1)}
+}
+
+// SetInterval новый интервал отсчитывается с момента изменения
+func (u *CurrencyUpdater) SetInterval(interval time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if interval == u.interval {
+		return
+	}
+	u.interval = interval
+
+	select {
+	case u.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (u *CurrencyUpdater) getInterval() time.Duration {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.interval
+}
+
+// Run блокируется, пока не отменен ctx
+func (u *CurrencyUpdater) Run(ctx context.Context) error {
+	u.calculator.UpdateRates()
+
+	ticker := time.NewTicker(u.getInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-u.changed:
+			ticker.Reset(u.getInterval())
+		case <-ticker.C:
+			u.calculator.UpdateRates()
+		}
+	}
+}
@@ -2,174 +2,669 @@ package handler
 
 import (
 	avito_tech "for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/model"
 	"for_avito_tech_with_gin/pkg/service"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-// @Summary Add Funds
-// @Description add funds (sum) for user (id)
+const defaultDeleteReason = "deleted via api"
+
+// @Summary Create User
+// @Description create user (id) with zero balance, optional external_ref is user id in external system
 // @Accept json
 // @Produce json
 // @Param input body map[string]interface{} true "input"
+// @Success 201 {object} model.User
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/users [post]
+func (h *Handler) createUserHandler(ctx *gin.Context) {
+	s := &struct {
+		UserId      jsonUserId `json:"id" binding:"required,userid"`
+		ExternalRef string     `json:"external_ref" binding:"max=64"`
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.UserId)) {
+		return
+	}
+
+	user, err := h.services.CreateUser(ctx.Request.Context(), string(s.UserId), s.ExternalRef)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, user)
+}
+
+// @Summary Get User
+// @Description get user (id) with balance, status and metadata
+// @Produce json
+// @Param id path string true "user id"
+// @Success 200 {object} model.User
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/users/{id} [get]
+func (h *Handler) getUserHandler(ctx *gin.Context) {
+	userId := ctx.Param("id")
+	if !validateParam(ctx, "id", userId, "userid") {
+		return
+	}
+	if !h.allowUsers(ctx, userId) {
+		return
+	}
+
+	user, err := h.services.GetUser(ctx.Request.Context(), userId)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// @Summary Delete User
+// @Description close user (id), user must have zero balance, history is kept. Optional reason is saved to status history. Requires admin permission with mTLS
+// @Accept json
+// @Produce json
+// @Param id path string true "user id"
+// @Param input body map[string]interface{} false "input"
 // @Success 200 {integer} integer
 // @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
 // @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
 // @Failure default {object} errorResponse
-// @Router /add_funds [post]
-func (h *Handler) addFundsHandler(ctx *gin.Context) {
+// @Router /v1/users/{id} [delete]
+func (h *Handler) deleteUserHandler(ctx *gin.Context) {
+	// юзер не удаляется из базы, чтобы не терять историю операций - он закрывается
+	userId := ctx.Param("id")
+	if !validateParam(ctx, "id", userId, "userid") {
+		return
+	}
+
 	s := &struct {
-		UserId int     `json:"id" binding:"required"`
-		Sum    float32 `json:"sum" binding:"required"`
+		Reason string `json:"reason" binding:"max=255"`
 	}{}
-	if err := ctx.BindJSON(s); err != nil {
-		logrus.Error(err)
-		newErrorResponse(ctx, http.StatusBadRequest, "invalid body.")
+	if !bindOptionalJSON(ctx, s) {
+		return
+	}
+	if s.Reason == "" {
+		s.Reason = defaultDeleteReason
+	}
+	if !h.allowUsers(ctx, userId) {
 		return
 	}
 
-	if err := h.services.AddFunds(s.UserId, s.Sum); err != nil {
+	if err := h.services.SetStatus(ctx.Request.Context(), userId, model.UserStatusClosed, s.Reason); err != nil {
 		responseError, ok := err.(service.ResponseError)
 		if !ok {
 			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
 			return
 		}
-		newErrorResponse(ctx, responseError.StatusCode(), responseError.Error())
+		newServiceErrorResponse(ctx, responseError)
 		return
 	}
 
 	ctx.Status(http.StatusOK)
 }
 
+// @Summary Add Funds
+// @Description add funds (sum) for user (id), optional order_id, service_id, comment and source are saved to history
+// @Accept json
+// @Produce json
+// @Param input body map[string]interface{} true "input"
+// @Success 200 {object} model.OperationResult
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/add_funds [post]
+func (h *Handler) addFundsHandler(ctx *gin.Context) {
+	s := &struct {
+		UserId jsonUserId `json:"id" binding:"required,userid"`
+		Sum    float32    `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.UserId)) {
+		return
+	}
+
+	result, err := h.services.AddFunds(ctx.Request.Context(), string(s.UserId), s.Sum, s.TransactionInfo)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
 // @Summary Write Off Funds
-// @Description writes off funds (sum) for user (id)
+// @Description writes off funds (sum) for user (id), optional order_id, service_id, comment and source are saved to history
 // @Accept json
 // @Produce json
 // @Param input body map[string]interface{} true "input"
-// @Success 200 {integer} integer
+// @Success 200 {object} model.OperationResult
 // @Failure 400 {object} errorResponse
 // @Failure 404 {object} errorResponse
+// @Failure 403 {object} errorResponse
 // @Failure 412 {object} errorResponse
+// @Failure 422 {object} errorResponse
+// @Failure 429 {object} errorResponse
 // @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
 // @Failure default {object} errorResponse
-// @Router /write_off_funds [post]
+// @Router /v1/write_off_funds [post]
 func (h *Handler) writeOffFundsHandler(ctx *gin.Context) {
 	s := &struct {
-		UserId int     `json:"id" binding:"required"`
-		Sum    float32 `json:"sum" binding:"required"`
+		UserId jsonUserId `json:"id" binding:"required,userid"`
+		Sum    float32    `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
 	}{}
-	if err := ctx.BindJSON(s); err != nil {
-		logrus.Error(err)
-		newErrorResponse(ctx, http.StatusBadRequest, "invalid body.")
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.UserId)) {
 		return
 	}
 
-	if err := h.services.WriteOffFunds(s.UserId, s.Sum); err != nil {
+	result, err := h.services.WriteOffFunds(ctx.Request.Context(), string(s.UserId), s.Sum, s.TransactionInfo)
+	if err != nil {
 		responseError, ok := err.(service.ResponseError)
 		if !ok {
 			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
 			return
 		}
-		newErrorResponse(ctx, responseError.StatusCode(), responseError.Error())
+		newServiceErrorResponse(ctx, responseError)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, result)
 }
 
 // @Summary Funds Transfer
-// @Description transfer funds (sum) from user (sender_id) to user (receiver_id)
+// @Description transfer funds (sum) from user (sender_id) to user (receiver_id), optional order_id, service_id, comment and source are saved to history
 // @Accept json
 // @Produce json
 // @Param input body map[string]interface{} true "input"
 // @Param currency path string false "balance will convert from RUB to currency"
-// @Success 200 {integer} integer
+// @Success 200 {object} model.OperationResult
 // @Failure 400 {object} errorResponse
 // @Failure 404 {object} errorResponse
+// @Failure 403 {object} errorResponse
 // @Failure 412 {object} errorResponse
+// @Failure 422 {object} errorResponse
+// @Failure 429 {object} errorResponse
 // @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
 // @Failure default {object} errorResponse
-// @Router /funds_transfer [post]
+// @Router /v1/funds_transfer [post]
 func (h *Handler) fundsTransferHandler(ctx *gin.Context) {
 	s := &struct {
-		SenderId   int     `json:"sender_id" binding:"required"`
-		ReceiverId int     `json:"receiver_id" binding:"required"`
-		Sum        float32 `json:"sum" binding:"required"`
+		SenderId   jsonUserId `json:"sender_id" binding:"required,userid"`
+		ReceiverId jsonUserId `json:"receiver_id" binding:"required,userid"`
+		Sum        float32    `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
 	}{}
-	if err := ctx.BindJSON(s); err != nil {
-		logrus.Error(err)
-		newErrorResponse(ctx, http.StatusBadRequest, "invalid body.")
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.SenderId), string(s.ReceiverId)) {
 		return
 	}
 
-	if err := h.services.FundsTransfer(s.SenderId, s.ReceiverId, s.Sum); err != nil {
+	result, err := h.services.FundsTransfer(ctx.Request.Context(), string(s.SenderId), string(s.ReceiverId), s.Sum, s.TransactionInfo)
+	if err != nil {
 		responseError, ok := err.(service.ResponseError)
 		if !ok {
 			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
 			return
 		}
-		newErrorResponse(ctx, responseError.StatusCode(), responseError.Error())
+		newServiceErrorResponse(ctx, responseError)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, result)
 }
 
 // @Summary Get Balance
-// @Description get user balance for user (id)
+// @Description get user balance, credit limit and available to spend sum for user (id)
 // @Accept json
 // @Produce json
 // @Param input body map[string]interface{} true "input"
-// @Success 200 {object} map[string]interface{}
+// @Param currency query string false "balance will convert from RUB to currency"
+// @Success 200 {object} model.Balance
 // @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
 // @Failure 404 {object} errorResponse
+// @Failure 429 {object} errorResponse
 // @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
 // @Failure default {object} errorResponse
-// @Router /get_balance [get]
+// @Router /v1/get_balance [get]
 func (h *Handler) getBalanceHandler(calculator avito_tech.CurrencyCalculator) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		s := &struct {
-			UserId int `json:"id" binding:"required"`
+			UserId jsonUserId `json:"id" binding:"required,userid"`
 		}{}
-		if err := ctx.BindJSON(s); err != nil {
-			logrus.Error(err)
-			newErrorResponse(ctx, http.StatusBadRequest, "invalid body.")
+		if !bindJSON(ctx, s) {
+			return
+		}
+		if !validateParam(ctx, "currency", ctx.Query("currency"), "omitempty,currency") {
+			return
+		}
+		if !h.allowUsers(ctx, string(s.UserId)) {
 			return
 		}
 
-		balance, err := h.services.GetBalance(s.UserId)
+		balance, err := h.services.GetBalance(ctx.Request.Context(), string(s.UserId))
 		if err != nil {
 			responseError, ok := err.(service.ResponseError)
 			if !ok {
 				newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
 				return
 			}
-			newErrorResponse(ctx, responseError.StatusCode(), responseError.Error())
-			return
-		}
-
-		currency := ctx.Query("currency")
-		if currency == "" {
-			ctx.JSON(http.StatusOK, map[string]interface{}{
-				"balance": balance,
-			})
-		} else {
-			if tt, err := calculator.ConvertRubTo(currency, balance); err != nil {
-				responseError, ok := err.(service.ResponseError)
-				if !ok {
-					newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
-					return
-				}
-				newErrorResponse(ctx, responseError.StatusCode(), responseError.Error())
+			newServiceErrorResponse(ctx, responseError)
+			return
+		}
+
+		writeBalance(ctx, calculator, balance)
+	}
+}
+
+// @Summary Get History
+// @Description get operations history for user (id), newest first
+// @Accept json
+// @Produce json
+// @Param input body map[string]interface{} true "input"
+// @Success 200 {array} model.Transaction
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/get_history [get]
+func (h *Handler) getHistoryHandler(ctx *gin.Context) {
+	s := &struct {
+		UserId jsonUserId `json:"id" binding:"required,userid"`
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.UserId)) {
+		return
+	}
+
+	transactions, err := h.services.GetHistory(ctx.Request.Context(), string(s.UserId))
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transactions)
+}
+
+// @Summary Reverse Transaction
+// @Description reverse transaction (id) fully or partially (sum), reversal is saved as a new transaction linked to the original one
+// @Description money goes back from receiver to sender, receiver balance can become negative only with allow_negative
+// @Accept json
+// @Produce json
+// @Param id path int true "transaction id"
+// @Param input body map[string]interface{} false "input"
+// @Success 200 {object} model.Transaction
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/transactions/{id}/reverse [post]
+func (h *Handler) reverseTransactionHandler(ctx *gin.Context) {
+	transactionId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil || transactionId <= 0 {
+		logger(ctx.Request.Context()).Errorf("invalid transaction id %q", ctx.Param("id"))
+		newValidationErrorResponse(ctx, "invalid transaction id.", []fieldError{{Field: "id", Message: "must be a positive integer."}})
+		return
+	}
+
+	// тело необязательное: без него операция возвращается полностью
+	s := &struct {
+		Sum           float32 `json:"sum" binding:"gte=0,decimals=2"`
+		AllowNegative bool    `json:"allow_negative"`
+		model.TransactionInfo
+	}{}
+	if !bindOptionalJSON(ctx, s) {
+		return
+	}
+
+	reversal, err := h.services.ReverseTransaction(ctx.Request.Context(), transactionId, s.Sum, s.AllowNegative, s.TransactionInfo)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, reversal)
+}
+
+// @Summary Set Credit Limit
+// @Description set credit limit for user (id), user can spend until balance is not lower than -credit_limit
+// @Description null credit_limit resets it to default limit from config
+// @Accept json
+// @Produce json
+// @Param input body map[string]interface{} true "input"
+// @Success 200 {integer} integer
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/admin/set_credit_limit [post]
+func (h *Handler) setCreditLimitHandler(ctx *gin.Context) {
+	s := &struct {
+		UserId      jsonUserId `json:"id" binding:"required,userid"`
+		CreditLimit *float32   `json:"credit_limit" binding:"omitempty,gte=0,decimals=2"`
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.UserId)) {
+		return
+	}
+
+	if err := h.services.SetCreditLimit(ctx.Request.Context(), string(s.UserId), s.CreditLimit); err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// @Summary Set Limits
+// @Description set user (id) own limits on write offs and outgoing transfers, null limits are taken from config
+// @Accept json
+// @Produce json
+// @Param input body map[string]interface{} true "input"
+// @Success 200 {integer} integer
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/admin/set_limits [post]
+func (h *Handler) setLimitsHandler(ctx *gin.Context) {
+	s := &struct {
+		UserId jsonUserId `json:"id" binding:"required,userid"`
+		model.Limits
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.UserId)) {
+		return
+	}
+
+	if err := h.services.SetLimits(ctx.Request.Context(), string(s.UserId), s.Limits); err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// @Summary Set User Status
+// @Description freeze, unfreeze or close user (id), reason is required and saved to status history
+// @Description frozen user can't be debited or send transfers, closed user can't do anything and can't be reopened
+// @Accept json
+// @Produce json
+// @Param input body map[string]interface{} true "input"
+// @Success 200 {integer} integer
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 409 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/admin/freeze [post]
+// @Router /v1/admin/unfreeze [post]
+// @Router /v1/admin/close [post]
+func (h *Handler) setStatusHandler(status string) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		s := &struct {
+			UserId jsonUserId `json:"id" binding:"required,userid"`
+			Reason string     `json:"reason" binding:"max=255"`
+		}{}
+		if !bindJSON(ctx, s) {
+			return
+		}
+		if !h.allowUsers(ctx, string(s.UserId)) {
+			return
+		}
+
+		if err := h.services.SetStatus(ctx.Request.Context(), string(s.UserId), status, s.Reason); err != nil {
+			responseError, ok := err.(service.ResponseError)
+			if !ok {
+				newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+				return
+			}
+			newServiceErrorResponse(ctx, responseError)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+	}
+}
+
+// @Summary Get Ledger
+// @Description get balances of ledger accounts (user wallets, cash_in, revenue, fees) computed from postings
+// @Description and check that postings of every transaction and of the whole ledger sum to zero
+// @Produce json
+// @Success 200 {object} model.Ledger
+// @Failure 403 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/admin/ledger [get]
+func (h *Handler) getLedgerHandler(ctx *gin.Context) {
+	ledger, err := h.services.GetLedger(ctx.Request.Context())
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ledger)
+}
+
+// @Summary Get Audit Log
+// @Description get entries of the append-only audit log (balance changes and admin actions) ordered by id,
+// @Description filtered by actor, user_id and created_at range [from, to), next page starts after after_id
+// @Produce json
+// @Param actor query string false "actor, e.g. cert:CN=billing or ip:192.0.2.1"
+// @Param user_id query string false "user id"
+// @Param from query string false "RFC3339 time, inclusive"
+// @Param to query string false "RFC3339 time, exclusive"
+// @Param after_id query int false "return entries with id greater than after_id"
+// @Param limit query int false "1-1000, default 100"
+// @Success 200 {array} model.AuditEntry
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v1/admin/audit [get]
+func (h *Handler) getAuditLogHandler(ctx *gin.Context) {
+	filter, ok := auditFilter(ctx)
+	if !ok {
+		return
+	}
+
+	entries, err := h.services.GetAuditLog(ctx.Request.Context(), filter)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// auditFilter разбирает фильтр журнала аудита из query, если параметр невалиден - отвечает 400 и возвращает false
+func auditFilter(ctx *gin.Context) (model.AuditFilter, bool) {
+	filter := model.AuditFilter{Actor: ctx.Query("actor"), UserId: ctx.Query("user_id")}
+	if filter.UserId != "" && !validateParam(ctx, "user_id", filter.UserId, "userid") {
+		return filter, false
+	}
+
+	var errs []fieldError
+	for _, p := range []struct {
+		field string
+		value **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := ctx.Query(p.field)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs = append(errs, fieldError{Field: p.field, Message: "must be RFC3339 time."})
+			continue
+		}
+		t = t.UTC()
+		*p.value = &t
+	}
+	if value := ctx.Query("after_id"); value != "" {
+		afterId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			errs = append(errs, fieldError{Field: "after_id", Message: "must be an integer."})
+		}
+		filter.AfterId = afterId
+	}
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, fieldError{Field: "limit", Message: "must be an integer."})
+		}
+		filter.Limit = limit
+	}
+	if len(errs) > 0 {
+		newValidationErrorResponse(ctx, "invalid params.", errs)
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// writeBalance отдает баланс, если передан ?currency=<тикер> - переведенный из рублей в эту валюту
+func writeBalance(ctx *gin.Context, calculator avito_tech.CurrencyCalculator, balance *model.Balance) {
+	currency := ctx.Query("currency")
+	if currency == "" {
+		ctx.JSON(http.StatusOK, balance)
+		return
+	}
+
+	var converted [3]float64
+	for i, sum := range []float32{balance.Balance, balance.CreditLimit, balance.Available} {
+		var err error
+		if converted[i], err = calculator.ConvertRubTo(currency, sum); err != nil {
+			responseError, ok := err.(service.ResponseError)
+			if !ok {
+				newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
 				return
-			} else {
-				ctx.JSON(http.StatusOK, map[string]interface{}{
-					"balance": tt,
-				})
 			}
+			newServiceErrorResponse(ctx, responseError)
+			return
 		}
 	}
+	ctx.JSON(http.StatusOK, struct {
+		Balance     float64 `json:"balance"`
+		CreditLimit float64 `json:"credit_limit"`
+		Available   float64 `json:"available"`
+	}{converted[0], converted[1], converted[2]})
 }
 
 // TODO: довести до ума документацию
@@ -2,7 +2,9 @@ package handler
 
 import (
 	"bytes"
+	"encoding/json"
 	mock_pkg "for_avito_tech_with_gin/pkg/mocks"
+	"for_avito_tech_with_gin/pkg/model"
 	"for_avito_tech_with_gin/pkg/service"
 	mock_service "for_avito_tech_with_gin/pkg/service/mocks"
 	"github.com/gin-gonic/gin"
@@ -11,9 +13,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 type mockUserBehavior func(s *mock_service.MockUser)
+type mockTransactionBehavior func(s *mock_service.MockTransaction)
+type mockLedgerBehavior func(s *mock_service.MockLedger)
+type mockAuditBehavior func(s *mock_service.MockAudit)
 type mockCalculatorBehavior func(s *mock_pkg.MockCurrencyCalculator)
 
 type testSkillet struct {
@@ -27,37 +33,77 @@ type testSkillet struct {
 }
 
 func TestHandler_addFundsHandler(t *testing.T) {
+	userId, balance := "348", float32(2700)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	result := &model.OperationResult{
+		Transaction: &model.Transaction{Id: 17, Type: model.TransactionAddFunds, ReceiverId: &userId, Sum: 2700, CreatedAt: createdAt},
+		Balance:     &balance,
+	}
+	resultBody := `{"transaction":{"id":17,"type":"add_funds","receiver_id":"348","sum":2700,"created_at":"2022-01-25T10:30:00Z"},` +
+		`"balance":2700}`
+
 	testData := []testSkillet{
 		{
 			name:      "OK",
 			inputBody: `{"id":348, "sum": 2700}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().AddFunds(348, float32(2700)).Return(nil)
+				s.EXPECT().AddFunds(gomock.Any(), "348", float32(2700), model.TransactionInfo{}).Return(result, nil)
 			},
 			expectedStatusCode:  http.StatusOK,
-			expectedRequestBody: "",
+			expectedRequestBody: resultBody,
+		},
+		{
+			name:      "OK With Info",
+			inputBody: `{"id":348, "sum": 2700, "order_id": "o-1", "service_id": "s-2", "comment": "за доставку", "source": "web"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), "348", float32(2700), model.TransactionInfo{
+					OrderId: "o-1", ServiceId: "s-2", Comment: "за доставку", Source: "web"}).Return(result, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: resultBody,
+		},
+		{
+			name:      "OK String Id",
+			inputBody: `{"id":"6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13", "sum": 500}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13", float32(500), model.TransactionInfo{}).Return(result, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: resultBody,
+		},
+		{
+			name:                "Fractional Id",
+			inputBody:           `{"id":1.5, "sum": 500}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"body","message":"user id must be a string or a positive integer."}]}`,
+		},
+		{
+			name:                "Empty Id",
+			inputBody:           `{"id":"", "sum": 500}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
 		},
 		{
 			name:                "Invalid Body",
 			inputBody:           `{"id":348}`,
 			mockUserBehavior:    func(s *mock_service.MockUser) {},
 			expectedStatusCode:  http.StatusBadRequest,
-			expectedRequestBody: `{"message":"invalid body."}`,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"is required."}]}`,
 		},
 		{
-			name:      "Negative Sum",
-			inputBody: `{"id":34, "sum": -10}`,
-			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().AddFunds(34, float32(-10)).Return(&service.NegativeSum{})
-			},
+			name:                "Negative Sum",
+			inputBody:           `{"id":34, "sum": -10}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
 			expectedStatusCode:  http.StatusBadRequest,
-			expectedRequestBody: `{"message":"sum can't be negative or 0."}`,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"must be greater than 0."}]}`,
 		},
 		{
 			name:      "Internal Server Error",
 			inputBody: `{"id":14589, "sum": 10}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().AddFunds(14589, float32(10)).Return(&service.InternalServerError{})
+				s.EXPECT().AddFunds(gomock.Any(), "14589", float32(10), model.TransactionInfo{}).Return(nil, &service.InternalServerError{})
 			},
 			expectedStatusCode:  http.StatusInternalServerError,
 			expectedRequestBody: `{"message":"internal server error."}`,
@@ -75,7 +121,7 @@ func TestHandler_addFundsHandler(t *testing.T) {
 			testCase.mockUserBehavior(servi)
 
 			services := &service.Service{User: servi}
-			handler := NewHandler(services)
+			handler := NewHandler(services, nil, nil)
 
 			// test server
 			r := gin.New()
@@ -96,37 +142,44 @@ func TestHandler_addFundsHandler(t *testing.T) {
 }
 
 func TestHandler_writeOffFundsHandler(t *testing.T) {
+	userId, balance := "348", float32(-200)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	result := &model.OperationResult{
+		Transaction: &model.Transaction{Id: 18, Type: model.TransactionWriteOffFunds, SenderId: &userId, Sum: 2700, CreatedAt: createdAt},
+		Balance:     &balance,
+	}
+	resultBody := `{"transaction":{"id":18,"type":"write_off_funds","sender_id":"348","sum":2700,"created_at":"2022-01-25T10:30:00Z"},` +
+		`"balance":-200}`
+
 	testData := []testSkillet{
 		{
 			name:      "OK",
 			inputBody: `{"id":348, "sum": 2700}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().WriteOffFunds(348, float32(2700)).Return(nil)
+				s.EXPECT().WriteOffFunds(gomock.Any(), "348", float32(2700), model.TransactionInfo{}).Return(result, nil)
 			},
 			expectedStatusCode:  http.StatusOK,
-			expectedRequestBody: "",
+			expectedRequestBody: resultBody,
 		},
 		{
 			name:                "Invalid Body",
 			inputBody:           `{"id":348}`,
 			mockUserBehavior:    func(s *mock_service.MockUser) {},
 			expectedStatusCode:  http.StatusBadRequest,
-			expectedRequestBody: `{"message":"invalid body."}`,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"is required."}]}`,
 		},
 		{
-			name:      "Negative Sum",
-			inputBody: `{"id":34, "sum": -10}`,
-			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().WriteOffFunds(34, float32(-10)).Return(&service.NegativeSum{})
-			},
+			name:                "Negative Sum",
+			inputBody:           `{"id":34, "sum": -10}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
 			expectedStatusCode:  http.StatusBadRequest,
-			expectedRequestBody: `{"message":"sum can't be negative or 0."}`,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"must be greater than 0."}]}`,
 		},
 		{
 			name:      "User Not Found",
 			inputBody: `{"id":91, "sum": 10}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().WriteOffFunds(91, float32(10)).Return(&service.UserNotFound{Id: 91})
+				s.EXPECT().WriteOffFunds(gomock.Any(), "91", float32(10), model.TransactionInfo{}).Return(nil, &service.UserNotFound{Id: "91"})
 			},
 			expectedStatusCode:  http.StatusNotFound,
 			expectedRequestBody: `{"message":"user 91 does not exist."}`,
@@ -135,16 +188,34 @@ func TestHandler_writeOffFundsHandler(t *testing.T) {
 			name:      "Insufficient Funds",
 			inputBody: `{"id":23, "sum": 10}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().WriteOffFunds(23, float32(10)).Return(&service.InsufficientFunds{Id: 23})
+				s.EXPECT().WriteOffFunds(gomock.Any(), "23", float32(10), model.TransactionInfo{}).Return(nil, &service.InsufficientFunds{Id: "23"})
 			},
 			expectedStatusCode:  http.StatusPreconditionFailed,
 			expectedRequestBody: `{"message":"user 23 has insufficient funds."}`,
 		},
+		{
+			name:      "Frozen",
+			inputBody: `{"id":23, "sum": 10}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().WriteOffFunds(gomock.Any(), "23", float32(10), model.TransactionInfo{}).Return(nil, &service.UserFrozen{Id: "23"})
+			},
+			expectedStatusCode:  http.StatusForbidden,
+			expectedRequestBody: `{"message":"user 23 is frozen."}`,
+		},
+		{
+			name:      "Limit Exceeded",
+			inputBody: `{"id":23, "sum": 10}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().WriteOffFunds(gomock.Any(), "23", float32(10), model.TransactionInfo{}).Return(nil, &service.LimitExceeded{Id: "23", Limit: "daily_debit"})
+			},
+			expectedStatusCode:  http.StatusUnprocessableEntity,
+			expectedRequestBody: `{"message":"user 23 exceeded daily_debit limit."}`,
+		},
 		{
 			name:      "Internal Server Error",
 			inputBody: `{"id":14589, "sum": 10}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().WriteOffFunds(14589, float32(10)).Return(&service.InternalServerError{})
+				s.EXPECT().WriteOffFunds(gomock.Any(), "14589", float32(10), model.TransactionInfo{}).Return(nil, &service.InternalServerError{})
 			},
 			expectedStatusCode:  http.StatusInternalServerError,
 			expectedRequestBody: `{"message":"internal server error."}`,
@@ -162,7 +233,7 @@ func TestHandler_writeOffFundsHandler(t *testing.T) {
 			testCase.mockUserBehavior(servi)
 
 			services := &service.Service{User: servi}
-			handler := NewHandler(services)
+			handler := NewHandler(services, nil, nil)
 
 			// test server
 			r := gin.New()
@@ -183,68 +254,97 @@ func TestHandler_writeOffFundsHandler(t *testing.T) {
 }
 
 func TestHandler_fundsTransferHandler(t *testing.T) {
+	senderId, receiverId := "348", "4389"
+	senderBalance, receiverBalance := float32(300), float32(0)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	result := &model.OperationResult{
+		Transaction: &model.Transaction{Id: 19, Type: model.TransactionFundsTransfer, SenderId: &senderId, ReceiverId: &receiverId,
+			Sum: 2700, CreatedAt: createdAt},
+		SenderBalance:   &senderBalance,
+		ReceiverBalance: &receiverBalance,
+	}
+	resultBody := `{"transaction":{"id":19,"type":"funds_transfer","sender_id":"348","receiver_id":"4389","sum":2700,` +
+		`"created_at":"2022-01-25T10:30:00Z"},"sender_balance":300,"receiver_balance":0}`
+
 	testData := []testSkillet{
 		{
 			name:      "OK",
-			inputBody: `{"sender_id":348, "receiver_id": 4389, "sum": 2700}`,
+			inputBody: `{"sender_id":"348", "receiver_id": 4389, "sum": 2700}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().FundsTransfer(348, 4389, float32(2700)).Return(nil)
+				s.EXPECT().FundsTransfer(gomock.Any(), "348", "4389", float32(2700), model.TransactionInfo{}).Return(result, nil)
 			},
 			expectedStatusCode:  http.StatusOK,
-			expectedRequestBody: "",
+			expectedRequestBody: resultBody,
 		},
 		{
 			name:                "Invalid Body",
-			inputBody:           `{"sender_id":348, "receiver_id": 4389}`,
+			inputBody:           `{"sender_id":"348", "receiver_id": 4389}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"is required."}]}`,
+		},
+		{
+			name:                "Negative Sum",
+			inputBody:           `{"sender_id":"34", "receiver_id": 89, "sum": -10}`,
 			mockUserBehavior:    func(s *mock_service.MockUser) {},
 			expectedStatusCode:  http.StatusBadRequest,
-			expectedRequestBody: `{"message":"invalid body."}`,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"must be greater than 0."}]}`,
 		},
 		{
-			name:      "Negative Sum",
-			inputBody: `{"sender_id":34, "receiver_id": 89, "sum": -10}`,
+			name:      "Wrong Info Param",
+			inputBody: `{"sender_id":"34", "receiver_id": 89, "sum": 10, "order_id": "#1"}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().FundsTransfer(34, 89, float32(-10)).Return(&service.NegativeSum{})
+				s.EXPECT().FundsTransfer(gomock.Any(), "34", "89", float32(10), model.TransactionInfo{OrderId: "#1"}).
+					Return(nil, &service.WrongParam{Param: "order_id"})
 			},
-			expectedStatusCode:  http.StatusBadRequest,
-			expectedRequestBody: `{"message":"sum can't be negative or 0."}`,
+			expectedStatusCode:  http.StatusPreconditionFailed,
+			expectedRequestBody: `{"message":"wrong order_id param."}`,
 		},
 		{
 			name:      "Equal Sender And Receiver",
-			inputBody: `{"sender_id":34, "receiver_id": 34, "sum": 1000}`,
+			inputBody: `{"sender_id":"34", "receiver_id": 34, "sum": 1000}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().FundsTransfer(34, 34, float32(1000)).Return(&service.SameId{})
+				s.EXPECT().FundsTransfer(gomock.Any(), "34", "34", float32(1000), model.TransactionInfo{}).Return(nil, &service.SameId{})
 			},
 			expectedStatusCode:  http.StatusBadRequest,
 			expectedRequestBody: `{"message":"user cannot send money to himself."}`,
 		},
 		{
 			name:      "User Not Found",
-			inputBody: `{"sender_id":91, "receiver_id": 12, "sum": 599}`,
+			inputBody: `{"sender_id":"91", "receiver_id": 12, "sum": 599}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().FundsTransfer(91, 12, float32(599)).Return(&service.UserNotFound{Id: 91})
+				s.EXPECT().FundsTransfer(gomock.Any(), "91", "12", float32(599), model.TransactionInfo{}).Return(nil, &service.UserNotFound{Id: "91"})
 			},
 			expectedStatusCode:  http.StatusNotFound,
 			expectedRequestBody: `{"message":"user 91 does not exist."}`,
 		},
 		{
 			name:      "Insufficient Funds",
-			inputBody: `{"sender_id":23, "receiver_id": 24, "sum": 1000}`,
+			inputBody: `{"sender_id":"23", "receiver_id": 24, "sum": 1000}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().FundsTransfer(23, 24, float32(1000)).Return(&service.InsufficientFunds{Id: 23})
+				s.EXPECT().FundsTransfer(gomock.Any(), "23", "24", float32(1000), model.TransactionInfo{}).Return(nil, &service.InsufficientFunds{Id: "23"})
 			},
 			expectedStatusCode:  http.StatusPreconditionFailed,
 			expectedRequestBody: `{"message":"user 23 has insufficient funds."}`,
 		},
 		{
 			name:      "Internal Server Error",
-			inputBody: `{"sender_id":14589, "receiver_id": 4389, "sum": 3500}`,
+			inputBody: `{"sender_id":"14589", "receiver_id": 4389, "sum": 3500}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().FundsTransfer(14589, 4389, float32(3500)).Return(&service.InternalServerError{})
+				s.EXPECT().FundsTransfer(gomock.Any(), "14589", "4389", float32(3500), model.TransactionInfo{}).Return(nil, &service.InternalServerError{})
 			},
 			expectedStatusCode:  http.StatusInternalServerError,
 			expectedRequestBody: `{"message":"internal server error."}`,
 		},
+		{
+			name:      "Concurrent Update",
+			inputBody: `{"sender_id":"14589", "receiver_id": 4389, "sum": 3500}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), "14589", "4389", float32(3500), model.TransactionInfo{}).Return(nil, &service.ConcurrentUpdate{})
+			},
+			expectedStatusCode:  http.StatusServiceUnavailable,
+			expectedRequestBody: `{"message":"too many concurrent operations, try again later."}`,
+		},
 	}
 
 	t.Parallel()
@@ -258,7 +358,7 @@ func TestHandler_fundsTransferHandler(t *testing.T) {
 			testCase.mockUserBehavior(servi)
 
 			services := &service.Service{User: servi}
-			handler := NewHandler(services)
+			handler := NewHandler(services, nil, nil)
 
 			// test server
 			r := gin.New()
@@ -274,6 +374,9 @@ func TestHandler_fundsTransferHandler(t *testing.T) {
 			// assert
 			assert.Equal(t, testCase.expectedStatusCode, w.Code)
 			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+			if testCase.expectedStatusCode == http.StatusServiceUnavailable {
+				assert.Equal(t, "1", w.Header().Get("Retry-After"))
+			}
 		})
 	}
 }
@@ -284,11 +387,11 @@ func TestHandler_getBalance(t *testing.T) {
 			name:      "OK",
 			inputBody: `{"id":348}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().GetBalance(348).Return(float32(100), nil)
+				s.EXPECT().GetBalance(gomock.Any(), "348").Return(&model.Balance{Balance: 100, CreditLimit: 50, Available: 150}, nil)
 			},
 			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {},
 			expectedStatusCode:     http.StatusOK,
-			expectedRequestBody:    `{"balance":100}`,
+			expectedRequestBody:    `{"balance":100,"credit_limit":50,"available":150}`,
 		},
 		{
 			name:                   "Invalid Body",
@@ -296,27 +399,28 @@ func TestHandler_getBalance(t *testing.T) {
 			mockUserBehavior:       func(s *mock_service.MockUser) {},
 			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {},
 			expectedStatusCode:     http.StatusBadRequest,
-			expectedRequestBody:    `{"message":"invalid body."}`,
+			expectedRequestBody:    `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
 		},
 		{
 			name:             "OK With Query Param",
 			inputBody:        `{"id":34}`,
 			inputQueryParams: "?currency=USD",
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().GetBalance(34).Return(float32(100), nil)
+				s.EXPECT().GetBalance(gomock.Any(), "34").Return(&model.Balance{Balance: 100, CreditLimit: 0, Available: 100}, nil)
 			},
 			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {
-				s.EXPECT().ConvertRubTo("USD", float32(100)).Return(1.3, nil)
+				s.EXPECT().ConvertRubTo("USD", float32(100)).Return(1.3, nil).Times(2)
+				s.EXPECT().ConvertRubTo("USD", float32(0)).Return(0.0, nil)
 			},
 			expectedStatusCode:  http.StatusOK,
-			expectedRequestBody: `{"balance":1.3}`,
+			expectedRequestBody: `{"balance":1.3,"credit_limit":0,"available":1.3}`,
 		},
 		{
 			name:             "Invalid Query Param",
 			inputBody:        `{"id":34}`,
 			inputQueryParams: "?currency=XRP",
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().GetBalance(34).Return(float32(100), nil)
+				s.EXPECT().GetBalance(gomock.Any(), "34").Return(&model.Balance{Balance: 100, CreditLimit: 0, Available: 100}, nil)
 			},
 			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {
 				s.EXPECT().ConvertRubTo("XRP", float32(100)).Return(0.0, &service.WrongParam{Param: "currency"})
@@ -328,7 +432,7 @@ func TestHandler_getBalance(t *testing.T) {
 			name:      "Internal Server Error",
 			inputBody: `{"id":14589}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().GetBalance(14589).Return(float32(0), &service.InternalServerError{})
+				s.EXPECT().GetBalance(gomock.Any(), "14589").Return(nil, &service.InternalServerError{})
 			},
 			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {},
 			expectedStatusCode:     http.StatusInternalServerError,
@@ -338,7 +442,7 @@ func TestHandler_getBalance(t *testing.T) {
 			name:      "User Not Found",
 			inputBody: `{"id":91}`,
 			mockUserBehavior: func(s *mock_service.MockUser) {
-				s.EXPECT().GetBalance(91).Return(float32(0), &service.UserNotFound{Id: 91})
+				s.EXPECT().GetBalance(gomock.Any(), "91").Return(nil, &service.UserNotFound{Id: "91"})
 			},
 			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {},
 			expectedStatusCode:     http.StatusNotFound,
@@ -357,7 +461,7 @@ func TestHandler_getBalance(t *testing.T) {
 			testCase.mockUserBehavior(userService)
 
 			services := &service.Service{User: userService}
-			handler := NewHandler(services)
+			handler := NewHandler(services, nil, nil)
 
 			calculator := mock_pkg.NewMockCurrencyCalculator(c)
 			testCase.mockCalculatorBehavior(calculator)
@@ -380,3 +484,825 @@ func TestHandler_getBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_getHistoryHandler(t *testing.T) {
+	senderId, receiverId := "348", "12"
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []testSkillet{
+		{
+			name:      "OK",
+			inputBody: `{"id":348}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetHistory(gomock.Any(), "348").Return([]model.Transaction{
+					{
+						Id:              2,
+						Type:            model.TransactionFundsTransfer,
+						SenderId:        &senderId,
+						ReceiverId:      &receiverId,
+						Sum:             50,
+						TransactionInfo: model.TransactionInfo{OrderId: "o-1", Comment: "за доставку"},
+						CreatedAt:       createdAt,
+					},
+					{
+						Id:         1,
+						Type:       model.TransactionAddFunds,
+						ReceiverId: &senderId,
+						Sum:        100,
+						CreatedAt:  createdAt,
+					},
+				}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedRequestBody: `[{"id":2,"type":"funds_transfer","sender_id":"348","receiver_id":"12","sum":50,` +
+				`"order_id":"o-1","comment":"за доставку","created_at":"2022-01-25T10:30:00Z"},` +
+				`{"id":1,"type":"add_funds","receiver_id":"348","sum":100,"created_at":"2022-01-25T10:30:00Z"}]`,
+		},
+		{
+			name:      "OK Empty",
+			inputBody: `{"id":348}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetHistory(gomock.Any(), "348").Return([]model.Transaction{}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: `[]`,
+		},
+		{
+			name:                "Invalid Body",
+			inputBody:           `{}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
+		},
+		{
+			name:      "User Not Found",
+			inputBody: `{"id":91}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetHistory(gomock.Any(), "91").Return(nil, &service.UserNotFound{Id: "91"})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 91 does not exist."}`,
+		},
+		{
+			name:      "Internal Server Error",
+			inputBody: `{"id":14589}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetHistory(gomock.Any(), "14589").Return(nil, &service.InternalServerError{})
+			},
+			expectedStatusCode:  http.StatusInternalServerError,
+			expectedRequestBody: `{"message":"internal server error."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.GET("/api/v1/get_history", handler.getHistoryHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/get_history", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_reverseTransactionHandler(t *testing.T) {
+	senderId, receiverId, reversedId := "348", "12", 7
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	reversal := &model.Transaction{
+		Id:         8,
+		Type:       model.TransactionReversal,
+		SenderId:   &receiverId,
+		ReceiverId: &senderId,
+		Sum:        50,
+		ReversedId: &reversedId,
+		CreatedAt:  createdAt,
+	}
+
+	testData := []struct {
+		name                    string
+		transactionId           string
+		inputBody               string
+		mockTransactionBehavior mockTransactionBehavior
+		expectedStatusCode      int
+		expectedRequestBody     string
+	}{
+		{
+			name:          "OK Full",
+			transactionId: "7",
+			inputBody:     "",
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 7, float32(0), false, model.TransactionInfo{}).Return(reversal, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedRequestBody: `{"id":8,"type":"reversal","sender_id":"12","receiver_id":"348","sum":50,` +
+				`"reversed_id":7,"created_at":"2022-01-25T10:30:00Z"}`,
+		},
+		{
+			name:          "OK Partial",
+			transactionId: "7",
+			inputBody:     `{"sum": 50, "allow_negative": true, "comment": "refund"}`,
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 7, float32(50), true, model.TransactionInfo{Comment: "refund"}).Return(reversal, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedRequestBody: `{"id":8,"type":"reversal","sender_id":"12","receiver_id":"348","sum":50,` +
+				`"reversed_id":7,"created_at":"2022-01-25T10:30:00Z"}`,
+		},
+		{
+			name:                    "Invalid Id",
+			transactionId:           "seven",
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {},
+			expectedStatusCode:      http.StatusBadRequest,
+			expectedRequestBody:     `{"message":"invalid transaction id.","errors":[{"field":"id","message":"must be a positive integer."}]}`,
+		},
+		{
+			name:                    "Invalid Body",
+			transactionId:           "7",
+			inputBody:               `{"sum": "all"}`,
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {},
+			expectedStatusCode:      http.StatusBadRequest,
+			expectedRequestBody:     `{"message":"invalid body.","errors":[{"field":"sum","message":"must be a number."}]}`,
+		},
+		{
+			name:          "Transaction Not Found",
+			transactionId: "7",
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 7, float32(0), false, model.TransactionInfo{}).
+					Return(nil, &service.TransactionNotFound{Id: 7})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"transaction 7 does not exist."}`,
+		},
+		{
+			name:          "Already Reversed",
+			transactionId: "7",
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 7, float32(0), false, model.TransactionInfo{}).
+					Return(nil, &service.AlreadyReversed{Id: 7})
+			},
+			expectedStatusCode:  http.StatusConflict,
+			expectedRequestBody: `{"message":"transaction 7 has already been reversed."}`,
+		},
+		{
+			name:          "Insufficient Funds",
+			transactionId: "7",
+			inputBody:     `{"sum": 50}`,
+			mockTransactionBehavior: func(s *mock_service.MockTransaction) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 7, float32(50), false, model.TransactionInfo{}).
+					Return(nil, &service.InsufficientFunds{Id: "12"})
+			},
+			expectedStatusCode:  http.StatusPreconditionFailed,
+			expectedRequestBody: `{"message":"user 12 has insufficient funds."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockTransaction(c)
+			testCase.mockTransactionBehavior(servi)
+
+			services := &service.Service{Transaction: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v1/transactions/:id/reverse", handler.reverseTransactionHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/transactions/"+testCase.transactionId+"/reverse",
+				bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_setCreditLimitHandler(t *testing.T) {
+	creditLimit := float32(5000)
+
+	testData := []testSkillet{
+		{
+			name:      "OK",
+			inputBody: `{"id":348, "credit_limit": 5000}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetCreditLimit(gomock.Any(), "348", &creditLimit).Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:      "OK Reset",
+			inputBody: `{"id":348, "credit_limit": null}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetCreditLimit(gomock.Any(), "348", nil).Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:                "Invalid Body",
+			inputBody:           `{"credit_limit": 5000}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
+		},
+		{
+			name:                "Negative Limit",
+			inputBody:           `{"id":348, "credit_limit": -1}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"credit_limit","message":"must be greater than or equal to 0."}]}`,
+		},
+		{
+			name:      "User Not Found",
+			inputBody: `{"id":91, "credit_limit": 5000}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetCreditLimit(gomock.Any(), "91", &creditLimit).Return(&service.UserNotFound{Id: "91"})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 91 does not exist."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v1/admin/set_credit_limit", handler.setCreditLimitHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/admin/set_credit_limit", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_setLimitsHandler(t *testing.T) {
+	dailyDebit, hourlyTransfers := float32(1000), 5
+
+	testData := []testSkillet{
+		{
+			name:      "OK",
+			inputBody: `{"id":348, "daily_debit": 1000, "hourly_transfers": 5}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetLimits(gomock.Any(), "348", model.Limits{DailyDebit: &dailyDebit, HourlyTransfers: &hourlyTransfers}).Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:                "Invalid Body",
+			inputBody:           `{"daily_debit": 1000}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
+		},
+		{
+			name:                "Negative Limit",
+			inputBody:           `{"id":348, "hourly_transfers": -1}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"hourly_transfers","message":"must be greater than or equal to 0."}]}`,
+		},
+		{
+			name:      "User Not Found",
+			inputBody: `{"id":91}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetLimits(gomock.Any(), "91", model.Limits{}).Return(&service.UserNotFound{Id: "91"})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 91 does not exist."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v1/admin/set_limits", handler.setLimitsHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/admin/set_limits", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_setStatusHandler(t *testing.T) {
+	testData := []testSkillet{
+		{
+			name:      "OK",
+			inputBody: `{"id":348, "reason": "compromised"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusFrozen, "compromised").Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:                "Invalid Body",
+			inputBody:           `{"reason": "compromised"}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
+		},
+		{
+			name:      "Empty Reason",
+			inputBody: `{"id":348}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusFrozen, "").Return(&service.WrongParam{Param: "reason"})
+			},
+			expectedStatusCode:  http.StatusPreconditionFailed,
+			expectedRequestBody: `{"message":"wrong reason param."}`,
+		},
+		{
+			name:      "Already Frozen",
+			inputBody: `{"id":348, "reason": "compromised"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusFrozen, "compromised").
+					Return(&service.StatusNotChanged{Id: "348", Status: model.UserStatusFrozen})
+			},
+			expectedStatusCode:  http.StatusConflict,
+			expectedRequestBody: `{"message":"user 348 is already frozen."}`,
+		},
+		{
+			name:      "Closed",
+			inputBody: `{"id":348, "reason": "compromised"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusFrozen, "compromised").Return(&service.UserClosed{Id: "348"})
+			},
+			expectedStatusCode:  http.StatusForbidden,
+			expectedRequestBody: `{"message":"user 348 is closed."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v1/admin/freeze", handler.setStatusHandler(model.UserStatusFrozen))
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/admin/freeze", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_getLedgerHandler(t *testing.T) {
+	testData := []struct {
+		name                string
+		mockLedgerBehavior  mockLedgerBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name: "OK",
+			mockLedgerBehavior: func(s *mock_service.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(&model.Ledger{
+					Accounts: []model.AccountBalance{
+						{Account: model.AccountCashIn, Balance: -500},
+						{Account: model.AccountUser, Balance: 500},
+					},
+					UnbalancedTransactions: []int{},
+					Balanced:               true,
+				}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedRequestBody: `{"accounts":[{"account":"cash_in","balance":-500},{"account":"user","balance":500}],` +
+				`"total":0,"unbalanced_transactions":[],"balanced":true}`,
+		},
+		{
+			name: "Internal Server Error",
+			mockLedgerBehavior: func(s *mock_service.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(nil, &service.InternalServerError{})
+			},
+			expectedStatusCode:  http.StatusInternalServerError,
+			expectedRequestBody: `{"message":"internal server error."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockLedger(c)
+			testCase.mockLedgerBehavior(servi)
+
+			services := &service.Service{Ledger: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.GET("/api/v1/admin/ledger", handler.getLedgerHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/admin/ledger", nil)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_getAuditLogHandler(t *testing.T) {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	from := time.Date(2022, 1, 25, 0, 0, 0, 0, time.UTC)
+	transactionId := 17
+
+	testData := []struct {
+		name                string
+		inputQueryParams    string
+		mockAuditBehavior   mockAuditBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:             "OK",
+			inputQueryParams: "?user_id=348&from=2022-01-25T03:00:00%2B03:00&after_id=10&limit=1",
+			mockAuditBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{UserId: "348", From: &from, AfterId: 10, Limit: 1}).Return([]model.AuditEntry{
+					{
+						Id:            11,
+						CreatedAt:     createdAt,
+						Actor:         "cli",
+						Action:        model.TransactionAddFunds,
+						UserIds:       []string{"348"},
+						TransactionId: &transactionId,
+						Details:       json.RawMessage(`{"sum":2700}`),
+						PrevHash:      "aaa",
+						Hash:          "bbb",
+					},
+				}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedRequestBody: `[{"id":11,"created_at":"2022-01-25T10:30:00Z","actor":"cli","action":"add_funds","user_ids":["348"],` +
+				`"transaction_id":17,"details":{"sum":2700},"prev_hash":"aaa","hash":"bbb"}]`,
+		},
+		{
+			name:               "Invalid Params",
+			inputQueryParams:   "?from=yesterday&limit=ten",
+			mockAuditBehavior:  func(s *mock_service.MockAudit) {},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid params.","errors":[{"field":"from","message":"must be RFC3339 time."},` +
+				`{"field":"limit","message":"must be an integer."}]}`,
+		},
+		{
+			name:             "Wrong Limit",
+			inputQueryParams: "?limit=5000",
+			mockAuditBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{Limit: 5000}).Return(nil, &service.WrongParam{Param: "limit"})
+			},
+			expectedStatusCode:  http.StatusPreconditionFailed,
+			expectedRequestBody: `{"message":"wrong limit param."}`,
+		},
+		{
+			name:             "Internal Server Error",
+			inputQueryParams: "",
+			mockAuditBehavior: func(s *mock_service.MockAudit) {
+				s.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{}).Return(nil, &service.InternalServerError{})
+			},
+			expectedStatusCode:  http.StatusInternalServerError,
+			expectedRequestBody: `{"message":"internal server error."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockAudit(c)
+			testCase.mockAuditBehavior(servi)
+
+			services := &service.Service{Audit: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.GET("/api/v1/admin/audit", handler.getAuditLogHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/admin/audit"+testCase.inputQueryParams, nil)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_createUserHandler(t *testing.T) {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []testSkillet{
+		{
+			name:      "OK",
+			inputBody: `{"id":348, "external_ref": "crm-348"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().CreateUser(gomock.Any(), "348", "crm-348").Return(&model.User{Id: 1, UserId: "348", Status: model.UserStatusActive,
+					ExternalRef: "crm-348", CreatedAt: createdAt, UpdatedAt: createdAt}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedRequestBody: `{"id":"348","balance":0,"credit_limit":0,"status":"active","external_ref":"crm-348",` +
+				`"created_at":"2022-01-25T10:30:00Z","updated_at":"2022-01-25T10:30:00Z"}`,
+		},
+		{
+			name:                "Invalid Body",
+			inputBody:           `{"external_ref": "crm-348"}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."}]}`,
+		},
+		{
+			name:      "Already Exists",
+			inputBody: `{"id":348}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().CreateUser(gomock.Any(), "348", "").Return(nil, &service.UserAlreadyExists{Id: "348"})
+			},
+			expectedStatusCode:  http.StatusConflict,
+			expectedRequestBody: `{"message":"user 348 already exists."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v1/users", handler.createUserHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_getUserHandler(t *testing.T) {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []struct {
+		name                string
+		userId              string
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:   "OK",
+			userId: "348",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetUser(gomock.Any(), "348").Return(&model.User{Id: 1, UserId: "348", Balance: 100, Status: model.UserStatusFrozen,
+					CreatedAt: createdAt, UpdatedAt: createdAt}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedRequestBody: `{"id":"348","balance":100,"credit_limit":0,"status":"frozen",` +
+				`"created_at":"2022-01-25T10:30:00Z","updated_at":"2022-01-25T10:30:00Z"}`,
+		},
+		{
+			name:   "User Not Found",
+			userId: "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetUser(gomock.Any(), "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13").
+					Return(nil, &service.UserNotFound{Id: "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13"})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13 does not exist."}`,
+		},
+		{
+			name:   "User Not Found Legacy Id",
+			userId: "91",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetUser(gomock.Any(), "91").Return(nil, &service.UserNotFound{Id: "91"})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 91 does not exist."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.GET("/api/v1/users/:id", handler.getUserHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v1/users/"+testCase.userId, nil)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_deleteUserHandler(t *testing.T) {
+	testData := []struct {
+		name                string
+		userId              string
+		inputBody           string
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:   "OK",
+			userId: "348",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusClosed, "deleted via api").Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:      "OK With Reason",
+			userId:    "348",
+			inputBody: `{"reason": "by user request"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusClosed, "by user request").Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:   "OK UUID",
+			userId: "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13", model.UserStatusClosed, "deleted via api").Return(nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:   "Non Zero Balance",
+			userId: "348",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().SetStatus(gomock.Any(), "348", model.UserStatusClosed, "deleted via api").Return(&service.NonZeroBalance{Id: "348"})
+			},
+			expectedStatusCode:  http.StatusConflict,
+			expectedRequestBody: `{"message":"user 348 balance is not zero."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.DELETE("/api/v1/users/:id", handler.deleteUserHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("DELETE", "/api/v1/users/"+testCase.userId, bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	avito_tech "for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/service"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Методы v2 - те же операции, что и в v1, но id юзера передается в пути, а не в теле GET запроса,
+// и операции с деньгами возвращают созданную операцию
+
+// @Summary Get User Balance
+// @Description get user (id) balance, credit limit and available to spend sum
+// @Produce json
+// @Param id path string true "user id"
+// @Param currency query string false "balance will convert from RUB to currency"
+// @Success 200 {object} model.Balance
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v2/users/{id}/balance [get]
+func (h *Handler) getUserBalanceHandler(calculator avito_tech.CurrencyCalculator) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		userId := ctx.Param("id")
+		if !validateParam(ctx, "id", userId, "userid") {
+			return
+		}
+		if !validateParam(ctx, "currency", ctx.Query("currency"), "omitempty,currency") {
+			return
+		}
+		if !h.allowUsers(ctx, userId) {
+			return
+		}
+
+		balance, err := h.services.GetBalance(ctx.Request.Context(), userId)
+		if err != nil {
+			responseError, ok := err.(service.ResponseError)
+			if !ok {
+				newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+				return
+			}
+			newServiceErrorResponse(ctx, responseError)
+			return
+		}
+
+		writeBalance(ctx, calculator, balance)
+	}
+}
+
+// @Summary Get User Transactions
+// @Description get operations history for user (id), newest first
+// @Produce json
+// @Param id path string true "user id"
+// @Success 200 {array} model.Transaction
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v2/users/{id}/transactions [get]
+func (h *Handler) getUserTransactionsHandler(ctx *gin.Context) {
+	userId := ctx.Param("id")
+	if !validateParam(ctx, "id", userId, "userid") {
+		return
+	}
+	if !h.allowUsers(ctx, userId) {
+		return
+	}
+
+	transactions, err := h.services.GetHistory(ctx.Request.Context(), userId)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, transactions)
+}
+
+// @Summary Create Credit
+// @Description add funds (sum) for user (id), optional order_id, service_id, comment and source are saved to history
+// @Accept json
+// @Produce json
+// @Param id path string true "user id"
+// @Param input body map[string]interface{} true "input"
+// @Success 201 {object} model.OperationResult
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v2/users/{id}/credits [post]
+func (h *Handler) createCreditHandler(ctx *gin.Context) {
+	userId := ctx.Param("id")
+	if !validateParam(ctx, "id", userId, "userid") {
+		return
+	}
+
+	s := &struct {
+		Sum float32 `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, userId) {
+		return
+	}
+
+	result, err := h.services.AddFunds(ctx.Request.Context(), userId, s.Sum, s.TransactionInfo)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// @Summary Create Debit
+// @Description writes off funds (sum) for user (id), optional order_id, service_id, comment and source are saved to history
+// @Accept json
+// @Produce json
+// @Param id path string true "user id"
+// @Param input body map[string]interface{} true "input"
+// @Success 201 {object} model.OperationResult
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 422 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v2/users/{id}/debits [post]
+func (h *Handler) createDebitHandler(ctx *gin.Context) {
+	userId := ctx.Param("id")
+	if !validateParam(ctx, "id", userId, "userid") {
+		return
+	}
+
+	s := &struct {
+		Sum float32 `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, userId) {
+		return
+	}
+
+	result, err := h.services.WriteOffFunds(ctx.Request.Context(), userId, s.Sum, s.TransactionInfo)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
+
+// @Summary Create Transfer
+// @Description transfer funds (sum) from user (sender_id) to user (receiver_id), optional order_id, service_id, comment and source are saved to history
+// @Accept json
+// @Produce json
+// @Param input body map[string]interface{} true "input"
+// @Success 201 {object} model.OperationResult
+// @Failure 400 {object} errorResponse
+// @Failure 403 {object} errorResponse
+// @Failure 404 {object} errorResponse
+// @Failure 412 {object} errorResponse
+// @Failure 422 {object} errorResponse
+// @Failure 429 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure 503 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /v2/transfers [post]
+func (h *Handler) createTransferHandler(ctx *gin.Context) {
+	s := &struct {
+		SenderId   jsonUserId `json:"sender_id" binding:"required,userid"`
+		ReceiverId jsonUserId `json:"receiver_id" binding:"required,userid"`
+		Sum        float32    `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
+	}{}
+	if !bindJSON(ctx, s) {
+		return
+	}
+	if !h.allowUsers(ctx, string(s.SenderId), string(s.ReceiverId)) {
+		return
+	}
+
+	result, err := h.services.FundsTransfer(ctx.Request.Context(), string(s.SenderId), string(s.ReceiverId), s.Sum, s.TransactionInfo)
+	if err != nil {
+		responseError, ok := err.(service.ResponseError)
+		if !ok {
+			newErrorResponse(ctx, http.StatusInternalServerError, err.Error())
+			return
+		}
+		newServiceErrorResponse(ctx, responseError)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, result)
+}
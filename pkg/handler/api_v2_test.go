@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"bytes"
+	mock_pkg "for_avito_tech_with_gin/pkg/mocks"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/service"
+	mock_service "for_avito_tech_with_gin/pkg/service/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler_getUserBalanceHandler(t *testing.T) {
+	testData := []struct {
+		name                   string
+		userId                 string
+		inputQueryParams       string
+		mockUserBehavior       mockUserBehavior
+		mockCalculatorBehavior mockCalculatorBehavior
+		expectedStatusCode     int
+		expectedRequestBody    string
+	}{
+		{
+			name:   "OK",
+			userId: "348",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetBalance(gomock.Any(), "348").Return(&model.Balance{Balance: 100, CreditLimit: 50, Available: 150}, nil)
+			},
+			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {},
+			expectedStatusCode:     http.StatusOK,
+			expectedRequestBody:    `{"balance":100,"credit_limit":50,"available":150}`,
+		},
+		{
+			name:             "OK With Query Param",
+			userId:           "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13",
+			inputQueryParams: "?currency=USD",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetBalance(gomock.Any(), "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13").
+					Return(&model.Balance{Balance: 100, CreditLimit: 0, Available: 100}, nil)
+			},
+			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {
+				s.EXPECT().ConvertRubTo("USD", float32(100)).Return(1.3, nil).Times(2)
+				s.EXPECT().ConvertRubTo("USD", float32(0)).Return(0.0, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: `{"balance":1.3,"credit_limit":0,"available":1.3}`,
+		},
+		{
+			name:   "User Not Found",
+			userId: "91",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetBalance(gomock.Any(), "91").Return(nil, &service.UserNotFound{Id: "91"})
+			},
+			mockCalculatorBehavior: func(s *mock_pkg.MockCurrencyCalculator) {},
+			expectedStatusCode:     http.StatusNotFound,
+			expectedRequestBody:    `{"message":"user 91 does not exist."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(userService)
+
+			services := &service.Service{User: userService}
+			handler := NewHandler(services, nil, nil)
+
+			calculator := mock_pkg.NewMockCurrencyCalculator(c)
+			testCase.mockCalculatorBehavior(calculator)
+
+			// test server
+			r := gin.New()
+			r.GET("/api/v2/users/:id/balance", handler.getUserBalanceHandler(calculator))
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v2/users/"+testCase.userId+"/balance"+testCase.inputQueryParams, nil)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_getUserTransactionsHandler(t *testing.T) {
+	receiverId := "348"
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []struct {
+		name                string
+		userId              string
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:   "OK",
+			userId: "348",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetHistory(gomock.Any(), "348").Return([]model.Transaction{
+					{Id: 1, Type: model.TransactionAddFunds, ReceiverId: &receiverId, Sum: 500, CreatedAt: createdAt},
+				}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: `[{"id":1,"type":"add_funds","receiver_id":"348","sum":500,"created_at":"2022-01-25T10:30:00Z"}]`,
+		},
+		{
+			name:   "User Not Found",
+			userId: "91",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().GetHistory(gomock.Any(), "91").Return(nil, &service.UserNotFound{Id: "91"})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 91 does not exist."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.GET("/api/v2/users/:id/transactions", handler.getUserTransactionsHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api/v2/users/"+testCase.userId+"/transactions", nil)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_createCreditHandler(t *testing.T) {
+	userId := "348"
+	balance := float32(3200)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []struct {
+		name                string
+		userId              string
+		inputBody           string
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			userId:    "348",
+			inputBody: `{"sum": 2700, "order_id": "o-1"}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), "348", float32(2700), model.TransactionInfo{OrderId: "o-1"}).
+					Return(&model.OperationResult{Transaction: &model.Transaction{Id: 17, Type: model.TransactionAddFunds, ReceiverId: &userId, Sum: 2700,
+						TransactionInfo: model.TransactionInfo{OrderId: "o-1"}, CreatedAt: createdAt}, Balance: &balance}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedRequestBody: `{"transaction":{"id":17,"type":"add_funds","receiver_id":"348","sum":2700,"order_id":"o-1",` +
+				`"created_at":"2022-01-25T10:30:00Z"},"balance":3200}`,
+		},
+		{
+			name:                "Invalid Body",
+			userId:              "348",
+			inputBody:           `{"id": 348}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is unknown."}]}`,
+		},
+		{
+			name:                "Wrong Id",
+			userId:              "a%20b",
+			inputBody:           `{"sum": 10}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid params.","errors":[{"field":"id","message":"must be 1-64 latin letters, digits or _ - . : characters."}]}`,
+		},
+		{
+			name:      "Internal Server Error",
+			userId:    "14589",
+			inputBody: `{"sum": 10}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), "14589", float32(10), model.TransactionInfo{}).Return(nil, &service.InternalServerError{})
+			},
+			expectedStatusCode:  http.StatusInternalServerError,
+			expectedRequestBody: `{"message":"internal server error."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v2/users/:id/credits", handler.createCreditHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v2/users/"+testCase.userId+"/credits", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_createDebitHandler(t *testing.T) {
+	userId := "348"
+	balance := float32(300)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []struct {
+		name                string
+		userId              string
+		inputBody           string
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			userId:    "348",
+			inputBody: `{"sum": 2700}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().WriteOffFunds(gomock.Any(), "348", float32(2700), model.TransactionInfo{}).
+					Return(&model.OperationResult{Transaction: &model.Transaction{Id: 18, Type: model.TransactionWriteOffFunds, SenderId: &userId, Sum: 2700, CreatedAt: createdAt},
+						Balance: &balance}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedRequestBody: `{"transaction":{"id":18,"type":"write_off_funds","sender_id":"348","sum":2700,"created_at":"2022-01-25T10:30:00Z"},` +
+				`"balance":300}`,
+		},
+		{
+			name:                "Invalid Body",
+			userId:              "348",
+			inputBody:           `{"sum": "many"}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"must be a number."}]}`,
+		},
+		{
+			name:      "Insufficient Funds",
+			userId:    "348",
+			inputBody: `{"sum": 2700}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().WriteOffFunds(gomock.Any(), "348", float32(2700), model.TransactionInfo{}).Return(nil, &service.InsufficientFunds{Id: "348"})
+			},
+			expectedStatusCode:  http.StatusPreconditionFailed,
+			expectedRequestBody: `{"message":"user 348 has insufficient funds."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v2/users/:id/debits", handler.createDebitHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v2/users/"+testCase.userId+"/debits", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_createTransferHandler(t *testing.T) {
+	senderId, receiverId := "348", "4389"
+	senderBalance, receiverBalance := float32(300), float32(2700)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []testSkillet{
+		{
+			name:      "OK",
+			inputBody: `{"sender_id": "348", "receiver_id": 4389, "sum": 2700}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), "348", "4389", float32(2700), model.TransactionInfo{}).
+					Return(&model.OperationResult{Transaction: &model.Transaction{Id: 19, Type: model.TransactionFundsTransfer, SenderId: &senderId, ReceiverId: &receiverId,
+						Sum: 2700, CreatedAt: createdAt}, SenderBalance: &senderBalance, ReceiverBalance: &receiverBalance}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedRequestBody: `{"transaction":{"id":19,"type":"funds_transfer","sender_id":"348","receiver_id":"4389","sum":2700,` +
+				`"created_at":"2022-01-25T10:30:00Z"},"sender_balance":300,"receiver_balance":2700}`,
+		},
+		{
+			name:                "Invalid Body",
+			inputBody:           `{"sender_id": "348", "sum": 2700}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"receiver_id","message":"is required."}]}`,
+		},
+		{
+			name:      "Same Id",
+			inputBody: `{"sender_id": "348", "receiver_id": "348", "sum": 2700}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), "348", "348", float32(2700), model.TransactionInfo{}).Return(nil, &service.SameId{})
+			},
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"user cannot send money to himself."}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v2/transfers", handler.createTransferHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v2/transfers", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
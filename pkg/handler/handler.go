@@ -1,34 +1,97 @@
 package handler
 
 import (
+	"context"
 	_ "for_avito_tech_with_gin/docs"
 	"for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/auth"
+	"for_avito_tech_with_gin/pkg/logging"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/ratelimit"
 	"for_avito_tech_with_gin/pkg/service"
+	"for_avito_tech_with_gin/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Handler struct {
-	services *service.Service
+	services   *service.Service
+	limiter    *ratelimit.Limiter
+	authorizer *auth.Authorizer
 }
 
-func NewHandler(services *service.Service) *Handler {
-	return &Handler{services: services}
+// NewHandler limiter - лимиты запросов на клиента и на юзера, nil - без лимитов,
+// authorizer - права клиентов по сертификатам при mTLS, nil - права не проверяются
+func NewHandler(services *service.Service, limiter *ratelimit.Limiter, authorizer *auth.Authorizer) *Handler {
+	return &Handler{services: services, limiter: limiter, authorizer: authorizer}
 }
 
-func (h Handler) InitRouters() *gin.Engine {
+// RouterConfig настройки роутера, которые задаются в секции server конфига
+type RouterConfig struct {
+	MaxBodyBytes   int64    // 0 - размер тела не ограничен
+	Pprof          bool     // включить /debug/pprof, нужно право admin, конфиг разрешает только вместе с mTLS
+	TrustedProxies []string // ip и подсети прокси, которым можно верить в X-Forwarded-For, пусто - не верить никому
+}
+
+func (h *Handler) InitRouters(c RouterConfig) *gin.Engine {
 	router := gin.New()
+	// по умолчанию gin верит X-Forwarded-For от любого адреса, и клиент мог бы подставить чужой ip в лимиты и журнал
+	// аудита. Невалидный список не пропускает валидация конфига, но и тогда лучше не верить никому
+	if err := router.SetTrustedProxies(c.TrustedProxies); err != nil {
+		_ = router.SetTrustedProxies(nil)
+	}
+	router.Use(otelgin.Middleware(tracing.ServiceName), h.requestIdMiddleware, h.actorMiddleware, h.accessLogMiddleware,
+		h.recoveryMiddleware, bodyLimitMiddleware(c.MaxBodyBytes), h.rateLimitMiddleware)
 
-	api := router.Group("/api/v1", h.middleware)
+	api := router.Group("/api/v1", h.authorizeMiddleware)
 	{
+		api.POST("/users", h.createUserHandler)
+		api.GET("/users/:id", h.getUserHandler)
+		api.DELETE("/users/:id", h.deleteUserHandler)
 		api.POST("/add_funds", h.addFundsHandler)
 		api.POST("/write_off_funds", h.writeOffFundsHandler)
 		api.POST("/funds_transfer", h.fundsTransferHandler)
 		api.GET("/get_balance", h.getBalanceHandler(&pkg.DefaultCurrencyCalculator{}))
+		api.GET("/get_history", h.getHistoryHandler)
+		api.POST("/transactions/:id/reverse", h.reverseTransactionHandler)
+
+		admin := api.Group("/admin")
+		{
+			admin.POST("/set_credit_limit", h.setCreditLimitHandler)
+			admin.POST("/set_limits", h.setLimitsHandler)
+			admin.POST("/freeze", h.setStatusHandler(model.UserStatusFrozen))
+			admin.POST("/unfreeze", h.setStatusHandler(model.UserStatusActive))
+			admin.POST("/close", h.setStatusHandler(model.UserStatusClosed))
+			admin.GET("/ledger", h.getLedgerHandler)
+			admin.GET("/audit", h.getAuditLogHandler)
+		}
+	}
+
+	apiV2 := router.Group("/api/v2", h.authorizeMiddleware)
+	{
+		apiV2.POST("/users", h.createUserHandler)
+		apiV2.GET("/users/:id", h.getUserHandler)
+		apiV2.DELETE("/users/:id", h.deleteUserHandler)
+		apiV2.GET("/users/:id/balance", h.getUserBalanceHandler(&pkg.DefaultCurrencyCalculator{}))
+		apiV2.GET("/users/:id/transactions", h.getUserTransactionsHandler)
+		apiV2.POST("/users/:id/credits", h.createCreditHandler)
+		apiV2.POST("/users/:id/debits", h.createDebitHandler)
+		apiV2.POST("/transfers", h.createTransferHandler)
 	}
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	if c.Pprof {
+		router.Group("/debug/pprof", h.authorizeMiddleware).Any("/*name", pprofHandler)
+	}
+
 	return router
 }
+
+// logger логгер хендлеров, уровень задается в log.packages.handler
+func logger(ctx context.Context) *logrus.Entry {
+	return logging.Package(ctx, "handler")
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// jsonUserId id юзера в теле запроса. Принимается и строкой (UUID, внешний id), и целым положительным числом, как было раньше
+type jsonUserId string
+
+func (r *jsonUserId) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*r = jsonUserId(s)
+		return nil
+	}
+
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || n <= 0 {
+		return &json.UnmarshalTypeError{Value: "number " + string(data), Type: reflect.TypeOf(*r)}
+	}
+	*r = jsonUserId(strconv.FormatInt(n, 10))
+	return nil
+}
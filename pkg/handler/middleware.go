@@ -1,14 +1,215 @@
 package handler
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"for_avito_tech_with_gin/pkg/auth"
+	"for_avito_tech_with_gin/pkg/logging"
+	"for_avito_tech_with_gin/pkg/ratelimit"
+	"for_avito_tech_with_gin/pkg/service"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"math"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func (h Handler) middleware(ctx *gin.Context) {
+const (
+	requestIdHeader  = "X-Request-ID"
+	retryAfterHeader = "Retry-After"
+	userIdsKey       = "user_ids"
+)
+
+// id запроса от клиента принимается, только если он похож на id, иначе генерируется новый
+var requestIdRegexp = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,128}$`)
+
+// requestIdMiddleware берет id запроса из заголовка X-Request-ID или генерирует новый, возвращает его в ответе
+// и кладет в контекст запроса, чтобы он попал в логи сервисов и репозиториев
+func (h *Handler) requestIdMiddleware(ctx *gin.Context) {
+	requestId := ctx.GetHeader(requestIdHeader)
+	if !requestIdRegexp.MatchString(requestId) {
+		requestId = newRequestId()
+	}
+
+	ctx.Header(requestIdHeader, requestId)
+	ctx.Request = ctx.Request.WithContext(logging.WithRequestId(ctx.Request.Context(), requestId))
+	ctx.Next()
+}
+
+// actorMiddleware кладет в контекст запроса клиента для журнала аудита
+func (h *Handler) actorMiddleware(ctx *gin.Context) {
+	ctx.Request = ctx.Request.WithContext(service.WithActor(ctx.Request.Context(), clientId(ctx)))
+	ctx.Next()
+}
+
+// accessLogMiddleware пишет одну строку лога на запрос
+func (h *Handler) accessLogMiddleware(ctx *gin.Context) {
+	start := time.Now()
+	ctx.Next()
+
+	status := ctx.Writer.Status()
+	entry := logger(ctx.Request.Context()).WithFields(logrus.Fields{
+		"method":     ctx.Request.Method,
+		"route":      ctx.FullPath(),
+		"path":       ctx.Request.URL.Path,
+		"status":     status,
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+		"client_ip":  ctx.ClientIP(),
+		"user_agent": ctx.Request.UserAgent(),
+		"bytes":      ctx.Writer.Size(),
+	})
+	if userIds := ctx.GetStringSlice(userIdsKey); len(userIds) > 0 {
+		entry = entry.WithField(userIdsKey, userIds)
+	}
+
+	switch {
+	case status >= http.StatusInternalServerError:
+		entry.Error("request")
+	case status >= http.StatusBadRequest:
+		entry.Warn("request")
+	default:
+		entry.Info("request")
+	}
+}
+
+// recoveryMiddleware ловит панику в хендлере и отвечает 500, вместо того чтобы рвать соединение
+func (h *Handler) recoveryMiddleware(ctx *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
-			logrus.Errorf("recovered: %v", err)
+			logger(ctx.Request.Context()).WithField("stack", string(debug.Stack())).Errorf("recovered: %v", err)
+			newErrorResponse(ctx, http.StatusInternalServerError, "internal server error.")
 		}
 	}()
+
+	ctx.Next()
+}
+
+// bodyLimitMiddleware отвечает 413, если тело запроса больше limit байт. Если размер тела заранее неизвестен,
+// ошибка будет при чтении тела в bindJSON
+func bodyLimitMiddleware(limit int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if limit <= 0 {
+			ctx.Next()
+			return
+		}
+		if ctx.Request.ContentLength > limit {
+			newErrorResponse(ctx, http.StatusRequestEntityTooLarge, "request body too large.")
+			return
+		}
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limit)
+		ctx.Next()
+	}
+}
+
+// rateLimitMiddleware ограничивает частоту запросов к маршруту от одного клиента
+func (h *Handler) rateLimitMiddleware(ctx *gin.Context) {
+	if h.limiter == nil {
+		ctx.Next()
+		return
+	}
+
+	decision, err := h.limiter.AllowClient(ctx.Request.Context(), ctx.Request.Method+" "+ctx.FullPath(), clientId(ctx))
+	if !h.checkRateLimit(ctx, decision, err) {
+		return
+	}
+	ctx.Next()
+}
+
+// authorizeMiddleware проверяет права клиента по его сертификату (tls.client_ca_file и auth.clients в конфиге).
+// Запросы без проверенного клиентского сертификата (http или TLS без mTLS) пропускаются
+func (h *Handler) authorizeMiddleware(ctx *gin.Context) {
+	if h.authorizer == nil || ctx.Request.TLS == nil || len(ctx.Request.TLS.VerifiedChains) == 0 {
+		ctx.Next()
+		return
+	}
+
+	cert := ctx.Request.TLS.VerifiedChains[0][0]
+	permission := requiredPermission(ctx)
+	if !h.authorizer.Allowed(cert, permission) {
+		logger(ctx.Request.Context()).WithField("subject", cert.Subject.String()).Warnf("no %s permission", permission)
+		newErrorResponse(ctx, http.StatusForbidden, "forbidden.")
+		return
+	}
+	ctx.Next()
+}
+
+// requiredPermission admin для /api/v1/admin, /debug/pprof и удаления юзера (это то же закрытие, что и /api/v1/admin/close),
+// read для GET, write для остальных запросов
+func requiredPermission(ctx *gin.Context) string {
+	switch {
+	case strings.HasPrefix(ctx.FullPath(), "/api/v1/admin/"), strings.HasPrefix(ctx.FullPath(), "/debug/pprof/"):
+		return auth.PermissionAdmin
+	case ctx.Request.Method == http.MethodDelete && strings.HasSuffix(ctx.FullPath(), "/users/:id"):
+		return auth.PermissionAdmin
+	case ctx.Request.Method == http.MethodGet:
+		return auth.PermissionRead
+	default:
+		return auth.PermissionWrite
+	}
+}
+
+// allowUsers запоминает юзеров запроса для лога и проверяет лимит маршрута на каждого из них.
+// Если лимит превышен - отвечает 429 и возвращает false
+func (h *Handler) allowUsers(ctx *gin.Context, userIds ...string) bool {
+	logUserIds(ctx, userIds...)
+	if h.limiter == nil {
+		return true
+	}
+
+	for _, userId := range userIds {
+		decision, err := h.limiter.AllowUser(ctx.Request.Context(), ctx.Request.Method+" "+ctx.FullPath(), userId)
+		if !h.checkRateLimit(ctx, decision, err) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkRateLimit если хранилище лимитов недоступно, запрос пропускается - лучше без лимитов, чем без переводов
+func (h *Handler) checkRateLimit(ctx *gin.Context, decision ratelimit.Decision, err error) bool {
+	if err != nil {
+		logger(ctx.Request.Context()).Error(err)
+		return true
+	}
+	if decision.Allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	ctx.Header(retryAfterHeader, strconv.Itoa(retryAfter))
+	newErrorResponse(ctx, http.StatusTooManyRequests, "too many requests.")
+	return false
+}
+
+// clientId клиент запроса для лимитов и журнала аудита: subject проверенного сертификата при mTLS, иначе ip. Заголовку
+// X-API-Key верить нельзя, сервис ключи не проверяет: со случайным ключом клиент получал бы новый лимит и чужое имя в журнале.
+// Ip берется из X-Forwarded-For только от доверенных прокси (RouterConfig.TrustedProxies), иначе - адрес соединения
+func clientId(ctx *gin.Context) string {
+	if ctx.Request.TLS != nil && len(ctx.Request.TLS.VerifiedChains) > 0 {
+		return "cert:" + ctx.Request.TLS.VerifiedChains[0][0].Subject.String()
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// logUserIds запоминает, каких юзеров касается запрос, чтобы они попали в лог запроса
+func logUserIds(ctx *gin.Context, userIds ...string) {
+	ctx.Set(userIdsKey, userIds)
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+
+	return hex.EncodeToString(b)
 }
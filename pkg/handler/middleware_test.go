@@ -0,0 +1,579 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"for_avito_tech_with_gin/pkg/auth"
+	"for_avito_tech_with_gin/pkg/logging"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/ratelimit"
+	"for_avito_tech_with_gin/pkg/service"
+	mock_service "for_avito_tech_with_gin/pkg/service/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler_middleware(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	userId, balance := "348", float32(100)
+	result := &model.OperationResult{Transaction: &model.Transaction{Id: 1}, Balance: &balance}
+
+	testData := []struct {
+		name                string
+		requestId           string
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+		expectedRequestId   string
+		expectedLevel       logrus.Level
+	}{
+		{
+			name:      "OK",
+			requestId: "req-1",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), userId, float32(100), model.TransactionInfo{}).
+					DoAndReturn(func(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+						logging.FromContext(ctx).Info("service log")
+						return result, nil
+					})
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: `{"transaction":{"id":1,"type":"","sum":0,"created_at":"0001-01-01T00:00:00Z"},"balance":100}`,
+			expectedRequestId:   "req-1",
+			expectedLevel:       logrus.InfoLevel,
+		},
+		{
+			name:      "Generated Id",
+			requestId: "bad id",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), userId, float32(100), model.TransactionInfo{}).
+					DoAndReturn(func(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+						logging.FromContext(ctx).Info("service log")
+						return nil, &service.UserNotFound{Id: userId}
+					})
+			},
+			expectedStatusCode:  http.StatusNotFound,
+			expectedRequestBody: `{"message":"user 348 does not exist."}`,
+			expectedLevel:       logrus.WarnLevel,
+		},
+		{
+			name:      "Panic",
+			requestId: "req-3",
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().AddFunds(gomock.Any(), userId, float32(100), model.TransactionInfo{}).
+					DoAndReturn(func(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+						logging.FromContext(ctx).Info("service log")
+						panic("lol kek cheburek.")
+					})
+			},
+			expectedStatusCode:  http.StatusInternalServerError,
+			expectedRequestBody: `{"message":"internal server error."}`,
+			expectedRequestId:   "req-3",
+			expectedLevel:       logrus.ErrorLevel,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			hook.Reset()
+
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			handler := NewHandler(services, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.Use(handler.requestIdMiddleware, handler.accessLogMiddleware, handler.recoveryMiddleware)
+			r.POST("/api/v1/add_funds", handler.addFundsHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/add_funds", bytes.NewBufferString(`{"id":348, "sum": 100}`))
+			req.Header.Set(requestIdHeader, testCase.requestId)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+
+			requestId := w.Header().Get(requestIdHeader)
+			if testCase.expectedRequestId != "" {
+				assert.Equal(t, testCase.expectedRequestId, requestId)
+			} else {
+				assert.Regexp(t, `^[0-9a-f]{32}$`, requestId)
+			}
+
+			entries := hook.AllEntries()
+			if assert.NotEmpty(t, entries) {
+				assert.Equal(t, "service log", entries[0].Message)
+			}
+			for _, entry := range entries {
+				assert.Equal(t, requestId, entry.Data[logging.RequestIdField])
+			}
+
+			access := hook.LastEntry()
+			assert.Equal(t, "request", access.Message)
+			assert.Equal(t, testCase.expectedLevel, access.Level)
+			assert.Equal(t, "/api/v1/add_funds", access.Data["route"])
+			assert.Equal(t, testCase.expectedStatusCode, access.Data["status"])
+			assert.Equal(t, []string{userId}, access.Data[userIdsKey])
+		})
+	}
+}
+
+func TestHandler_tracing(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	balance := &model.Balance{Balance: 100, Available: 100}
+
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	servi := mock_service.NewMockUser(c)
+	servi.EXPECT().GetBalance(gomock.Any(), "348").
+		DoAndReturn(func(ctx context.Context, userId string) (*model.Balance, error) {
+			logging.FromContext(ctx).Info("service log")
+			return balance, nil
+		})
+
+	services := &service.Service{User: servi}
+	handler := NewHandler(services, nil, nil)
+
+	// test server
+	r := handler.InitRouters(RouterConfig{})
+
+	// test request
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v2/users/348/balance", nil)
+	req.Header.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+
+	// perform request
+	r.ServeHTTP(w, req)
+
+	// assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "/api/v2/users/:id/balance", spans[0].Name())
+		assert.Equal(t, traceId, spans[0].SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	}
+
+	entries := hook.AllEntries()
+	if assert.NotEmpty(t, entries) {
+		assert.Equal(t, "service log", entries[0].Message)
+		assert.Equal(t, traceId, entries[0].Data["trace_id"])
+	}
+}
+
+func TestHandler_rateLimit(t *testing.T) {
+	result := &model.OperationResult{Transaction: &model.Transaction{Id: 1}}
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	support := &x509.Certificate{Subject: pkix.Name{CommonName: "support"}}
+
+	type request struct {
+		remoteAddr         string
+		forwardedFor       string
+		apiKey             string
+		cert               *x509.Certificate
+		inputBody          string
+		expectedStatusCode int
+		expectedRetryAfter string
+	}
+
+	testData := []struct {
+		name             string
+		limits           ratelimit.RouteConfig
+		trustedProxies   []string
+		mockUserBehavior mockUserBehavior
+		requests         []request
+	}{
+		{
+			name:   "Client Limit",
+			limits: ratelimit.RouteConfig{Route: "POST /api/v1/funds_transfer", Client: ratelimit.Limit{Rate: 0.5, Burst: 1}},
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), gomock.Any(), gomock.Any(), float32(10), model.TransactionInfo{}).Return(result, nil).Times(2)
+			},
+			requests: []request{
+				{remoteAddr: "192.0.2.1:1234", inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "192.0.2.1:1234", inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusTooManyRequests, expectedRetryAfter: "2"},
+				{remoteAddr: "192.0.2.2:1234", inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusOK},
+			},
+		},
+		{
+			// ключ не проверяется, поэтому новый ключ не дает нового лимита
+			name:   "Client Limit With API Key",
+			limits: ratelimit.RouteConfig{Route: "POST /api/v1/funds_transfer", Client: ratelimit.Limit{Rate: 0.5, Burst: 1}},
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), gomock.Any(), gomock.Any(), float32(10), model.TransactionInfo{}).Return(result, nil).Times(1)
+			},
+			requests: []request{
+				{remoteAddr: "192.0.2.1:1234", apiKey: "a", inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "192.0.2.1:1234", apiKey: "b", inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusTooManyRequests, expectedRetryAfter: "2"},
+			},
+		},
+		{
+			// X-Forwarded-For не от доверенного прокси подделан клиентом, новый ip в нем не дает нового лимита
+			name:   "Client Limit With Forged X-Forwarded-For",
+			limits: ratelimit.RouteConfig{Route: "POST /api/v1/funds_transfer", Client: ratelimit.Limit{Rate: 0.5, Burst: 1}},
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), gomock.Any(), gomock.Any(), float32(10), model.TransactionInfo{}).Return(result, nil).Times(1)
+			},
+			requests: []request{
+				{remoteAddr: "192.0.2.1:1234", forwardedFor: "198.51.100.1", inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "192.0.2.1:1234", forwardedFor: "198.51.100.2", inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusTooManyRequests, expectedRetryAfter: "2"},
+			},
+		},
+		{
+			name:           "Client Limit Behind Trusted Proxy",
+			limits:         ratelimit.RouteConfig{Route: "POST /api/v1/funds_transfer", Client: ratelimit.Limit{Rate: 0.5, Burst: 1}},
+			trustedProxies: []string{"10.0.0.0/8"},
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), gomock.Any(), gomock.Any(), float32(10), model.TransactionInfo{}).Return(result, nil).Times(2)
+			},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1234", forwardedFor: "198.51.100.1", inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1234", forwardedFor: "198.51.100.1", inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusTooManyRequests, expectedRetryAfter: "2"},
+				{remoteAddr: "10.0.0.1:1234", forwardedFor: "198.51.100.2", inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusOK},
+			},
+		},
+		{
+			name:   "Client Limit With Cert",
+			limits: ratelimit.RouteConfig{Route: "POST /api/v1/funds_transfer", Client: ratelimit.Limit{Rate: 0.5, Burst: 1}},
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), gomock.Any(), gomock.Any(), float32(10), model.TransactionInfo{}).Return(result, nil).Times(2)
+			},
+			requests: []request{
+				{remoteAddr: "192.0.2.1:1234", cert: billing, inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "192.0.2.1:1234", cert: support, inputBody: `{"sender_id":3, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "192.0.2.2:1234", cert: billing, inputBody: `{"sender_id":5, "receiver_id":6, "sum":10}`, expectedStatusCode: http.StatusTooManyRequests, expectedRetryAfter: "2"},
+			},
+		},
+		{
+			name:   "User Limit",
+			limits: ratelimit.RouteConfig{Route: "POST /api/v1/funds_transfer", User: ratelimit.Limit{Rate: 10, Burst: 1}},
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), gomock.Any(), gomock.Any(), float32(10), model.TransactionInfo{}).Return(result, nil).Times(2)
+			},
+			requests: []request{
+				{remoteAddr: "192.0.2.1:1234", inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusOK},
+				{remoteAddr: "192.0.2.2:1234", inputBody: `{"sender_id":3, "receiver_id":2, "sum":10}`, expectedStatusCode: http.StatusTooManyRequests, expectedRetryAfter: "1"},
+				{remoteAddr: "192.0.2.2:1234", inputBody: `{"sender_id":5, "receiver_id":4, "sum":10}`, expectedStatusCode: http.StatusOK},
+			},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+
+			services := &service.Service{User: servi}
+			limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), []ratelimit.RouteConfig{testCase.limits})
+			handler := NewHandler(services, limiter, nil)
+
+			// test server
+			r := handler.InitRouters(RouterConfig{TrustedProxies: testCase.trustedProxies})
+
+			for _, request := range testCase.requests {
+				// test request
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "/api/v1/funds_transfer", bytes.NewBufferString(request.inputBody))
+				req.RemoteAddr = request.remoteAddr
+				if request.forwardedFor != "" {
+					req.Header.Set("X-Forwarded-For", request.forwardedFor)
+				}
+				if request.apiKey != "" {
+					req.Header.Set("X-API-Key", request.apiKey)
+				}
+				if request.cert != nil {
+					req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{request.cert}}}
+				}
+
+				// perform request
+				r.ServeHTTP(w, req)
+
+				// assert
+				assert.Equal(t, request.expectedStatusCode, w.Code)
+				assert.Equal(t, request.expectedRetryAfter, w.Header().Get(retryAfterHeader))
+				if request.expectedStatusCode == http.StatusTooManyRequests {
+					assert.Equal(t, `{"message":"too many requests."}`, w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_authorize(t *testing.T) {
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	support := &x509.Certificate{Subject: pkix.Name{CommonName: "support"}}
+	authorizer := auth.NewAuthorizer([]auth.Rule{
+		{CommonName: "billing", Permissions: []string{auth.PermissionRead, auth.PermissionWrite}},
+		{CommonName: "support", Permissions: []string{auth.PermissionRead, auth.PermissionAdmin}},
+	})
+
+	testData := []struct {
+		name               string
+		method             string
+		path               string
+		cert               *x509.Certificate
+		expectedStatusCode int
+	}{
+		{name: "Read", method: "GET", path: "/api/v1/users/348", cert: support, expectedStatusCode: http.StatusOK},
+		{name: "Write", method: "POST", path: "/api/v1/funds_transfer", cert: billing, expectedStatusCode: http.StatusOK},
+		{name: "No Write Permission", method: "POST", path: "/api/v1/funds_transfer", cert: support, expectedStatusCode: http.StatusForbidden},
+		{name: "Admin", method: "POST", path: "/api/v1/admin/freeze", cert: support, expectedStatusCode: http.StatusOK},
+		{name: "No Admin Permission", method: "POST", path: "/api/v1/admin/freeze", cert: billing, expectedStatusCode: http.StatusForbidden},
+		{name: "Delete User", method: "DELETE", path: "/api/v1/users/348", cert: support, expectedStatusCode: http.StatusOK},
+		// удаление закрывает юзера, как /api/v1/admin/close, права write на него не хватает
+		{name: "Delete User Without Admin Permission", method: "DELETE", path: "/api/v1/users/348", cert: billing, expectedStatusCode: http.StatusForbidden},
+		{name: "Delete User v2 Without Admin Permission", method: "DELETE", path: "/api/v2/users/348", cert: billing, expectedStatusCode: http.StatusForbidden},
+		{name: "Unknown Client", method: "GET", path: "/api/v1/users/348", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "lol"}},
+			expectedStatusCode: http.StatusForbidden},
+		{name: "Without mTLS", method: "POST", path: "/api/v1/admin/freeze", expectedStatusCode: http.StatusOK},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// test server
+			handler := NewHandler(&service.Service{}, nil, authorizer)
+			r := gin.New()
+			api := r.Group("/api/v1", handler.authorizeMiddleware)
+			ok := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+			api.GET("/users/:id", ok)
+			api.DELETE("/users/:id", ok)
+			api.POST("/funds_transfer", ok)
+			api.POST("/admin/freeze", ok)
+			r.Group("/api/v2", handler.authorizeMiddleware).DELETE("/users/:id", ok)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.method, testCase.path, nil)
+			if testCase.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{testCase.cert}}}
+			}
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			if testCase.expectedStatusCode == http.StatusForbidden {
+				assert.Equal(t, `{"message":"forbidden."}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_actor(t *testing.T) {
+	testData := []struct {
+		name           string
+		apiKey         string
+		forwardedFor   string
+		trustedProxies []string
+		cert           *x509.Certificate
+		expectedActor  string
+	}{
+		{name: "Cert", apiKey: "secret", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "support"}}, expectedActor: "cert:CN=support"},
+		// ключ не проверяется, любой клиент мог бы назваться чужим ключом
+		{name: "API Key", apiKey: "secret", expectedActor: "ip:192.0.2.1"},
+		{name: "IP", expectedActor: "ip:192.0.2.1"},
+		// заголовок не от доверенного прокси подделан клиентом
+		{name: "Forged X-Forwarded-For", forwardedFor: "198.51.100.1", expectedActor: "ip:192.0.2.1"},
+		{name: "Trusted Proxy", forwardedFor: "198.51.100.1", trustedProxies: []string{"192.0.2.0/24"}, expectedActor: "ip:198.51.100.1"},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// test server
+			handler := NewHandler(&service.Service{}, nil, nil)
+			r := handler.InitRouters(RouterConfig{TrustedProxies: testCase.trustedProxies})
+			var actor string
+			r.GET("/actor", func(ctx *gin.Context) {
+				actor = service.Actor(ctx.Request.Context())
+				ctx.Status(http.StatusOK)
+			})
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/actor", nil)
+			if testCase.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", testCase.forwardedFor)
+			}
+			if testCase.apiKey != "" {
+				req.Header.Set("X-API-Key", testCase.apiKey)
+			}
+			if testCase.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{testCase.cert}}}
+			}
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, testCase.expectedActor, actor)
+		})
+	}
+}
+
+func TestHandler_bodyLimit(t *testing.T) {
+	result := &model.OperationResult{Transaction: &model.Transaction{Id: 1}}
+
+	testData := []struct {
+		name                string
+		inputBody           string
+		unknownLength       bool
+		mockUserBehavior    mockUserBehavior
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:      "OK",
+			inputBody: `{"sender_id":1, "receiver_id":2, "sum":10}`,
+			mockUserBehavior: func(s *mock_service.MockUser) {
+				s.EXPECT().FundsTransfer(gomock.Any(), "1", "2", float32(10), model.TransactionInfo{}).Return(result, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:                "Too Large",
+			inputBody:           `{"sender_id":1, "receiver_id":2, "sum":10, "comment":"lol kek cheburek."}`,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusRequestEntityTooLarge,
+			expectedRequestBody: `{"message":"request body too large."}`,
+		},
+		{
+			name:                "Too Large Without Content-Length",
+			inputBody:           `{"sender_id":1, "receiver_id":2, "sum":10, "comment":"lol kek cheburek."}`,
+			unknownLength:       true,
+			mockUserBehavior:    func(s *mock_service.MockUser) {},
+			expectedStatusCode:  http.StatusRequestEntityTooLarge,
+			expectedRequestBody: `{"message":"request body too large."}`,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			servi := mock_service.NewMockUser(c)
+			testCase.mockUserBehavior(servi)
+			handler := NewHandler(&service.Service{User: servi}, nil, nil)
+
+			// test server
+			r := gin.New()
+			r.POST("/api/v1/funds_transfer", bodyLimitMiddleware(50), handler.fundsTransferHandler)
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/v1/funds_transfer", bytes.NewBufferString(testCase.inputBody))
+			if testCase.unknownLength {
+				req.ContentLength = -1
+			}
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			if testCase.expectedRequestBody != "" {
+				assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_pprof(t *testing.T) {
+	billing := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	support := &x509.Certificate{Subject: pkix.Name{CommonName: "support"}}
+	authorizer := auth.NewAuthorizer([]auth.Rule{
+		{CommonName: "billing", Permissions: []string{auth.PermissionRead, auth.PermissionWrite}},
+		{CommonName: "support", Permissions: []string{auth.PermissionAdmin}},
+	})
+
+	testData := []struct {
+		name               string
+		pprof              bool
+		path               string
+		cert               *x509.Certificate
+		expectedStatusCode int
+	}{
+		{name: "Disabled", path: "/debug/pprof/", expectedStatusCode: http.StatusNotFound},
+		{name: "Index", pprof: true, path: "/debug/pprof/", expectedStatusCode: http.StatusOK},
+		{name: "Profile", pprof: true, path: "/debug/pprof/heap?debug=1", expectedStatusCode: http.StatusOK},
+		{name: "Cmdline", pprof: true, path: "/debug/pprof/cmdline", cert: support, expectedStatusCode: http.StatusOK},
+		{name: "No Admin Permission", pprof: true, path: "/debug/pprof/", cert: billing, expectedStatusCode: http.StatusForbidden},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// test server
+			handler := NewHandler(&service.Service{}, nil, authorizer)
+			r := handler.InitRouters(RouterConfig{Pprof: testCase.pprof})
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", testCase.path, nil)
+			if testCase.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{testCase.cert}}}
+			}
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+		})
+	}
+}
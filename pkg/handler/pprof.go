@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http/pprof"
+)
+
+// pprofHandler /debug/pprof/* как в net/http/pprof. Профиль cpu и trace снимаются seconds секунд, и seconds должен
+// быть меньше server.write_timeout, иначе ответ оборвется
+func pprofHandler(ctx *gin.Context) {
+	switch ctx.Param("name") {
+	case "/cmdline":
+		pprof.Cmdline(ctx.Writer, ctx.Request)
+	case "/profile":
+		pprof.Profile(ctx.Writer, ctx.Request)
+	case "/symbol":
+		pprof.Symbol(ctx.Writer, ctx.Request)
+	case "/trace":
+		pprof.Trace(ctx.Writer, ctx.Request)
+	default:
+		pprof.Index(ctx.Writer, ctx.Request)
+	}
+}
@@ -1,15 +1,30 @@
 package handler
 
 import (
+	"for_avito_tech_with_gin/pkg/service"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
+	"math"
+	"strconv"
 )
 
 type errorResponse struct {
 	Message string `json:"message"`
 }
 
+type validationErrorResponse struct {
+	Message string       `json:"message"`
+	Errors  []fieldError `json:"errors"`
+}
+
 func newErrorResponse(ctx *gin.Context, statusCode int, message string) {
-	logrus.Error(message)
+	logger(ctx.Request.Context()).Error(message)
 	ctx.AbortWithStatusJSON(statusCode, errorResponse{message})
 }
+
+// newServiceErrorResponse ответ на ошибку сервиса, если запрос можно повторить - с заголовком Retry-After в секундах
+func newServiceErrorResponse(ctx *gin.Context, err service.ResponseError) {
+	if retryable, ok := err.(service.RetryableError); ok {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryable.RetryAfter().Seconds()))))
+	}
+	newErrorResponse(ctx, err.StatusCode(), err.Error())
+}
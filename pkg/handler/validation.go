@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// то же правило, что и в сервисе, чтобы кривой id отсекался еще до него
+	userIdRegexp   = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,64}$`)
+	currencyRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// validate проверяет DTO хендлеров по тегам binding, в ошибках поля называются так же, как в json
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	mustRegisterValidation(v, "decimals", validateDecimals)
+	mustRegisterValidation(v, "userid", func(fl validator.FieldLevel) bool {
+		return userIdRegexp.MatchString(fl.Field().String())
+	})
+	mustRegisterValidation(v, "currency", func(fl validator.FieldLevel) bool {
+		return currencyRegexp.MatchString(fl.Field().String())
+	})
+	return v
+}
+
+func mustRegisterValidation(v *validator.Validate, tag string, fn validator.Func) {
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		panic(err)
+	}
+}
+
+// validateDecimals decimals=N - у числа не больше N знаков после запятой
+func validateDecimals(fl validator.FieldLevel) bool {
+	places, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		return false
+	}
+	s := strconv.FormatFloat(fl.Field().Float(), 'f', -1, fl.Field().Type().Bits())
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s)-i-1 <= places
+	}
+	return true
+}
+
+// fieldError ошибка в одном поле запроса
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// bindJSON разбирает тело запроса в obj и проверяет его по тегам binding. Неизвестные поля и числа, которые
+// не влезают в тип поля - тоже ошибка. Если тело невалидно - отвечает 400 со списком ошибок и возвращает false
+func bindJSON(ctx *gin.Context, obj interface{}) bool {
+	return bindBody(ctx, obj, false)
+}
+
+// bindOptionalJSON как bindJSON, но пустое тело допустимо
+func bindOptionalJSON(ctx *gin.Context, obj interface{}) bool {
+	return bindBody(ctx, obj, true)
+}
+
+func bindBody(ctx *gin.Context, obj interface{}, optional bool) bool {
+	decoder := json.NewDecoder(ctx.Request.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(obj)
+	if err == io.EOF && optional {
+		err = nil
+	}
+	if err == nil && decoder.More() {
+		err = errors.New("extra data after json body")
+	}
+	if err != nil && isBodyTooLarge(err) {
+		newErrorResponse(ctx, http.StatusRequestEntityTooLarge, "request body too large.")
+		return false
+	}
+	if err != nil {
+		logger(ctx.Request.Context()).Error(err)
+		newValidationErrorResponse(ctx, "invalid body.", []fieldError{decodeFieldError(err)})
+		return false
+	}
+
+	return validateStruct(ctx, obj)
+}
+
+// validateStruct проверяет уже заполненную структуру, если она невалидна - отвечает 400 и возвращает false
+func validateStruct(ctx *gin.Context, obj interface{}) bool {
+	err := validate.Struct(obj)
+	if err == nil {
+		return true
+	}
+	logger(ctx.Request.Context()).Error(err)
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		newValidationErrorResponse(ctx, "invalid body.", []fieldError{{Field: "body", Message: "is invalid."}})
+		return false
+	}
+	errs := make([]fieldError, 0, len(validationErrors))
+	for _, e := range validationErrors {
+		errs = append(errs, fieldError{Field: e.Field(), Message: ruleMessage(e.Tag(), e.Param(), e.Kind())})
+	}
+	newValidationErrorResponse(ctx, "invalid body.", errs)
+	return false
+}
+
+// validateParam проверяет параметр из пути или query по правилам tag, если он невалиден - отвечает 400 и возвращает false
+func validateParam(ctx *gin.Context, field string, value string, tag string) bool {
+	err := validate.Var(value, tag)
+	if err == nil {
+		return true
+	}
+	logger(ctx.Request.Context()).Error(err)
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok || len(validationErrors) == 0 {
+		newValidationErrorResponse(ctx, "invalid params.", []fieldError{{Field: field, Message: "is invalid."}})
+		return false
+	}
+	e := validationErrors[0]
+	newValidationErrorResponse(ctx, "invalid params.", []fieldError{{Field: field, Message: ruleMessage(e.Tag(), e.Param(), e.Kind())}})
+	return false
+}
+
+// decodeFieldError переводит ошибку encoding/json в ошибку поля
+func decodeFieldError(err error) fieldError {
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case err == io.EOF:
+		return fieldError{Field: "body", Message: "is required."}
+	case errors.As(err, &syntaxError):
+		return fieldError{Field: "body", Message: fmt.Sprintf("is not valid json (offset %d).", syntaxError.Offset)}
+	case errors.As(err, &typeError):
+		// для ошибок из UnmarshalJSON (jsonUserId) encoding/json не заполняет Field
+		if typeError.Field == "" {
+			return fieldError{Field: "body", Message: typeMessage(typeError)}
+		}
+		return fieldError{Field: typeError.Field, Message: typeMessage(typeError)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, unquoteErr := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		if unquoteErr != nil {
+			field = strings.TrimPrefix(err.Error(), "json: unknown field ")
+		}
+		return fieldError{Field: field, Message: "is unknown."}
+	}
+	return fieldError{Field: "body", Message: "is invalid."}
+}
+
+func typeMessage(err *json.UnmarshalTypeError) string {
+	if err.Type == reflect.TypeOf(jsonUserId("")) {
+		return "user id must be a string or a positive integer."
+	}
+
+	isNumber := strings.HasPrefix(err.Value, "number")
+	switch err.Type.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if isNumber {
+			return "is out of range."
+		}
+		return "must be a number."
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if isNumber && !strings.ContainsAny(err.Value, ".eE") {
+			return "is out of range."
+		}
+		return "must be an integer."
+	case reflect.String:
+		return "must be a string."
+	case reflect.Bool:
+		return "must be a boolean."
+	}
+	return "has wrong type."
+}
+
+func ruleMessage(tag string, param string, kind reflect.Kind) string {
+	switch tag {
+	case "required":
+		return "is required."
+	case "gt":
+		return fmt.Sprintf("must be greater than %s.", param)
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s.", param)
+	case "max":
+		if kind == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long.", param)
+		}
+		return fmt.Sprintf("must be less than or equal to %s.", param)
+	case "decimals":
+		return fmt.Sprintf("must have at most %s decimal places.", param)
+	case "userid":
+		return "must be 1-64 latin letters, digits or _ - . : characters."
+	case "currency":
+		return "must be a 3-letter uppercase currency code."
+	}
+	return "is invalid."
+}
+
+// newValidationErrorResponse отвечает 400 со списком ошибок по полям, message для тела остается прежним "invalid body.",
+// чтобы не сломать старых клиентов
+func newValidationErrorResponse(ctx *gin.Context, message string, errs []fieldError) {
+	logger(ctx.Request.Context()).Error(message)
+	ctx.AbortWithStatusJSON(http.StatusBadRequest, validationErrorResponse{Message: message, Errors: errs})
+}
+
+// isBodyTooLarge ошибка http.MaxBytesReader из bodyLimitMiddleware, отдельного типа у нее пока нет
+func isBodyTooLarge(err error) bool {
+	return strings.Contains(err.Error(), "http: request body too large")
+}
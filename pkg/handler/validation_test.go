@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBindJSON(t *testing.T) {
+	type request struct {
+		UserId jsonUserId `json:"id" binding:"required,userid"`
+		Sum    float32    `json:"sum" binding:"required,gt=0,decimals=2"`
+		model.TransactionInfo
+	}
+
+	testData := []struct {
+		name                string
+		inputBody           string
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:                "OK",
+			inputBody:           `{"id":"user-1", "sum": 10.25, "comment": "ok"}`,
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:                "Empty Body",
+			inputBody:           ``,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"body","message":"is required."}]}`,
+		},
+		{
+			name:                "Not Json",
+			inputBody:           `{"id":`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"body","message":"is invalid."}]}`,
+		},
+		{
+			name:                "Extra Data",
+			inputBody:           `{"id":1, "sum": 1} {}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"body","message":"is invalid."}]}`,
+		},
+		{
+			name:                "Unknown Field",
+			inputBody:           `{"id":1, "sum": 1, "summ": 2}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"summ","message":"is unknown."}]}`,
+		},
+		{
+			name:                "Overflow",
+			inputBody:           `{"id":1, "sum": 1e40}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"is out of range."}]}`,
+		},
+		{
+			name:                "Id Overflow",
+			inputBody:           `{"id":99999999999999999999, "sum": 1}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"body","message":"user id must be a string or a positive integer."}]}`,
+		},
+		{
+			name:                "Too Many Decimals",
+			inputBody:           `{"id":1, "sum": 0.001}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"must have at most 2 decimal places."}]}`,
+		},
+		{
+			name:                "Bad Id",
+			inputBody:           `{"id":"user 1", "sum": 1}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"must be 1-64 latin letters, digits or _ - . : characters."}]}`,
+		},
+		{
+			name:               "Several Errors",
+			inputBody:          `{"sum": -1, "order_id": "` + string(bytes.Repeat([]byte("o"), 65)) + `"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"id","message":"is required."},` +
+				`{"field":"sum","message":"must be greater than 0."},{"field":"order_id","message":"must be at most 64 characters long."}]}`,
+		},
+		{
+			name:                "Wrong Type",
+			inputBody:           `{"id":1, "sum": "10"}`,
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid body.","errors":[{"field":"sum","message":"must be a number."}]}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// test server
+			r := gin.New()
+			r.POST("/bind", func(ctx *gin.Context) {
+				if !bindJSON(ctx, &request{}) {
+					return
+				}
+				ctx.Status(http.StatusOK)
+			})
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/bind", bytes.NewBufferString(testCase.inputBody))
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
+
+func TestValidateParam(t *testing.T) {
+	testData := []struct {
+		name                string
+		inputQueryParams    string
+		expectedStatusCode  int
+		expectedRequestBody string
+	}{
+		{
+			name:                "OK",
+			inputQueryParams:    "?currency=USD",
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:                "OK Empty",
+			inputQueryParams:    "",
+			expectedStatusCode:  http.StatusOK,
+			expectedRequestBody: "",
+		},
+		{
+			name:                "Lowercase",
+			inputQueryParams:    "?currency=usd",
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid params.","errors":[{"field":"currency","message":"must be a 3-letter uppercase currency code."}]}`,
+		},
+		{
+			name:                "Too Long",
+			inputQueryParams:    "?currency=USDT",
+			expectedStatusCode:  http.StatusBadRequest,
+			expectedRequestBody: `{"message":"invalid params.","errors":[{"field":"currency","message":"must be a 3-letter uppercase currency code."}]}`,
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// test server
+			r := gin.New()
+			r.GET("/param", func(ctx *gin.Context) {
+				if !validateParam(ctx, "currency", ctx.Query("currency"), "omitempty,currency") {
+					return
+				}
+				ctx.Status(http.StatusOK)
+			})
+
+			// test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/param"+testCase.inputQueryParams, nil)
+
+			// perform request
+			r.ServeHTTP(w, req)
+
+			// assert
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRequestBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package lifecycle
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// Component часть приложения, которая работает в фоне: http сервер, обновление котировок и т.д.
+type Component struct {
+	Name string
+	// Run работает, пока не отменен ctx. Если Run завершился раньше, останавливается все приложение
+	Run func(ctx context.Context) error
+	// Stop необязательная мягкая остановка после отмены ctx у Run, например http сервер дожидается запросов в работе.
+	// ctx отменяется, когда истекает время на остановку
+	Stop func(ctx context.Context) error
+}
+
+type Manager struct {
+	shutdownTimeout time.Duration
+	components      []Component
+}
+
+// NewManager shutdownTimeout - сколько ждать остановки всех компонентов
+func NewManager(shutdownTimeout time.Duration) *Manager {
+	return &Manager{shutdownTimeout: shutdownTimeout}
+}
+
+// Add компоненты запускаются в порядке добавления и останавливаются в обратном
+func (m *Manager) Add(component Component) {
+	m.components = append(m.components, component)
+}
+
+type running struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Run запускает компоненты и ждет отмены ctx (сигнала остановки) или падения одного из компонентов, после чего
+// останавливает их. Компоненты, которые не остановились за shutdownTimeout, бросаются. Возвращает ошибку упавшего
+// компонента или ошибку остановки
+func (m *Manager) Run(ctx context.Context) error {
+	failed := make(chan error, len(m.components))
+	started := make([]*running, 0, len(m.components))
+	for _, component := range m.components {
+		// контекст компонента не наследуется от ctx, чтобы компоненты останавливались по очереди
+		componentCtx, cancel := context.WithCancel(context.Background())
+		r := &running{Component: component, cancel: cancel, done: make(chan struct{})}
+		go r.run(componentCtx, failed)
+		started = append(started, r)
+		logger(component.Name).Info("started")
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		logrus.WithField(logging.PackageField, "lifecycle").Info("shutting down")
+	case err = <-failed:
+		logrus.WithField(logging.PackageField, "lifecycle").Error(errors.Wrap(err, "shutting down"))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+	for i := len(started) - 1; i >= 0; i-- {
+		if stopErr := started[i].stop(shutdownCtx); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+
+	return err
+}
+
+func (r *running) run(ctx context.Context, failed chan<- error) {
+	defer close(r.done)
+	defer func() {
+		if p := recover(); p != nil {
+			failed <- errors.Errorf("%s panicked: %v", r.Name, p)
+		}
+	}()
+
+	err := r.Run(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err == nil {
+		err = errors.New("stopped unexpectedly")
+	}
+	failed <- errors.Wrapf(err, "%s failed", r.Name)
+}
+
+func (r *running) stop(ctx context.Context) error {
+	log := logger(r.Name)
+	r.cancel()
+
+	var err error
+	if r.Stop != nil {
+		if err = r.Stop(ctx); err != nil {
+			err = errors.Wrapf(err, "filed to stop %s", r.Name)
+			log.Error(err)
+		}
+	}
+
+	select {
+	case <-r.done:
+		log.Info("stopped")
+	case <-ctx.Done():
+		log.Error("not stopped before shutdown deadline")
+		if err == nil {
+			err = errors.Errorf("%s not stopped before shutdown deadline", r.Name)
+		}
+	}
+
+	return err
+}
+
+func logger(component string) *logrus.Entry {
+	return logrus.WithFields(logrus.Fields{logging.PackageField: "lifecycle", "component": component})
+}
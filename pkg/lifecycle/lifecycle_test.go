@@ -0,0 +1,132 @@
+package lifecycle
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+// worker работает до отмены ctx
+func (r *recorder) worker(name string) Component {
+	return Component{Name: name, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		r.add("stop " + name)
+		return nil
+	}}
+}
+
+func TestManager_Run(t *testing.T) {
+	testData := []struct {
+		name           string
+		components     func(r *recorder) []Component
+		cancel         bool
+		expectedEvents []string
+		expectedError  string
+	}{
+		{
+			name: "Stop In Reverse Order",
+			components: func(r *recorder) []Component {
+				return []Component{r.worker("a"), r.worker("b")}
+			},
+			cancel:         true,
+			expectedEvents: []string{"stop b", "stop a"},
+		},
+		{
+			name: "Component Failed",
+			components: func(r *recorder) []Component {
+				return []Component{r.worker("a"), {Name: "b", Run: func(ctx context.Context) error {
+					time.Sleep(10 * time.Millisecond)
+					return errors.Errorf("lol kek cheburek.")
+				}}}
+			},
+			expectedEvents: []string{"stop a"},
+			expectedError:  "b failed: lol kek cheburek.",
+		},
+		{
+			name: "Component Panicked",
+			components: func(r *recorder) []Component {
+				return []Component{r.worker("a"), {Name: "b", Run: func(ctx context.Context) error {
+					time.Sleep(10 * time.Millisecond)
+					panic("lol kek cheburek.")
+				}}}
+			},
+			expectedEvents: []string{"stop a"},
+			expectedError:  "b panicked: lol kek cheburek.",
+		},
+		{
+			name: "Graceful Stop",
+			components: func(r *recorder) []Component {
+				stopped := make(chan struct{})
+				return []Component{{
+					Name: "a",
+					Run: func(ctx context.Context) error {
+						<-stopped
+						return nil
+					},
+					Stop: func(ctx context.Context) error {
+						r.add("stop a")
+						close(stopped)
+						return nil
+					},
+				}}
+			},
+			cancel:         true,
+			expectedEvents: []string{"stop a"},
+		},
+		{
+			name: "Shutdown Deadline",
+			components: func(r *recorder) []Component {
+				return []Component{{Name: "b", Run: func(ctx context.Context) error {
+					select {}
+				}}, r.worker("a")}
+			},
+			cancel:         true,
+			expectedEvents: []string{"stop a"},
+			expectedError:  "b not stopped before shutdown deadline",
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &recorder{}
+			manager := NewManager(100 * time.Millisecond)
+			for _, component := range testCase.components(r) {
+				manager.Add(component)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if testCase.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			err := manager.Run(ctx)
+
+			// assert
+			if testCase.expectedError != "" {
+				assert.EqualError(t, err, testCase.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			assert.Equal(t, testCase.expectedEvents, r.events)
+		})
+	}
+}
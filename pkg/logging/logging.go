@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey struct{}
+
+const (
+	// RequestIdField поле, в котором id запроса попадает во все логи, сделанные в рамках запроса
+	RequestIdField = "request_id"
+	// PackageField поле с именем пакета, по нему выбирается уровень логов из log.packages
+	PackageField = "package"
+)
+
+// WithRequestId кладет id запроса в контекст, дальше он передается в сервисы и репозитории вместе с контекстом
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestId)
+}
+
+// RequestId достает id запроса из контекста, пустая строка - контекст пришел не из http запроса
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(contextKey{}).(string)
+	return requestId
+}
+
+// FromContext логгер с id запроса и id трейса из контекста, если их там нет - обычный логгер без дополнительных полей
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if requestId := RequestId(ctx); requestId != "" {
+		entry = entry.WithField(RequestIdField, requestId)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		entry = entry.WithFields(logrus.Fields{
+			"trace_id": spanContext.TraceID().String(),
+			"span_id":  spanContext.SpanID().String(),
+		})
+	}
+
+	return entry
+}
+
+// Package логгер пакета name с полями из контекста
+func Package(ctx context.Context, name string) *logrus.Entry {
+	return FromContext(ctx).WithField(PackageField, name)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Действия админов и сверки в журнале аудита, операции с деньгами пишутся с типом операции (add_funds, reversal, ...)
+const (
+	AuditCorrectBalance = "correct_balance" // сверка исправила users.balance по журналу
+	AuditSetCreditLimit = "set_credit_limit"
+	AuditSetLimits      = "set_limits"
+	AuditSetStatus      = "set_status"
+)
+
+// Проблемы, которые находит проверка журнала аудита
+const (
+	AuditEntryModified    = "entry modified"                     // хэш записи не сходится с ее полями
+	AuditChainBroken      = "previous entry deleted or modified" // prev_hash не сходится с хэшем предыдущей записи
+	AuditLastHashNotFound = "entries after last known hash deleted"
+)
+
+// AuditEntry запись журнала аудита. Hash - sha256 от остальных полей и PrevHash, хэша предыдущей записи
+type AuditEntry struct {
+	Id            int64           `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	RequestId     string          `json:"request_id,omitempty"`
+	Actor         string          `json:"actor"`
+	Action        string          `json:"action"`
+	UserIds       []string        `json:"user_ids"`
+	TransactionId *int            `json:"transaction_id,omitempty"`
+	Details       json.RawMessage `json:"details" swaggertype:"object"`
+	PrevHash      string          `json:"prev_hash"`
+	Hash          string          `json:"hash"`
+}
+
+// ComputeHash считает хэш записи. Время берется в UTC с точностью до микросекунд, как оно хранится в бд
+func (e *AuditEntry) ComputeHash() string {
+	userIds := e.UserIds
+	if userIds == nil {
+		userIds = []string{}
+	}
+	details := e.Details
+	if len(details) == 0 {
+		details = json.RawMessage("{}")
+	}
+
+	// details хэшируется строкой, а не json: так байты не зависят от того, как json перепишет маршалинг
+	data, _ := json.Marshal(struct {
+		Id            int64    `json:"id"`
+		CreatedAt     string   `json:"created_at"`
+		RequestId     string   `json:"request_id"`
+		Actor         string   `json:"actor"`
+		Action        string   `json:"action"`
+		UserIds       []string `json:"user_ids"`
+		TransactionId *int     `json:"transaction_id"`
+		Details       string   `json:"details"`
+		PrevHash      string   `json:"prev_hash"`
+	}{
+		Id:            e.Id,
+		CreatedAt:     e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		RequestId:     e.RequestId,
+		Actor:         e.Actor,
+		Action:        e.Action,
+		UserIds:       userIds,
+		TransactionId: e.TransactionId,
+		Details:       string(details),
+		PrevHash:      e.PrevHash,
+	})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter фильтр журнала аудита, пустые поля не фильтруют. Записи идут по возрастанию id, следующая страница - с AfterId
+type AuditFilter struct {
+	Actor   string
+	UserId  string
+	From    *time.Time // включительно
+	To      *time.Time // не включительно
+	AfterId int64
+	Limit   int
+}
+
+// AuditProblem запись журнала аудита, которая не прошла проверку
+type AuditProblem struct {
+	Id      int64  `json:"id"`
+	Problem string `json:"problem"`
+}
+
+// AuditVerification результат проверки цепочки хэшей журнала аудита
+type AuditVerification struct {
+	Entries  int            `json:"entries"`
+	LastId   int64          `json:"last_id"`
+	LastHash string         `json:"last_hash"` // сохранить, чтобы следующая проверка нашла удаление записей с конца
+	Problems []AuditProblem `json:"problems"`
+	Valid    bool           `json:"valid"`
+}
@@ -0,0 +1,124 @@
+package model
+
+import "math"
+
+// Счета двойной записи: кошельки юзеров и системные счета. Деньги не появляются и не исчезают - каждая операция
+// переносит их со счета на счет, поэтому проводки операции, как и все проводки журнала, в сумме дают 0
+const (
+	AccountUser    = "user"    // кошелек юзера, его баланс - users.balance
+	AccountCashIn  = "cash_in" // откуда приходят начисления
+	AccountRevenue = "revenue" // куда уходят списания за услуги
+	AccountFees    = "fees"    // комиссии
+)
+
+// LedgerPrecision расхождение, до которого суммы во float считаются равными
+const LedgerPrecision = 0.005
+
+// Posting проводка: изменение одного счета в рамках операции
+type Posting struct {
+	Account string  `json:"account"`
+	UserId  *string `json:"user_id,omitempty"` // для кошелька юзера
+	Amount  float32 `json:"amount"`            // плюс - на счет, минус - со счета
+}
+
+// GetFields чтобы передавать в sql.Scan() все поля структуры Posting
+func (r *Posting) GetFields() []interface{} {
+	return []interface{}{&r.Account, &r.UserId, &r.Amount}
+}
+
+// JournalEntry запись журнала: операция и ее проводки
+type JournalEntry struct {
+	Type string
+	TransactionInfo
+	ReversedId *int // для reversal - id отменяемой операции
+	Postings   []Posting
+}
+
+// NewCredit начисление: cash_in -> кошелек юзера
+func NewCredit(userId string, sum float32, info TransactionInfo) JournalEntry {
+	return JournalEntry{Type: TransactionAddFunds, TransactionInfo: info, Postings: []Posting{
+		{Account: AccountCashIn, Amount: -sum},
+		{Account: AccountUser, UserId: &userId, Amount: sum},
+	}}
+}
+
+// NewWriteOff списание: кошелек юзера -> revenue
+func NewWriteOff(userId string, sum float32, info TransactionInfo) JournalEntry {
+	return JournalEntry{Type: TransactionWriteOffFunds, TransactionInfo: info, Postings: []Posting{
+		{Account: AccountUser, UserId: &userId, Amount: -sum},
+		{Account: AccountRevenue, Amount: sum},
+	}}
+}
+
+// NewTransfer перевод: кошелек отправителя -> кошелек получателя
+func NewTransfer(senderId string, receiverId string, sum float32, info TransactionInfo) JournalEntry {
+	return JournalEntry{Type: TransactionFundsTransfer, TransactionInfo: info, Postings: []Posting{
+		{Account: AccountUser, UserId: &senderId, Amount: -sum},
+		{Account: AccountUser, UserId: &receiverId, Amount: sum},
+	}}
+}
+
+// NewReversal возврат sum из операции original с проводками postings: те же счета, но в обратную сторону,
+// при частичном возврате - пропорционально
+func NewReversal(original Transaction, postings []Posting, sum float32, info TransactionInfo) JournalEntry {
+	entry := JournalEntry{Type: TransactionReversal, TransactionInfo: info, ReversedId: &original.Id}
+	for _, p := range postings {
+		p.Amount = -p.Amount / original.Sum * sum
+		entry.Postings = append(entry.Postings, p)
+	}
+	return entry
+}
+
+// Balanced проводки сходятся в 0
+func (e JournalEntry) Balanced() bool {
+	var total float64
+	for _, p := range e.Postings {
+		total += float64(p.Amount)
+	}
+	return len(e.Postings) > 0 && math.Abs(total) <= LedgerPrecision
+}
+
+// Sum сумма операции - сколько денег пришло на счета
+func (e JournalEntry) Sum() float32 {
+	var sum float32
+	for _, p := range e.Postings {
+		if p.Amount > 0 {
+			sum += p.Amount
+		}
+	}
+	return sum
+}
+
+// Sender юзер, с кошелька которого уходят деньги, nil - деньги уходят с системного счета
+func (e JournalEntry) Sender() *string {
+	for _, p := range e.Postings {
+		if p.Account == AccountUser && p.Amount < 0 {
+			return p.UserId
+		}
+	}
+	return nil
+}
+
+// Receiver юзер, на кошелек которого приходят деньги, nil - деньги приходят на системный счет
+func (e JournalEntry) Receiver() *string {
+	for _, p := range e.Postings {
+		if p.Account == AccountUser && p.Amount > 0 {
+			return p.UserId
+		}
+	}
+	return nil
+}
+
+// AccountBalance баланс счета по проводкам, для user - сумма всех кошельков
+type AccountBalance struct {
+	Account string  `json:"account"`
+	Balance float32 `json:"balance"`
+}
+
+// Ledger сводка по журналу
+type Ledger struct {
+	Accounts               []AccountBalance `json:"accounts"`
+	Total                  float32          `json:"total"`                   // сумма всех проводок, у сведенного журнала 0
+	UnbalancedTransactions []int            `json:"unbalanced_transactions"` // операции, проводки которых не сходятся в 0
+	Balanced               bool             `json:"balanced"`
+}
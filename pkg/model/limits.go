@@ -0,0 +1,39 @@
+package model
+
+// Limits лимиты юзера на списания и исходящие переводы, nil - лимита нет
+type Limits struct {
+	MaxTransactionSum *float32 `json:"max_transaction_sum" db:"max_transaction_sum" binding:"omitempty,gte=0,decimals=2"`
+	DailyDebit        *float32 `json:"daily_debit" db:"daily_debit" binding:"omitempty,gte=0,decimals=2"`
+	MonthlyDebit      *float32 `json:"monthly_debit" db:"monthly_debit" binding:"omitempty,gte=0,decimals=2"`
+	DailyTransfer     *float32 `json:"daily_transfer" db:"daily_transfer" binding:"omitempty,gte=0,decimals=2"`
+	MonthlyTransfer   *float32 `json:"monthly_transfer" db:"monthly_transfer" binding:"omitempty,gte=0,decimals=2"`
+	HourlyTransfers   *int     `json:"hourly_transfers" db:"hourly_transfers" binding:"omitempty,gte=0"`
+}
+
+// GetFields чтобы передавать в sql.Scan() все поля структуры Limits
+func (r *Limits) GetFields() []interface{} {
+	return []interface{}{&r.MaxTransactionSum, &r.DailyDebit, &r.MonthlyDebit, &r.DailyTransfer, &r.MonthlyTransfer, &r.HourlyTransfers}
+}
+
+// Merge возвращает лимиты, в которых незаданные у юзера значения взяты из defaults
+func (r Limits) Merge(defaults Limits) Limits {
+	if r.MaxTransactionSum == nil {
+		r.MaxTransactionSum = defaults.MaxTransactionSum
+	}
+	if r.DailyDebit == nil {
+		r.DailyDebit = defaults.DailyDebit
+	}
+	if r.MonthlyDebit == nil {
+		r.MonthlyDebit = defaults.MonthlyDebit
+	}
+	if r.DailyTransfer == nil {
+		r.DailyTransfer = defaults.DailyTransfer
+	}
+	if r.MonthlyTransfer == nil {
+		r.MonthlyTransfer = defaults.MonthlyTransfer
+	}
+	if r.HourlyTransfers == nil {
+		r.HourlyTransfers = defaults.HourlyTransfers
+	}
+	return r
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Discrepancy расхождение кэша баланса юзера с суммой проводок по его кошельку
+type Discrepancy struct {
+	UserId        string  `json:"user_id"`
+	Balance       float32 `json:"balance"`                 // users.balance
+	LedgerBalance float32 `json:"ledger_balance"`          // сумма проводок по кошельку
+	Difference    float32 `json:"difference"`              // balance - ledger_balance
+	CorrectionId  *int    `json:"correction_id,omitempty"` // запись balance_corrections, которой users.balance исправлен по журналу
+}
+
+// Reconciliation результат сверки балансов с журналом
+type Reconciliation struct {
+	StartedAt              time.Time     `json:"started_at"`
+	FinishedAt             time.Time     `json:"finished_at"`
+	Discrepancies          []Discrepancy `json:"discrepancies"`
+	UnbalancedTransactions []int         `json:"unbalanced_transactions"` // операции, проводки которых не сходятся в 0, сверка их не исправляет
+	Repaired               bool          `json:"repaired"`                // users.balance исправлен по журналу
+}
+
+// Consistent балансы сходятся с журналом или все расхождения исправлены, и журнал сведен
+func (r *Reconciliation) Consistent() bool {
+	if len(r.UnbalancedTransactions) > 0 {
+		return false
+	}
+	for _, d := range r.Discrepancies {
+		if d.CorrectionId == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// Типы операций, которые пишутся в историю
+const (
+	TransactionAddFunds       = "add_funds"
+	TransactionWriteOffFunds  = "write_off_funds"
+	TransactionFundsTransfer  = "funds_transfer"
+	TransactionReversal       = "reversal"
+	TransactionOpeningBalance = "opening_balance" // остаток, который был у юзера до перехода на двойную запись
+)
+
+// TransactionInfo необязательные поля операции, по ним потом можно понять за что было списание
+type TransactionInfo struct {
+	OrderId   string `json:"order_id,omitempty" db:"order_id" binding:"max=64"`
+	ServiceId string `json:"service_id,omitempty" db:"service_id" binding:"max=64"`
+	Comment   string `json:"comment,omitempty" db:"comment" binding:"max=255"`
+	Source    string `json:"source,omitempty" db:"source" binding:"max=64"`
+}
+
+type Transaction struct {
+	Id         int     `json:"id" db:"id"`
+	Type       string  `json:"type" db:"type"`
+	SenderId   *string `json:"sender_id,omitempty" db:"sender_id"`
+	ReceiverId *string `json:"receiver_id,omitempty" db:"receiver_id"`
+	Sum        float32 `json:"sum" db:"sum"`
+	TransactionInfo
+	ReversedId *int      `json:"reversed_id,omitempty" db:"reversed_id"` // для reversal - id отменяемой операции
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// GetFields чтобы передавать в sql.Scan() все поля структуры Transaction
+func (r *Transaction) GetFields() []interface{} {
+	return []interface{}{&r.Id, &r.Type, &r.SenderId, &r.ReceiverId, &r.Sum,
+		&r.OrderId, &r.ServiceId, &r.Comment, &r.Source, &r.ReversedId, &r.CreatedAt}
+}
+
+// OperationResult результат начисления, списания или перевода: созданная операция и балансы после нее,
+// чтобы клиенту не нужно было отдельно запрашивать баланс
+type OperationResult struct {
+	Transaction     *Transaction `json:"transaction"`
+	Balance         *float32     `json:"balance,omitempty"`          // для начисления и списания
+	SenderBalance   *float32     `json:"sender_balance,omitempty"`   // для перевода
+	ReceiverBalance *float32     `json:"receiver_balance,omitempty"` // для перевода
+}
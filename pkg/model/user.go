@@ -1,12 +1,32 @@
 package model
 
+import "time"
+
+const (
+	UserStatusActive = "active"
+	UserStatusFrozen = "frozen" // нельзя списывать и переводить, начисления - по настройке balance.allow_frozen_credits
+	UserStatusClosed = "closed" // нельзя ничего, вернуть в active нельзя
+)
+
 type User struct {
-	Id      int     `db:"id"`
-	UserId  int     `json:"id" db:"user_id"` // TODO: сделать UserId строкой
-	Balance float32 `json:"balance" db:"balance"`
+	Id          int       `json:"-" db:"id"`
+	UserId      string    `json:"id" db:"user_id"` // строка - id может быть UUID или любым внешним id
+	Balance     float32   `json:"balance" db:"balance"`
+	CreditLimit float32   `json:"credit_limit" db:"credit_limit"` // лимит юзера, а если он не задан - лимит по умолчанию
+	Status      string    `json:"status" db:"status"`
+	ExternalRef string    `json:"external_ref,omitempty" db:"external_ref"` // id юзера во внешней системе
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // GetFields чтобы передавать в sql.Scan() все поля структуры User
 func (r *User) GetFields() []interface{} {
-	return []interface{}{&r.Id, &r.UserId, &r.Balance}
+	return []interface{}{&r.Id, &r.UserId, &r.Balance, &r.CreditLimit, &r.Status, &r.ExternalRef, &r.CreatedAt, &r.UpdatedAt}
+}
+
+// Balance баланс юзера вместе с тем, сколько он еще может потратить с учетом кредитного лимита
+type Balance struct {
+	Balance     float32 `json:"balance"`
+	CreditLimit float32 `json:"credit_limit"`
+	Available   float32 `json:"available"`
 }
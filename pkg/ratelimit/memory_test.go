@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Allow(t *testing.T) {
+	now := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	limit := Limit{Rate: 2, Burst: 3}
+
+	type request struct {
+		key      string
+		after    time.Duration
+		expected Decision
+	}
+
+	testData := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "Burst",
+			requests: []request{
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{RetryAfter: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "Refill",
+			requests: []request{
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", after: 250 * time.Millisecond, expected: Decision{RetryAfter: 250 * time.Millisecond}},
+				{key: "a", after: 250 * time.Millisecond, expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{RetryAfter: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "Refill Up To Burst",
+			requests: []request{
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", after: time.Hour, expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{RetryAfter: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "Separate Keys",
+			requests: []request{
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{Allowed: true}},
+				{key: "b", expected: Decision{Allowed: true}},
+				{key: "a", expected: Decision{RetryAfter: 500 * time.Millisecond}},
+			},
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			current := now
+			store := NewMemoryStore()
+			store.now = func() time.Time { return current }
+
+			for _, r := range testCase.requests {
+				current = current.Add(r.after)
+				decision, err := store.Allow(context.Background(), r.key, limit)
+
+				// assert
+				assert.NoError(t, err)
+				assert.Equal(t, r.expected, decision)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_sweep(t *testing.T) {
+	current := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return current }
+	store.lastSweep = current
+
+	_, _ = store.Allow(context.Background(), "full soon", Limit{Rate: 1, Burst: 1})
+	_, _ = store.Allow(context.Background(), "full later", Limit{Rate: 0.001, Burst: 1})
+	assert.Len(t, store.buckets, 2)
+
+	current = current.Add(sweepInterval)
+	_, _ = store.Allow(context.Background(), "new", Limit{Rate: 1, Burst: 1})
+
+	// assert
+	assert.Len(t, store.buckets, 2)
+	assert.Contains(t, store.buckets, "full later")
+	assert.Contains(t, store.buckets, "new")
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit token bucket: в ведре до Burst запросов, ведро пополняется на Rate запросов в секунду. Rate = 0 - лимита нет
+type Limit struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+func (l Limit) enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// RouteConfig лимиты одного маршрута, Route - метод и путь как в роутере, например "POST /api/v1/funds_transfer"
+type RouteConfig struct {
+	Route  string `mapstructure:"route"`
+	Client Limit  `mapstructure:"client"` // на сертификат или ip клиента
+	User   Limit  `mapstructure:"user"`   // на каждого юзера, которого касается запрос
+}
+
+// Decision ответ хранилища: можно ли выполнить запрос, и если нет - через сколько можно повторить
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+// Store хранилище ведер. Allow забирает из ведра key один запрос, если он там есть
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+type Limiter struct {
+	store Store
+
+	mu     sync.RWMutex
+	routes map[string]RouteConfig
+}
+
+// NewLimiter лимиты применяются только к маршрутам из routes
+func NewLimiter(store Store, routes []RouteConfig) *Limiter {
+	l := &Limiter{store: store}
+	l.SetRoutes(routes)
+
+	return l
+}
+
+// SetRoutes заменяет лимиты маршрутов на лету. Ведра в хранилище не сбрасываются, новые rate и burst
+// применяются к ним со следующего запроса
+func (l *Limiter) SetRoutes(routes []RouteConfig) {
+	m := make(map[string]RouteConfig, len(routes))
+	for _, route := range routes {
+		m[routeKey(route.Route)] = route
+	}
+
+	l.mu.Lock()
+	l.routes = m
+	l.mu.Unlock()
+}
+
+func (l *Limiter) route(route string) (RouteConfig, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	config, ok := l.routes[routeKey(route)]
+	return config, ok
+}
+
+// AllowClient проверяет лимит маршрута route (метод и путь) для клиента client
+func (l *Limiter) AllowClient(ctx context.Context, route string, client string) (Decision, error) {
+	config, ok := l.route(route)
+	if !ok || !config.Client.enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	return l.store.Allow(ctx, "client:"+config.Route+":"+client, config.Client)
+}
+
+// AllowUser проверяет лимит маршрута route (метод и путь) для юзера userId
+func (l *Limiter) AllowUser(ctx context.Context, route string, userId string) (Decision, error) {
+	config, ok := l.route(route)
+	if !ok || !config.User.enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	return l.store.Allow(ctx, "user:"+config.Route+":"+userId, config.User)
+}
+
+// routeKey viper приводит ключи к нижнему регистру, поэтому маршруты сравниваются без учета регистра
+func routeKey(route string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(route), " "))
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type recordingStore struct {
+	keys []string
+}
+
+func (s *recordingStore) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	s.keys = append(s.keys, key)
+	return Decision{Allowed: false}, nil
+}
+
+func TestLimiter(t *testing.T) {
+	routes := []RouteConfig{
+		{Route: "post /api/v1/funds_transfer", Client: Limit{Rate: 1, Burst: 1}, User: Limit{Rate: 1, Burst: 1}},
+		{Route: "POST /api/v2/transfers", Client: Limit{Rate: 1, Burst: 1}},
+	}
+
+	testData := []struct {
+		name         string
+		allow        func(l *Limiter) (Decision, error)
+		expectedKeys []string
+		allowed      bool
+	}{
+		{
+			name: "Client",
+			allow: func(l *Limiter) (Decision, error) {
+				return l.AllowClient(context.Background(), "POST /api/v1/funds_transfer", "ip:10.0.0.1")
+			},
+			expectedKeys: []string{"client:post /api/v1/funds_transfer:ip:10.0.0.1"},
+		},
+		{
+			name: "User",
+			allow: func(l *Limiter) (Decision, error) {
+				return l.AllowUser(context.Background(), "POST /api/v1/funds_transfer", "17")
+			},
+			expectedKeys: []string{"user:post /api/v1/funds_transfer:17"},
+		},
+		{
+			name: "No User Limit",
+			allow: func(l *Limiter) (Decision, error) {
+				return l.AllowUser(context.Background(), "POST /api/v2/transfers", "17")
+			},
+			allowed: true,
+		},
+		{
+			name: "Unknown Route",
+			allow: func(l *Limiter) (Decision, error) {
+				return l.AllowClient(context.Background(), "GET /api/v1/get_balance", "ip:10.0.0.1")
+			},
+			allowed: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := &recordingStore{}
+			limiter := NewLimiter(store, routes)
+
+			decision, err := testCase.allow(limiter)
+
+			// assert
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.allowed, decision.Allowed)
+			assert.Equal(t, testCase.expectedKeys, store.keys)
+		})
+	}
+}
+
+func TestLimiter_SetRoutes(t *testing.T) {
+	store := &recordingStore{}
+	limiter := NewLimiter(store, nil)
+
+	decision, err := limiter.AllowClient(context.Background(), "POST /api/v2/transfers", "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	limiter.SetRoutes([]RouteConfig{{Route: "POST /api/v2/transfers", Client: Limit{Rate: 1, Burst: 1}}})
+	decision, err = limiter.AllowClient(context.Background(), "POST /api/v2/transfers", "ip:10.0.0.1")
+
+	// assert
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, []string{"client:POST /api/v2/transfers:ip:10.0.0.1"}, store.keys)
+}
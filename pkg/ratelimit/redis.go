@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// RedisClient то, что RedisStore нужно от клиента redis (или совместимого хранилища, например KeyDB): выполнить lua скрипт.
+// Для go-redis это обертка над client.Eval(ctx, script, keys, args...).Result()
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// tokenBucketScript то же ведро, что и в MemoryStore, но атомарно на стороне redis и по часам redis,
+// поэтому лимит общий для всех инстансов сервиса. Ведро живет, пока не наполнится
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) + tonumber(clock[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+if tokens == nil then
+  tokens = burst
+else
+  tokens = math.min(burst, tokens + (now - tonumber(state[2])) * rate)
+end
+
+local allowed = 0
+local retry_after = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry_after = math.ceil((1 - tokens) / rate * 1000)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1)
+return {allowed, retry_after}
+`
+
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisStore prefix - префикс ключей, чтобы не пересечься с другими данными в том же redis
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Decision, error) {
+	result, err := s.client.Eval(ctx, tokenBucketScript, []string{s.prefix + key}, limit.Rate, limit.Burst)
+	if err != nil {
+		return Decision{}, errors.Wrapf(err, "filed to take token for %s", key)
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return Decision{}, errors.Errorf("unexpected token bucket result %v for %s", result, key)
+	}
+	allowed, ok1 := values[0].(int64)
+	retryAfter, ok2 := values[1].(int64)
+	if !ok1 || !ok2 {
+		return Decision{}, errors.Errorf("unexpected token bucket result %v for %s", result, key)
+	}
+
+	return Decision{Allowed: allowed == 1, RetryAfter: time.Duration(retryAfter) * time.Millisecond}, nil
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fakeRedisClient struct {
+	keys   []string
+	args   []interface{}
+	result interface{}
+	err    error
+}
+
+func (c *fakeRedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	c.keys, c.args = keys, args
+	return c.result, c.err
+}
+
+func TestRedisStore_Allow(t *testing.T) {
+	testData := []struct {
+		name             string
+		result           interface{}
+		err              error
+		expectedDecision Decision
+		wantError        bool
+	}{
+		{
+			name:             "Allowed",
+			result:           []interface{}{int64(1), int64(0)},
+			expectedDecision: Decision{Allowed: true},
+		},
+		{
+			name:             "Limited",
+			result:           []interface{}{int64(0), int64(1500)},
+			expectedDecision: Decision{RetryAfter: 1500 * time.Millisecond},
+		},
+		{
+			name:      "Error",
+			err:       errors.Errorf("lol kek cheburek."),
+			wantError: true,
+		},
+		{
+			name:      "Unexpected Result",
+			result:    "OK",
+			wantError: true,
+		},
+		{
+			name:      "Unexpected Values",
+			result:    []interface{}{"1", int64(0)},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &fakeRedisClient{result: testCase.result, err: testCase.err}
+			store := NewRedisStore(client, "balance:")
+
+			decision, err := store.Allow(context.Background(), "user:POST /transfers:17", Limit{Rate: 0.5, Burst: 2})
+
+			// assert
+			assert.Equal(t, []string{"balance:user:POST /transfers:17"}, client.keys)
+			assert.Equal(t, []interface{}{0.5, 2}, client.args)
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedDecision, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"for_avito_tech_with_gin/pkg/logging"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Ledger сверка балансов с журналом, ее делает service.Ledger
+type Ledger interface {
+	Reconcile(ctx context.Context, repair bool) (*model.Reconciliation, error)
+}
+
+// ReconcilerConfig сверка по расписанию
+type ReconcilerConfig struct {
+	Interval time.Duration // как часто сверять, 0 - не сверять
+	Repair   bool          // исправлять users.balance по журналу
+	Output   string        // файл, куда пишется отчет последней сверки в json, пусто - только лог
+}
+
+// Reconciler сверяет балансы с журналом раз в interval, первый раз - через interval после запуска
+type Reconciler struct {
+	ledger Ledger
+	c      ReconcilerConfig
+}
+
+func NewReconciler(ledger Ledger, c ReconcilerConfig) *Reconciler {
+	return &Reconciler{ledger: ledger, c: c}
+}
+
+// Run блокируется, пока не отменен ctx
+func (r *Reconciler) Run(ctx context.Context) error {
+	if r.c.Interval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(r.c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.Reconcile(ctx); err != nil {
+				logging.Package(ctx, "reconciler").Error(err)
+			}
+		}
+	}
+}
+
+// Reconcile одна сверка, отчет пишется в Output
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	reconciliation, err := r.ledger.Reconcile(ctx, r.c.Repair)
+	if err != nil {
+		return errors.Wrap(err, "filed to reconcile")
+	}
+	logging.Package(ctx, "reconciler").WithField("discrepancies", len(reconciliation.Discrepancies)).
+		WithField("unbalanced_transactions", len(reconciliation.UnbalancedTransactions)).Info("reconciliation finished")
+
+	if r.c.Output == "" {
+		return nil
+	}
+	return WriteReconciliationFile(r.c.Output, reconciliation)
+}
+
+// WriteReconciliation отчет сверки в json
+func WriteReconciliation(w io.Writer, reconciliation *model.Reconciliation) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(reconciliation)
+}
+
+// WriteReconciliationFile пишет отчет во временный файл рядом с path и переименовывает его,
+// поэтому в path всегда лежит целый отчет
+func WriteReconciliationFile(path string, reconciliation *model.Reconciliation) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "filed to create reconciliation report")
+	}
+	defer os.Remove(file.Name())
+
+	if err := WriteReconciliation(file, reconciliation); err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "filed to write reconciliation report")
+	}
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "filed to write reconciliation report")
+	}
+	return errors.Wrap(os.Rename(file.Name(), path), "filed to write reconciliation report")
+}
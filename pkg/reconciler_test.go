@@ -0,0 +1,87 @@
+package pkg_test
+
+import (
+	"context"
+	"encoding/json"
+	"for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/service"
+	mock_service "for_avito_tech_with_gin/pkg/service/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconciler_Reconcile(t *testing.T) {
+	correctionId := 12
+	reconciliation := &model.Reconciliation{
+		StartedAt:  time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC),
+		FinishedAt: time.Date(2022, 1, 25, 10, 30, 1, 0, time.UTC),
+		Discrepancies: []model.Discrepancy{
+			{UserId: "71", Balance: 120, LedgerBalance: 100, Difference: 20, CorrectionId: &correctionId},
+		},
+		UnbalancedTransactions: []int{},
+		Repaired:               true,
+	}
+
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	ledger := mock_service.NewMockLedger(c)
+	ledger.EXPECT().Reconcile(gomock.Any(), true).Return(reconciliation, nil)
+	ledger.EXPECT().Reconcile(gomock.Any(), true).Return(nil, &service.InternalServerError{})
+
+	output := filepath.Join(t.TempDir(), "reconciliation.json")
+	reconciler := pkg.NewReconciler(ledger, pkg.ReconcilerConfig{Interval: time.Hour, Repair: true, Output: output})
+
+	// test
+	err := reconciler.Reconcile(context.Background())
+
+	// assert
+	assert.NoError(t, err)
+	report, err := os.ReadFile(output)
+	if assert.NoError(t, err) {
+		var written model.Reconciliation
+		assert.NoError(t, json.Unmarshal(report, &written))
+		assert.Equal(t, *reconciliation, written)
+	}
+
+	// отчет неудачной сверки не затирает прежний
+	assert.Error(t, reconciler.Reconcile(context.Background()))
+	again, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, report, again)
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(output), "*"))
+	assert.Len(t, files, 1)
+}
+
+func TestReconciler_Run(t *testing.T) {
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ledger := mock_service.NewMockLedger(c)
+	ledger.EXPECT().Reconcile(gomock.Any(), false).DoAndReturn(func(context.Context, bool) (*model.Reconciliation, error) {
+		cancel()
+		return &model.Reconciliation{}, nil
+	})
+
+	// test
+	done := make(chan error)
+	go func() {
+		done <- pkg.NewReconciler(ledger, pkg.ReconcilerConfig{Interval: 10 * time.Millisecond}).Run(ctx)
+	}()
+
+	// assert
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reconciler did not stop")
+	}
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type AuditRepository struct {
+	db    Querier
+	retry RetryConfig
+}
+
+func NewAuditRepository(db Querier) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// WithRetry повторы AppendAudit при ошибках сериализации и дедлоках
+func (r *AuditRepository) WithRetry(c RetryConfig) *AuditRepository {
+	r.retry = c
+	return r
+}
+
+// AppendAudit добавляет запись в конец журнала аудита: id, время, prev_hash и hash заполняются здесь. Хэш последней
+// записи хранится в единственной строке audit_head, и записи добавляются под ее блокировкой, иначе две параллельные
+// записи сошлись бы на одном prev_hash. Если голову уже сдвинула запись, закоммиченная после снимка транзакции
+// (repeatable read), postgres откатывает транзакцию с ошибкой сериализации, и ее повторяют целиком. Внутри unit of work
+// блокировка держится до конца его транзакции
+func (r *AuditRepository) AppendAudit(ctx context.Context, entry model.AuditEntry) (*model.AuditEntry, error) {
+	if entry.UserIds == nil {
+		entry.UserIds = []string{}
+	}
+	if len(entry.Details) == 0 {
+		entry.Details = json.RawMessage("{}")
+	}
+
+	err := inTx(ctx, r.db, r.retry, func(tx Querier) error {
+		err := tx.QueryRowContext(ctx, "select hash from audit_head for update;").Scan(&entry.PrevHash)
+		if err != nil {
+			return errors.Wrap(err, "filed to lock audit head")
+		}
+
+		// clock_timestamp, а не now(): now() - начало транзакции, и время записей шло бы не по порядку цепочки
+		err = tx.QueryRowContext(ctx, "select nextval('audit_log_id_seq'), clock_timestamp() at time zone 'utc';").Scan(&entry.Id, &entry.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "filed to get audit entry id")
+		}
+		entry.Hash = entry.ComputeHash()
+
+		_, err = tx.ExecContext(ctx, "insert into audit_log (id, created_at, request_id, actor, action, user_ids, transaction_id, details, prev_hash, hash) "+
+			"values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);",
+			entry.Id, entry.CreatedAt, entry.RequestId, entry.Actor, entry.Action, pq.Array(entry.UserIds), entry.TransactionId,
+			string(entry.Details), entry.PrevHash, entry.Hash)
+		if err != nil {
+			return errors.Wrap(err, "filed to insert audit entry")
+		}
+
+		_, err = tx.ExecContext(ctx, "update audit_head set hash = $1;", entry.Hash)
+		if err != nil {
+			return errors.Wrap(err, "filed to update audit head")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to append audit %s", entry.Action)
+	}
+
+	return &entry, nil
+}
+
+// GetAuditLog записи журнала аудита по фильтру по возрастанию id
+func (r *AuditRepository) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, "select id, created_at, request_id, actor, action, user_ids, transaction_id, details, prev_hash, hash from audit_log "+
+		"where ($1 = '' or actor = $1) and ($2 = '' or $2 = any(user_ids)) "+
+		"and ($3::timestamp is null or created_at >= $3) and ($4::timestamp is null or created_at < $4) "+
+		"and id > $5 order by id limit $6;",
+		filter.Actor, filter.UserId, filter.From, filter.To, filter.AfterId, filter.Limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "filed to get audit log")
+	}
+	defer rows.Close()
+
+	entries := make([]model.AuditEntry, 0)
+	for rows.Next() {
+		var e model.AuditEntry
+		var details string
+		if err := rows.Scan(&e.Id, &e.CreatedAt, &e.RequestId, &e.Actor, &e.Action, pq.Array(&e.UserIds), &e.TransactionId,
+			&details, &e.PrevHash, &e.Hash); err != nil {
+			return nil, errors.Wrap(err, "filed to scan audit entry")
+		}
+		e.Details = json.RawMessage(details)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "filed to get audit log")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAuditRepository_AppendAudit(t *testing.T) {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	transactionId := 5
+
+	lockHead := `select hash from audit_head for update;`
+	selectId := `select nextval\('audit_log_id_seq'\), clock_timestamp\(\) at time zone 'utc';`
+	insert := `insert into audit_log \(id, created_at, request_id, actor, action, user_ids, transaction_id, details, prev_hash, hash\) ` +
+		`values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10\);`
+	updateHead := `update audit_head set hash = \$1;`
+
+	entry := model.AuditEntry{
+		RequestId:     "req-1",
+		Actor:         "cli",
+		Action:        model.TransactionAddFunds,
+		UserIds:       []string{"56"},
+		TransactionId: &transactionId,
+		Details:       json.RawMessage(`{"sum":100}`),
+	}
+	expected := func(prevHash string) *model.AuditEntry {
+		e := entry
+		e.Id = 8
+		e.CreatedAt = createdAt
+		e.PrevHash = prevHash
+		e.Hash = e.ComputeHash()
+		return &e
+	}
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedEntry    *model.AuditEntry
+		wantError        bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				e := expected("abc")
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockHead).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("abc"))
+				mock.ExpectQuery(selectId).WillReturnRows(sqlmock.NewRows([]string{"nextval", "timezone"}).AddRow(8, createdAt))
+				mock.ExpectExec(insert).
+					WithArgs(8, createdAt, "req-1", "cli", model.TransactionAddFunds, pq.Array([]string{"56"}), transactionId, `{"sum":100}`, "abc", e.Hash).
+					WillReturnResult(sqlmock.NewResult(8, 1))
+				mock.ExpectExec(updateHead).WithArgs(e.Hash).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedEntry: expected("abc"),
+		},
+		{
+			// первая запись журнала ни на что не ссылается
+			name: "OK First",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				e := expected("")
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockHead).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(""))
+				mock.ExpectQuery(selectId).WillReturnRows(sqlmock.NewRows([]string{"nextval", "timezone"}).AddRow(8, createdAt))
+				mock.ExpectExec(insert).
+					WithArgs(8, createdAt, "req-1", "cli", model.TransactionAddFunds, pq.Array([]string{"56"}), transactionId, `{"sum":100}`, "", e.Hash).
+					WillReturnResult(sqlmock.NewResult(8, 1))
+				mock.ExpectExec(updateHead).WithArgs(e.Hash).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expectedEntry: expected(""),
+		},
+		{
+			name: "Error in Lock",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockHead).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+		{
+			name: "Error in Insert",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockHead).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("abc"))
+				mock.ExpectQuery(selectId).WillReturnRows(sqlmock.NewRows([]string{"nextval", "timezone"}).AddRow(8, createdAt))
+				mock.ExpectExec(insert).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+		{
+			name: "Error in Update Head",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockHead).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow("abc"))
+				mock.ExpectQuery(selectId).WillReturnRows(sqlmock.NewRows([]string{"nextval", "timezone"}).AddRow(8, createdAt))
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(8, 1))
+				mock.ExpectExec(updateHead).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			appended, err := NewAuditRepository(db).AppendAudit(context.Background(), entry)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedEntry, appended)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAuditRepository_GetAuditLog(t *testing.T) {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	from := createdAt.Add(-time.Hour)
+	transactionId := 5
+
+	selectAudit := `select id, created_at, request_id, actor, action, user_ids, transaction_id, details, prev_hash, hash from audit_log ` +
+		`where .+ order by id limit \$6;`
+	columns := []string{"id", "created_at", "request_id", "actor", "action", "user_ids", "transaction_id", "details", "prev_hash", "hash"}
+
+	testData := []struct {
+		name             string
+		filter           model.AuditFilter
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedEntries  []model.AuditEntry
+		wantError        bool
+	}{
+		{
+			name:   "OK",
+			filter: model.AuditFilter{UserId: "56", From: &from, Limit: 100},
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAudit).WithArgs("", "56", &from, nil, 0, 100).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(1, createdAt, "req-1", "cert:CN=billing", model.TransactionFundsTransfer, `{"56","71"}`, transactionId, `{"sum":100}`, "", "aaa").
+					AddRow(2, createdAt, "", "cli", model.AuditSetStatus, `{"56"}`, nil, `{"to":"frozen"}`, "aaa", "bbb"))
+			},
+			expectedEntries: []model.AuditEntry{
+				{
+					Id:            1,
+					CreatedAt:     createdAt,
+					RequestId:     "req-1",
+					Actor:         "cert:CN=billing",
+					Action:        model.TransactionFundsTransfer,
+					UserIds:       []string{"56", "71"},
+					TransactionId: &transactionId,
+					Details:       json.RawMessage(`{"sum":100}`),
+					Hash:          "aaa",
+				},
+				{
+					Id:        2,
+					CreatedAt: createdAt,
+					Actor:     "cli",
+					Action:    model.AuditSetStatus,
+					UserIds:   []string{"56"},
+					Details:   json.RawMessage(`{"to":"frozen"}`),
+					PrevHash:  "aaa",
+					Hash:      "bbb",
+				},
+			},
+		},
+		{
+			name:   "OK Empty",
+			filter: model.AuditFilter{Actor: "cli", AfterId: 10, Limit: 100},
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAudit).WithArgs("cli", "", nil, nil, 10, 100).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expectedEntries: []model.AuditEntry{},
+		},
+		{
+			name:   "Error",
+			filter: model.AuditFilter{Limit: 100},
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAudit).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			entries, err := NewAuditRepository(db).GetAuditLog(context.Background(), testCase.filter)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedEntries, entries)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
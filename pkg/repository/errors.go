@@ -0,0 +1,17 @@
+package repository
+
+import "github.com/pkg/errors"
+
+// Ошибки, которые сервис должен отличать от прочих ошибок бд
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrAlreadyReversed     = errors.New("transaction already reversed")
+	ErrReversalExceedsSum  = errors.New("reversal sum exceeds transaction remaining sum")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrUserAlreadyExists   = errors.New("user already exists")
+	ErrRetriesExhausted    = errors.New("transaction retries exhausted")
+	ErrUnbalancedEntry     = errors.New("postings do not sum to zero")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrNonZeroBalance      = errors.New("balance is not zero")
+	ErrUserClosed          = errors.New("user is closed")
+)
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/pkg/errors"
+	"math"
+)
+
+type LedgerRepository struct {
+	db    Querier
+	retry RetryConfig
+}
+
+func NewLedgerRepository(db Querier) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// WithRetry повторы CorrectBalance при ошибках сериализации и дедлоках
+func (r *LedgerRepository) WithRetry(c RetryConfig) *LedgerRepository {
+	r.retry = c
+	return r
+}
+
+// GetLedger балансы счетов по всем проводкам журнала и операции, проводки которых не сходятся в 0
+func (r *LedgerRepository) GetLedger(ctx context.Context) (*model.Ledger, error) {
+	ledger := model.Ledger{Accounts: make([]model.AccountBalance, 0), UnbalancedTransactions: make([]int, 0)}
+
+	rows, err := r.db.QueryContext(ctx, "select account, coalesce(sum(amount), 0) from postings group by account order by account;")
+	if err != nil {
+		return nil, errors.Wrap(err, "filed to get account balances")
+	}
+	defer rows.Close()
+
+	var total float64
+	for rows.Next() {
+		var balance model.AccountBalance
+		if err := rows.Scan(&balance.Account, &balance.Balance); err != nil {
+			return nil, errors.Wrap(err, "filed to scan account balance")
+		}
+		ledger.Accounts = append(ledger.Accounts, balance)
+		total += float64(balance.Balance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "filed to get account balances")
+	}
+	ledger.Total = float32(total)
+
+	unbalanced, err := r.db.QueryContext(ctx, "select transaction_id from postings group by transaction_id having abs(sum(amount)) > $1 order by transaction_id;",
+		model.LedgerPrecision)
+	if err != nil {
+		return nil, errors.Wrap(err, "filed to get unbalanced transactions")
+	}
+	defer unbalanced.Close()
+
+	for unbalanced.Next() {
+		var transactionId int
+		if err := unbalanced.Scan(&transactionId); err != nil {
+			return nil, errors.Wrap(err, "filed to scan unbalanced transaction")
+		}
+		ledger.UnbalancedTransactions = append(ledger.UnbalancedTransactions, transactionId)
+	}
+	if err := unbalanced.Err(); err != nil {
+		return nil, errors.Wrap(err, "filed to get unbalanced transactions")
+	}
+
+	ledger.Balanced = len(ledger.UnbalancedTransactions) == 0 && math.Abs(total) <= model.LedgerPrecision
+	return &ledger, nil
+}
+
+// GetDiscrepancies юзеры, у которых кэш баланса в users.balance расходится с суммой проводок по кошельку
+func (r *LedgerRepository) GetDiscrepancies(ctx context.Context) ([]model.Discrepancy, error) {
+	rows, err := r.db.QueryContext(ctx, "select u.user_id, u.balance, coalesce(p.amount, 0) from users u "+
+		"left join (select user_id, sum(amount) amount from postings where account = 'user' group by user_id) p on p.user_id = u.user_id "+
+		"where abs(u.balance - coalesce(p.amount, 0)) > $1 order by u.user_id;", model.LedgerPrecision)
+	if err != nil {
+		return nil, errors.Wrap(err, "filed to get discrepancies")
+	}
+	defer rows.Close()
+
+	discrepancies := make([]model.Discrepancy, 0)
+	for rows.Next() {
+		var d model.Discrepancy
+		if err := rows.Scan(&d.UserId, &d.Balance, &d.LedgerBalance); err != nil {
+			return nil, errors.Wrap(err, "filed to scan discrepancy")
+		}
+		d.Difference = d.Balance - d.LedgerBalance
+		discrepancies = append(discrepancies, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "filed to get discrepancies")
+	}
+
+	return discrepancies, nil
+}
+
+// CorrectBalance исправляет кэш баланса юзера в users.balance на сумму проводок по его кошельку: источник правды - журнал,
+// поэтому проводки не меняются. Исправление сохраняется в balance_corrections. Юзер блокируется на время транзакции,
+// поэтому расхождение пересчитывается заново и параллельная операция его не изменит. Если расхождения уже нет,
+// возвращает его без CorrectionId
+func (r *LedgerRepository) CorrectBalance(ctx context.Context, userId string) (*model.Discrepancy, error) {
+	d := model.Discrepancy{UserId: userId}
+	err := inTx(ctx, r.db, r.retry, func(tx Querier) error {
+		err := tx.QueryRowContext(ctx, "select balance from users where user_id = $1 for update;", userId).Scan(&d.Balance)
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return errors.Wrap(err, "filed to get balance")
+		}
+		err = tx.QueryRowContext(ctx, "select coalesce(sum(amount), 0) from postings where account = 'user' and user_id = $1;", userId).
+			Scan(&d.LedgerBalance)
+		if err != nil {
+			return errors.Wrap(err, "filed to get ledger balance")
+		}
+
+		d.Difference = d.Balance - d.LedgerBalance
+		if math.Abs(float64(d.Difference)) <= model.LedgerPrecision {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, "update users set balance = $1 where user_id = $2;", d.LedgerBalance, userId); err != nil {
+			return errors.Wrap(err, "filed to update balance")
+		}
+		var correctionId int
+		err = tx.QueryRowContext(ctx, "insert into balance_corrections (user_id, balance, ledger_balance) values ($1, $2, $3) returning id;",
+			userId, d.Balance, d.LedgerBalance).Scan(&correctionId)
+		if err != nil {
+			return errors.Wrap(err, "filed to save balance correction")
+		}
+		d.CorrectionId = &correctionId
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to correct balance of user %s", userId)
+	}
+
+	return &d, nil
+}
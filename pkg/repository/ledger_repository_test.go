@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLedgerRepository_GetLedger(t *testing.T) {
+	selectAccounts := `select account, coalesce\(sum\(amount\), 0\) from postings group by account order by account;`
+	selectUnbalanced := `select transaction_id from postings group by transaction_id having abs\(sum\(amount\)\) > \$1 order by transaction_id;`
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedLedger   *model.Ledger
+		wantError        bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAccounts).WillReturnRows(sqlmock.NewRows([]string{"account", "sum"}).
+					AddRow(model.AccountCashIn, -500).AddRow(model.AccountRevenue, 200).AddRow(model.AccountUser, 300))
+				mock.ExpectQuery(selectUnbalanced).WithArgs(model.LedgerPrecision).WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}))
+			},
+			expectedLedger: &model.Ledger{
+				Accounts: []model.AccountBalance{
+					{Account: model.AccountCashIn, Balance: -500},
+					{Account: model.AccountRevenue, Balance: 200},
+					{Account: model.AccountUser, Balance: 300},
+				},
+				UnbalancedTransactions: []int{},
+				Balanced:               true,
+			},
+		},
+		{
+			name: "OK Unbalanced",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAccounts).WillReturnRows(sqlmock.NewRows([]string{"account", "sum"}).
+					AddRow(model.AccountCashIn, -500).AddRow(model.AccountUser, 520))
+				mock.ExpectQuery(selectUnbalanced).WithArgs(model.LedgerPrecision).WillReturnRows(sqlmock.NewRows([]string{"transaction_id"}).AddRow(5))
+			},
+			expectedLedger: &model.Ledger{
+				Accounts: []model.AccountBalance{
+					{Account: model.AccountCashIn, Balance: -500},
+					{Account: model.AccountUser, Balance: 520},
+				},
+				Total:                  20,
+				UnbalancedTransactions: []int{5},
+				Balanced:               false,
+			},
+		},
+		{
+			name: "Error in Accounts",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAccounts).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+		{
+			name: "Error in Unbalanced",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectAccounts).WillReturnRows(sqlmock.NewRows([]string{"account", "sum"}))
+				mock.ExpectQuery(selectUnbalanced).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			ledger, err := NewLedgerRepository(db).GetLedger(context.Background())
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedLedger, ledger)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLedgerRepository_GetDiscrepancies(t *testing.T) {
+	selectDiscrepancies := `select u.user_id, u.balance, coalesce\(p.amount, 0\) from users u left join \(.+\) p on p.user_id = u.user_id ` +
+		`where abs\(u.balance - coalesce\(p.amount, 0\)\) > \$1 order by u.user_id;`
+
+	testData := []struct {
+		name                  string
+		mockSqlxBehavior      func(mock sqlmock.Sqlmock)
+		expectedDiscrepancies []model.Discrepancy
+		wantError             bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectDiscrepancies).WithArgs(model.LedgerPrecision).WillReturnRows(sqlmock.NewRows([]string{"user_id", "balance", "amount"}).
+					AddRow("56", 100, 130).AddRow("71", 120, 100))
+			},
+			expectedDiscrepancies: []model.Discrepancy{
+				{UserId: "56", Balance: 100, LedgerBalance: 130, Difference: -30},
+				{UserId: "71", Balance: 120, LedgerBalance: 100, Difference: 20},
+			},
+		},
+		{
+			name: "OK Empty",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectDiscrepancies).WithArgs(model.LedgerPrecision).WillReturnRows(sqlmock.NewRows([]string{"user_id", "balance", "amount"}))
+			},
+			expectedDiscrepancies: []model.Discrepancy{},
+		},
+		{
+			name: "Error",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectDiscrepancies).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			discrepancies, err := NewLedgerRepository(db).GetDiscrepancies(context.Background())
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedDiscrepancies, discrepancies)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLedgerRepository_CorrectBalance(t *testing.T) {
+	correctionId := 12
+
+	selectBalance := `select balance from users where user_id = \$1 for update;`
+	selectLedgerBalance := `select coalesce\(sum\(amount\), 0\) from postings where account = 'user' and user_id = \$1;`
+	update := `update users set balance = \$1 where user_id = \$2;`
+	insert := `insert into balance_corrections \(user_id, balance, ledger_balance\) values \(\$1, \$2, \$3\) returning id;`
+
+	testData := []struct {
+		name                string
+		mockSqlxBehavior    func(mock sqlmock.Sqlmock)
+		expectedDiscrepancy *model.Discrepancy
+		expectedError       error
+		wantError           bool
+	}{
+		{
+			// кэш больше журнала: users.balance уменьшается до суммы проводок, проводки не меняются
+			name: "OK",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(120))
+				mock.ExpectQuery(selectLedgerBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectExec(update).WithArgs(float32(100), "71").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(insert).WithArgs("71", float32(120), float32(100)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(correctionId))
+				mock.ExpectCommit()
+			},
+			expectedDiscrepancy: &model.Discrepancy{UserId: "71", Balance: 120, LedgerBalance: 100, Difference: 20, CorrectionId: &correctionId},
+		},
+		{
+			name: "OK Already Consistent",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+				mock.ExpectQuery(selectLedgerBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectCommit()
+			},
+			expectedDiscrepancy: &model.Discrepancy{UserId: "71", Balance: 100, LedgerBalance: 100},
+		},
+		{
+			name: "User Not Found",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectBalance).WithArgs("71").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrUserNotFound,
+			wantError:     true,
+		},
+		{
+			name: "Error in Update",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(80))
+				mock.ExpectQuery(selectLedgerBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectExec(update).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+		{
+			name: "Error in Insert",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(80))
+				mock.ExpectQuery(selectLedgerBalance).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectExec(update).WithArgs(float32(100), "71").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(insert).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			discrepancy, err := NewLedgerRepository(db).CorrectBalance(context.Background(), "71")
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+				if testCase.expectedError != nil {
+					assert.True(t, errors.Is(err, testCase.expectedError))
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedDiscrepancy, discrepancy)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
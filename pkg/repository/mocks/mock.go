@@ -5,8 +5,11 @@
 package mock_repository
 
 import (
+	context "context"
 	model "for_avito_tech_with_gin/pkg/model"
+	repository "for_avito_tech_with_gin/pkg/repository"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,75 +37,738 @@ func (m *MockUser) EXPECT() *MockUserMockRecorder {
 	return m.recorder
 }
 
-// CreateFundsTransaction mocks base method.
-func (m *MockUser) CreateFundsTransaction(senderId, receiverId int, sum float32) error {
+// CreateUser mocks base method.
+func (m *MockUser) CreateUser(ctx context.Context, userId string, balance float32, externalRef string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, userId, balance, externalRef)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockUserMockRecorder) CreateUser(ctx, userId, balance, externalRef interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUser)(nil).CreateUser), ctx, userId, balance, externalRef)
+}
+
+// GetLimits mocks base method.
+func (m *MockUser) GetLimits(ctx context.Context, userId string) (*model.Limits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLimits", ctx, userId)
+	ret0, _ := ret[0].(*model.Limits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLimits indicates an expected call of GetLimits.
+func (mr *MockUserMockRecorder) GetLimits(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLimits", reflect.TypeOf((*MockUser)(nil).GetLimits), ctx, userId)
+}
+
+// GetSpending mocks base method.
+func (m *MockUser) GetSpending(ctx context.Context, userId, transactionType string, since time.Time) (float32, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSpending", ctx, userId, transactionType, since)
+	ret0, _ := ret[0].(float32)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetSpending indicates an expected call of GetSpending.
+func (mr *MockUserMockRecorder) GetSpending(ctx, userId, transactionType, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSpending", reflect.TypeOf((*MockUser)(nil).GetSpending), ctx, userId, transactionType, since)
+}
+
+// GetTransactions mocks base method.
+func (m *MockUser) GetTransactions(ctx context.Context, userId string) ([]model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactions", ctx, userId)
+	ret0, _ := ret[0].([]model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactions indicates an expected call of GetTransactions.
+func (mr *MockUserMockRecorder) GetTransactions(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactions", reflect.TypeOf((*MockUser)(nil).GetTransactions), ctx, userId)
+}
+
+// GetUser mocks base method.
+func (m *MockUser) GetUser(ctx context.Context, userId string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userId)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockUserMockRecorder) GetUser(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUser)(nil).GetUser), ctx, userId)
+}
+
+// GetUserForUpdate mocks base method.
+func (m *MockUser) GetUserForUpdate(ctx context.Context, userId string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserForUpdate", ctx, userId)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserForUpdate indicates an expected call of GetUserForUpdate.
+func (mr *MockUserMockRecorder) GetUserForUpdate(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserForUpdate", reflect.TypeOf((*MockUser)(nil).GetUserForUpdate), ctx, userId)
+}
+
+// GetUsersForUpdate mocks base method.
+func (m *MockUser) GetUsersForUpdate(ctx context.Context, userIds ...string) (map[string]*model.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateFundsTransaction", senderId, receiverId, sum)
+	varargs := []interface{}{ctx}
+	for _, a := range userIds {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetUsersForUpdate", varargs...)
+	ret0, _ := ret[0].(map[string]*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersForUpdate indicates an expected call of GetUsersForUpdate.
+func (mr *MockUserMockRecorder) GetUsersForUpdate(ctx interface{}, userIds ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, userIds...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersForUpdate", reflect.TypeOf((*MockUser)(nil).GetUsersForUpdate), varargs...)
+}
+
+// IsUserExist mocks base method.
+func (m *MockUser) IsUserExist(ctx context.Context, userId string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsUserExist", ctx, userId)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsUserExist indicates an expected call of IsUserExist.
+func (mr *MockUserMockRecorder) IsUserExist(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUserExist", reflect.TypeOf((*MockUser)(nil).IsUserExist), ctx, userId)
+}
+
+// Post mocks base method.
+func (m *MockUser) Post(ctx context.Context, entry model.JournalEntry) (*model.OperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Post", ctx, entry)
+	ret0, _ := ret[0].(*model.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Post indicates an expected call of Post.
+func (mr *MockUserMockRecorder) Post(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Post", reflect.TypeOf((*MockUser)(nil).Post), ctx, entry)
+}
+
+// SetCreditLimit mocks base method.
+func (m *MockUser) SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCreditLimit", ctx, userId, creditLimit)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetCreditLimit indicates an expected call of SetCreditLimit.
+func (mr *MockUserMockRecorder) SetCreditLimit(ctx, userId, creditLimit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCreditLimit", reflect.TypeOf((*MockUser)(nil).SetCreditLimit), ctx, userId, creditLimit)
+}
+
+// SetLimits mocks base method.
+func (m *MockUser) SetLimits(ctx context.Context, userId string, limits model.Limits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLimits", ctx, userId, limits)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreateFundsTransaction indicates an expected call of CreateFundsTransaction.
-func (mr *MockUserMockRecorder) CreateFundsTransaction(senderId, receiverId, sum interface{}) *gomock.Call {
+// SetLimits indicates an expected call of SetLimits.
+func (mr *MockUserMockRecorder) SetLimits(ctx, userId, limits interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFundsTransaction", reflect.TypeOf((*MockUser)(nil).CreateFundsTransaction), senderId, receiverId, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLimits", reflect.TypeOf((*MockUser)(nil).SetLimits), ctx, userId, limits)
 }
 
-// CreateUser mocks base method.
-func (m *MockUser) CreateUser(userId int, balance float32) error {
+// SetStatus mocks base method.
+func (m *MockUser) SetStatus(ctx context.Context, userId, status, reason string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", userId, balance)
+	ret := m.ctrl.Call(m, "SetStatus", ctx, userId, status, reason)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// SetStatus indicates an expected call of SetStatus.
+func (mr *MockUserMockRecorder) SetStatus(ctx, userId, status, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatus", reflect.TypeOf((*MockUser)(nil).SetStatus), ctx, userId, status, reason)
+}
+
+// MockTransaction is a mock of Transaction interface.
+type MockTransaction struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransactionMockRecorder
+}
+
+// MockTransactionMockRecorder is the mock recorder for MockTransaction.
+type MockTransactionMockRecorder struct {
+	mock *MockTransaction
+}
+
+// NewMockTransaction creates a new mock instance.
+func NewMockTransaction(ctrl *gomock.Controller) *MockTransaction {
+	mock := &MockTransaction{ctrl: ctrl}
+	mock.recorder = &MockTransactionMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransaction) EXPECT() *MockTransactionMockRecorder {
+	return m.recorder
+}
+
+// GetTransaction mocks base method.
+func (m *MockTransaction) GetTransaction(ctx context.Context, transactionId int) (*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransaction", ctx, transactionId)
+	ret0, _ := ret[0].(*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransaction indicates an expected call of GetTransaction.
+func (mr *MockTransactionMockRecorder) GetTransaction(ctx, transactionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransaction", reflect.TypeOf((*MockTransaction)(nil).GetTransaction), ctx, transactionId)
+}
+
+// ReverseTransaction mocks base method.
+func (m *MockTransaction) ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransaction", ctx, transactionId, sum, allowNegative, info)
+	ret0, _ := ret[0].(*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransaction indicates an expected call of ReverseTransaction.
+func (mr *MockTransactionMockRecorder) ReverseTransaction(ctx, transactionId, sum, allowNegative, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransaction", reflect.TypeOf((*MockTransaction)(nil).ReverseTransaction), ctx, transactionId, sum, allowNegative, info)
+}
+
+// MockLedger is a mock of Ledger interface.
+type MockLedger struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerMockRecorder
+}
+
+// MockLedgerMockRecorder is the mock recorder for MockLedger.
+type MockLedgerMockRecorder struct {
+	mock *MockLedger
+}
+
+// NewMockLedger creates a new mock instance.
+func NewMockLedger(ctrl *gomock.Controller) *MockLedger {
+	mock := &MockLedger{ctrl: ctrl}
+	mock.recorder = &MockLedgerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedger) EXPECT() *MockLedgerMockRecorder {
+	return m.recorder
+}
+
+// CorrectBalance mocks base method.
+func (m *MockLedger) CorrectBalance(ctx context.Context, userId string) (*model.Discrepancy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CorrectBalance", ctx, userId)
+	ret0, _ := ret[0].(*model.Discrepancy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CorrectBalance indicates an expected call of CorrectBalance.
+func (mr *MockLedgerMockRecorder) CorrectBalance(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CorrectBalance", reflect.TypeOf((*MockLedger)(nil).CorrectBalance), ctx, userId)
+}
+
+// GetDiscrepancies mocks base method.
+func (m *MockLedger) GetDiscrepancies(ctx context.Context) ([]model.Discrepancy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiscrepancies", ctx)
+	ret0, _ := ret[0].([]model.Discrepancy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiscrepancies indicates an expected call of GetDiscrepancies.
+func (mr *MockLedgerMockRecorder) GetDiscrepancies(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiscrepancies", reflect.TypeOf((*MockLedger)(nil).GetDiscrepancies), ctx)
+}
+
+// GetLedger mocks base method.
+func (m *MockLedger) GetLedger(ctx context.Context) (*model.Ledger, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedger", ctx)
+	ret0, _ := ret[0].(*model.Ledger)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedger indicates an expected call of GetLedger.
+func (mr *MockLedgerMockRecorder) GetLedger(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedger", reflect.TypeOf((*MockLedger)(nil).GetLedger), ctx)
+}
+
+// MockAudit is a mock of Audit interface.
+type MockAudit struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditMockRecorder
+}
+
+// MockAuditMockRecorder is the mock recorder for MockAudit.
+type MockAuditMockRecorder struct {
+	mock *MockAudit
+}
+
+// NewMockAudit creates a new mock instance.
+func NewMockAudit(ctrl *gomock.Controller) *MockAudit {
+	mock := &MockAudit{ctrl: ctrl}
+	mock.recorder = &MockAuditMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAudit) EXPECT() *MockAuditMockRecorder {
+	return m.recorder
+}
+
+// AppendAudit mocks base method.
+func (m *MockAudit) AppendAudit(ctx context.Context, entry model.AuditEntry) (*model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAudit", ctx, entry)
+	ret0, _ := ret[0].(*model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendAudit indicates an expected call of AppendAudit.
+func (mr *MockAuditMockRecorder) AppendAudit(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAudit", reflect.TypeOf((*MockAudit)(nil).AppendAudit), ctx, entry)
+}
+
+// GetAuditLog mocks base method.
+func (m *MockAudit) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLog", ctx, filter)
+	ret0, _ := ret[0].([]model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLog indicates an expected call of GetAuditLog.
+func (mr *MockAuditMockRecorder) GetAuditLog(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLog", reflect.TypeOf((*MockAudit)(nil).GetAuditLog), ctx, filter)
+}
+
+// MockUnitOfWork is a mock of UnitOfWork interface.
+type MockUnitOfWork struct {
+	ctrl     *gomock.Controller
+	recorder *MockUnitOfWorkMockRecorder
+}
+
+// MockUnitOfWorkMockRecorder is the mock recorder for MockUnitOfWork.
+type MockUnitOfWorkMockRecorder struct {
+	mock *MockUnitOfWork
+}
+
+// NewMockUnitOfWork creates a new mock instance.
+func NewMockUnitOfWork(ctrl *gomock.Controller) *MockUnitOfWork {
+	mock := &MockUnitOfWork{ctrl: ctrl}
+	mock.recorder = &MockUnitOfWorkMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUnitOfWork) EXPECT() *MockUnitOfWorkMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *MockUnitOfWork) Begin(ctx context.Context) (repository.Tx, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin", ctx)
+	ret0, _ := ret[0].(repository.Tx)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockUnitOfWorkMockRecorder) Begin(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockUnitOfWork)(nil).Begin), ctx)
+}
+
+// MockTx is a mock of Tx interface.
+type MockTx struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxMockRecorder
+}
+
+// MockTxMockRecorder is the mock recorder for MockTx.
+type MockTxMockRecorder struct {
+	mock *MockTx
+}
+
+// NewMockTx creates a new mock instance.
+func NewMockTx(ctrl *gomock.Controller) *MockTx {
+	mock := &MockTx{ctrl: ctrl}
+	mock.recorder = &MockTxMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTx) EXPECT() *MockTxMockRecorder {
+	return m.recorder
+}
+
+// AppendAudit mocks base method.
+func (m *MockTx) AppendAudit(ctx context.Context, entry model.AuditEntry) (*model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendAudit", ctx, entry)
+	ret0, _ := ret[0].(*model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AppendAudit indicates an expected call of AppendAudit.
+func (mr *MockTxMockRecorder) AppendAudit(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendAudit", reflect.TypeOf((*MockTx)(nil).AppendAudit), ctx, entry)
+}
+
+// Commit mocks base method.
+func (m *MockTx) Commit() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockTxMockRecorder) Commit() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockTx)(nil).Commit))
+}
+
+// CorrectBalance mocks base method.
+func (m *MockTx) CorrectBalance(ctx context.Context, userId string) (*model.Discrepancy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CorrectBalance", ctx, userId)
+	ret0, _ := ret[0].(*model.Discrepancy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CorrectBalance indicates an expected call of CorrectBalance.
+func (mr *MockTxMockRecorder) CorrectBalance(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CorrectBalance", reflect.TypeOf((*MockTx)(nil).CorrectBalance), ctx, userId)
+}
+
+// CreateUser mocks base method.
+func (m *MockTx) CreateUser(ctx context.Context, userId string, balance float32, externalRef string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, userId, balance, externalRef)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // CreateUser indicates an expected call of CreateUser.
-func (mr *MockUserMockRecorder) CreateUser(userId, balance interface{}) *gomock.Call {
+func (mr *MockTxMockRecorder) CreateUser(ctx, userId, balance, externalRef interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUser)(nil).CreateUser), userId, balance)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockTx)(nil).CreateUser), ctx, userId, balance, externalRef)
+}
+
+// GetAuditLog mocks base method.
+func (m *MockTx) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLog", ctx, filter)
+	ret0, _ := ret[0].([]model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLog indicates an expected call of GetAuditLog.
+func (mr *MockTxMockRecorder) GetAuditLog(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLog", reflect.TypeOf((*MockTx)(nil).GetAuditLog), ctx, filter)
+}
+
+// GetDiscrepancies mocks base method.
+func (m *MockTx) GetDiscrepancies(ctx context.Context) ([]model.Discrepancy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiscrepancies", ctx)
+	ret0, _ := ret[0].([]model.Discrepancy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiscrepancies indicates an expected call of GetDiscrepancies.
+func (mr *MockTxMockRecorder) GetDiscrepancies(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiscrepancies", reflect.TypeOf((*MockTx)(nil).GetDiscrepancies), ctx)
+}
+
+// GetLedger mocks base method.
+func (m *MockTx) GetLedger(ctx context.Context) (*model.Ledger, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedger", ctx)
+	ret0, _ := ret[0].(*model.Ledger)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedger indicates an expected call of GetLedger.
+func (mr *MockTxMockRecorder) GetLedger(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedger", reflect.TypeOf((*MockTx)(nil).GetLedger), ctx)
+}
+
+// GetLimits mocks base method.
+func (m *MockTx) GetLimits(ctx context.Context, userId string) (*model.Limits, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLimits", ctx, userId)
+	ret0, _ := ret[0].(*model.Limits)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLimits indicates an expected call of GetLimits.
+func (mr *MockTxMockRecorder) GetLimits(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLimits", reflect.TypeOf((*MockTx)(nil).GetLimits), ctx, userId)
+}
+
+// GetSpending mocks base method.
+func (m *MockTx) GetSpending(ctx context.Context, userId, transactionType string, since time.Time) (float32, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSpending", ctx, userId, transactionType, since)
+	ret0, _ := ret[0].(float32)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetSpending indicates an expected call of GetSpending.
+func (mr *MockTxMockRecorder) GetSpending(ctx, userId, transactionType, since interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSpending", reflect.TypeOf((*MockTx)(nil).GetSpending), ctx, userId, transactionType, since)
+}
+
+// GetTransaction mocks base method.
+func (m *MockTx) GetTransaction(ctx context.Context, transactionId int) (*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransaction", ctx, transactionId)
+	ret0, _ := ret[0].(*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransaction indicates an expected call of GetTransaction.
+func (mr *MockTxMockRecorder) GetTransaction(ctx, transactionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransaction", reflect.TypeOf((*MockTx)(nil).GetTransaction), ctx, transactionId)
+}
+
+// GetTransactions mocks base method.
+func (m *MockTx) GetTransactions(ctx context.Context, userId string) ([]model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactions", ctx, userId)
+	ret0, _ := ret[0].([]model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactions indicates an expected call of GetTransactions.
+func (mr *MockTxMockRecorder) GetTransactions(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactions", reflect.TypeOf((*MockTx)(nil).GetTransactions), ctx, userId)
 }
 
 // GetUser mocks base method.
-func (m *MockUser) GetUser(userId int) (*model.User, error) {
+func (m *MockTx) GetUser(ctx context.Context, userId string) (*model.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", userId)
+	ret := m.ctrl.Call(m, "GetUser", ctx, userId)
 	ret0, _ := ret[0].(*model.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUser indicates an expected call of GetUser.
-func (mr *MockUserMockRecorder) GetUser(userId interface{}) *gomock.Call {
+func (mr *MockTxMockRecorder) GetUser(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockTx)(nil).GetUser), ctx, userId)
+}
+
+// GetUserForUpdate mocks base method.
+func (m *MockTx) GetUserForUpdate(ctx context.Context, userId string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserForUpdate", ctx, userId)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserForUpdate indicates an expected call of GetUserForUpdate.
+func (mr *MockTxMockRecorder) GetUserForUpdate(ctx, userId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUser)(nil).GetUser), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserForUpdate", reflect.TypeOf((*MockTx)(nil).GetUserForUpdate), ctx, userId)
+}
+
+// GetUsersForUpdate mocks base method.
+func (m *MockTx) GetUsersForUpdate(ctx context.Context, userIds ...string) (map[string]*model.User, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range userIds {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetUsersForUpdate", varargs...)
+	ret0, _ := ret[0].(map[string]*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsersForUpdate indicates an expected call of GetUsersForUpdate.
+func (mr *MockTxMockRecorder) GetUsersForUpdate(ctx interface{}, userIds ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, userIds...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsersForUpdate", reflect.TypeOf((*MockTx)(nil).GetUsersForUpdate), varargs...)
 }
 
 // IsUserExist mocks base method.
-func (m *MockUser) IsUserExist(userId int) (bool, error) {
+func (m *MockTx) IsUserExist(ctx context.Context, userId string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsUserExist", userId)
+	ret := m.ctrl.Call(m, "IsUserExist", ctx, userId)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // IsUserExist indicates an expected call of IsUserExist.
-func (mr *MockUserMockRecorder) IsUserExist(userId interface{}) *gomock.Call {
+func (mr *MockTxMockRecorder) IsUserExist(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUserExist", reflect.TypeOf((*MockTx)(nil).IsUserExist), ctx, userId)
+}
+
+// Post mocks base method.
+func (m *MockTx) Post(ctx context.Context, entry model.JournalEntry) (*model.OperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Post", ctx, entry)
+	ret0, _ := ret[0].(*model.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Post indicates an expected call of Post.
+func (mr *MockTxMockRecorder) Post(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Post", reflect.TypeOf((*MockTx)(nil).Post), ctx, entry)
+}
+
+// ReverseTransaction mocks base method.
+func (m *MockTx) ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransaction", ctx, transactionId, sum, allowNegative, info)
+	ret0, _ := ret[0].(*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransaction indicates an expected call of ReverseTransaction.
+func (mr *MockTxMockRecorder) ReverseTransaction(ctx, transactionId, sum, allowNegative, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransaction", reflect.TypeOf((*MockTx)(nil).ReverseTransaction), ctx, transactionId, sum, allowNegative, info)
+}
+
+// Rollback mocks base method.
+func (m *MockTx) Rollback() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rollback indicates an expected call of Rollback.
+func (mr *MockTxMockRecorder) Rollback() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsUserExist", reflect.TypeOf((*MockUser)(nil).IsUserExist), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockTx)(nil).Rollback))
 }
 
-// UpdateBalance mocks base method.
-func (m *MockUser) UpdateBalance(userId int, sum float32) (*model.User, error) {
+// SetCreditLimit mocks base method.
+func (m *MockTx) SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) (*model.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateBalance", userId, sum)
+	ret := m.ctrl.Call(m, "SetCreditLimit", ctx, userId, creditLimit)
 	ret0, _ := ret[0].(*model.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateBalance indicates an expected call of UpdateBalance.
-func (mr *MockUserMockRecorder) UpdateBalance(userId, sum interface{}) *gomock.Call {
+// SetCreditLimit indicates an expected call of SetCreditLimit.
+func (mr *MockTxMockRecorder) SetCreditLimit(ctx, userId, creditLimit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCreditLimit", reflect.TypeOf((*MockTx)(nil).SetCreditLimit), ctx, userId, creditLimit)
+}
+
+// SetLimits mocks base method.
+func (m *MockTx) SetLimits(ctx context.Context, userId string, limits model.Limits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLimits", ctx, userId, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLimits indicates an expected call of SetLimits.
+func (mr *MockTxMockRecorder) SetLimits(ctx, userId, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLimits", reflect.TypeOf((*MockTx)(nil).SetLimits), ctx, userId, limits)
+}
+
+// SetStatus mocks base method.
+func (m *MockTx) SetStatus(ctx context.Context, userId, status, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatus", ctx, userId, status, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStatus indicates an expected call of SetStatus.
+func (mr *MockTxMockRecorder) SetStatus(ctx, userId, status, reason interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBalance", reflect.TypeOf((*MockUser)(nil).UpdateBalance), userId, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatus", reflect.TypeOf((*MockTx)(nil).SetStatus), ctx, userId, status, reason)
 }
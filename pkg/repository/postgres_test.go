@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	testData := []struct {
+		name      string
+		timeout   time.Duration
+		failures  int
+		wantError bool
+	}{
+		{
+			name:     "OK",
+			timeout:  time.Second,
+			failures: 0,
+		},
+		{
+			name:     "OK After Retries",
+			timeout:  time.Second,
+			failures: 2,
+		},
+		{
+			name:      "ERR Timeout",
+			timeout:   0,
+			failures:  1,
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			for i := 0; i < testCase.failures; i++ {
+				mock.ExpectPing().WillReturnError(errors.Errorf("lol kek cheburek."))
+			}
+			if !testCase.wantError {
+				mock.ExpectPing()
+			}
+
+			err = ping(db, testCase.timeout, "primary")
+
+			// assert
+			if testCase.wantError {
+				assert.EqualError(t, err, "filed to connect to primary db after 1 attempts: lol kek cheburek.")
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestConfig_DSN(t *testing.T) {
+	c := Config{Host: "localhost", Port: "5432", Username: "postgres", Password: "1234", DBName: "balance", SSLMode: "disable"}
+	assert.Equal(t, "host=localhost port=5432 user=postgres dbname=balance password=1234 sslmode=disable", c.DSN())
+
+	c.URL = "postgres://billing@db/balance"
+	assert.Equal(t, "postgres://billing@db/balance", c.DSN())
+}
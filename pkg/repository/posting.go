@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+// post проводит запись журнала в транзакции tx: меняет балансы кошельков, сохраняет операцию и ее проводки.
+// Возвращает операцию и балансы кошельков после нее. Кошелек уходит в минус, только если хватает кредитного лимита
+// (или allowNegative), иначе ErrInsufficientFunds. Кошельки меняются по порядку user_id, чтобы встречные переводы
+// не ловили дедлок
+func post(ctx context.Context, tx Querier, entry model.JournalEntry, allowNegative bool, defaultCreditLimit float32) (*model.Transaction, map[string]float32, error) {
+	if !entry.Balanced() {
+		return nil, nil, ErrUnbalancedEntry
+	}
+
+	wallets := make([]model.Posting, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		if p.Account == model.AccountUser {
+			wallets = append(wallets, p)
+		}
+	}
+	sort.SliceStable(wallets, func(i, j int) bool {
+		return *wallets[i].UserId < *wallets[j].UserId
+	})
+
+	balances := make(map[string]float32, len(wallets))
+	for _, p := range wallets {
+		var balance float32
+		var err error
+		if p.Amount < 0 {
+			balance, err = debitUser(ctx, tx, *p.UserId, -p.Amount, allowNegative, defaultCreditLimit)
+		} else {
+			balance, err = creditUser(ctx, tx, *p.UserId, p.Amount)
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "filed to update user %s", *p.UserId)
+		}
+		balances[*p.UserId] = balance
+	}
+
+	transaction, err := insertTransaction(ctx, tx, entry)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "filed to save transaction")
+	}
+	for _, p := range entry.Postings {
+		_, err := tx.ExecContext(ctx, "insert into postings (transaction_id, account, user_id, amount) values ($1, $2, $3, $4);",
+			transaction.Id, p.Account, p.UserId, p.Amount)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "filed to save postings of transaction %d", transaction.Id)
+		}
+	}
+
+	return transaction, balances, nil
+}
+
+func balanceOf(balances map[string]float32, userId *string) *float32 {
+	if userId == nil {
+		return nil
+	}
+	balance, ok := balances[*userId]
+	if !ok {
+		return nil
+	}
+	return &balance
+}
+
+// insertTransaction сохраняет операцию в историю и возвращает ее вместе с id и временем создания
+func insertTransaction(ctx context.Context, tx Querier, entry model.JournalEntry) (*model.Transaction, error) {
+	transaction := model.Transaction{
+		Type:            entry.Type,
+		SenderId:        entry.Sender(),
+		ReceiverId:      entry.Receiver(),
+		Sum:             entry.Sum(),
+		TransactionInfo: entry.TransactionInfo,
+		ReversedId:      entry.ReversedId,
+	}
+	err := tx.QueryRowContext(ctx, "insert into transactions (type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id) "+
+		"values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning id, created_at;",
+		transaction.Type, transaction.SenderId, transaction.ReceiverId, transaction.Sum,
+		entry.OrderId, entry.ServiceId, entry.Comment, entry.Source, entry.ReversedId).
+		Scan(&transaction.Id, &transaction.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
+
+// debitUser списывает sum и возвращает новый баланс, если баланс после списания не опустится ниже кредитного лимита юзера
+// (или allowNegative), иначе ErrInsufficientFunds. Проверка и списание - один запрос, поэтому параллельные списания не уведут
+// баланс за лимит
+func debitUser(ctx context.Context, tx Querier, userId string, sum float32, allowNegative bool, defaultCreditLimit float32) (float32, error) {
+	var balance float32
+	err := tx.QueryRowContext(ctx, "update users set balance = balance - $1 where user_id = $2 and ($3 or balance - $1 >= -coalesce(credit_limit, $4)) "+
+		"returning balance;", sum, userId, allowNegative, defaultCreditLimit).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, ErrInsufficientFunds
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// creditUser начисляет sum и возвращает новый баланс
+func creditUser(ctx context.Context, tx Querier, userId string, sum float32) (float32, error) {
+	var balance float32
+	err := tx.QueryRowContext(ctx, "update users set balance = balance + $1 where user_id = $2 returning balance;", sum, userId).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
@@ -1,26 +1,93 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"for_avito_tech_with_gin/pkg/model"
+	"time"
 )
 
 //go:generate mockgen -source=repository.go -destination=mocks/mock.go
 
 type User interface {
-	CreateUser(userId int, balance float32) error
-	GetUser(userId int) (*model.User, error)
-	IsUserExist(userId int) (bool, error)
-	UpdateBalance(userId int, sum float32) (*model.User, error)
-	CreateFundsTransaction(senderId int, receiverId int, sum float32) error
+	CreateUser(ctx context.Context, userId string, balance float32, externalRef string) (*model.User, error)
+	GetUser(ctx context.Context, userId string) (*model.User, error)
+	GetUserForUpdate(ctx context.Context, userId string) (*model.User, error)
+	GetUsersForUpdate(ctx context.Context, userIds ...string) (map[string]*model.User, error)
+	IsUserExist(ctx context.Context, userId string) (bool, error)
+	Post(ctx context.Context, entry model.JournalEntry) (*model.OperationResult, error)
+	SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) (*model.User, error)
+	SetStatus(ctx context.Context, userId string, status string, reason string) error
+	GetLimits(ctx context.Context, userId string) (*model.Limits, error)
+	SetLimits(ctx context.Context, userId string, limits model.Limits) error
+	GetSpending(ctx context.Context, userId string, transactionType string, since time.Time) (float32, int, error)
+	GetTransactions(ctx context.Context, userId string) ([]model.Transaction, error)
+}
+
+type Transaction interface {
+	GetTransaction(ctx context.Context, transactionId int) (*model.Transaction, error)
+	ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error)
+}
+
+type Ledger interface {
+	GetLedger(ctx context.Context) (*model.Ledger, error)
+	GetDiscrepancies(ctx context.Context) ([]model.Discrepancy, error)
+	CorrectBalance(ctx context.Context, userId string) (*model.Discrepancy, error)
+}
+
+// Audit журнал аудита, записи только дополняются и связаны цепочкой хэшей
+type Audit interface {
+	AppendAudit(ctx context.Context, entry model.AuditEntry) (*model.AuditEntry, error)
+	GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+// UnitOfWork объединяет несколько вызовов репозиториев в одну транзакцию
+type UnitOfWork interface {
+	Begin(ctx context.Context) (Tx, error)
+}
+
+// Tx репозитории внутри транзакции, изменения видны другим только после Commit. Rollback после Commit ничего не откатывает,
+// поэтому его можно звать в defer. Запись журнала аудита в Tx сохраняется или откатывается вместе с действием
+type Tx interface {
+	User
+	Transaction
+	Ledger
+	Audit
+	Commit() error
+	Rollback() error
 }
 
 type Repository struct {
 	User
+	Transaction
+	Ledger
+	Audit
+	UnitOfWork
+
+	Retry RetryConfig // повторы InTx
 }
 
-func NewRepository(db *sql.DB) *Repository {
+// NewRepository replica - реплика для чтения баланса и истории, nil - все запросы идут в db,
+// retry - повторы транзакций при ошибках сериализации и дедлоках
+func NewRepository(db *sql.DB, replica *sql.DB, defaultCreditLimit float32, retry RetryConfig) *Repository {
 	return &Repository{
-		User: NewUserRepository(db),
+		User:        NewUserRepository(db, defaultCreditLimit).WithReplica(replica).WithRetry(retry),
+		Transaction: NewTransactionRepository(db, defaultCreditLimit).WithRetry(retry),
+		Ledger:      NewLedgerRepository(db).WithRetry(retry),
+		Audit:       NewAuditRepository(db).WithRetry(retry),
+		UnitOfWork:  NewUnitOfWork(db, defaultCreditLimit),
+		Retry:       retry,
 	}
 }
+
+type replicaKey struct{}
+
+// FromReplica помечает чтения в ctx как допускающие отставание: они пойдут в реплику, если она есть
+func FromReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey{}, true)
+}
+
+func isFromReplica(ctx context.Context) bool {
+	fromReplica, _ := ctx.Value(replicaKey{}).(bool)
+	return fromReplica
+}
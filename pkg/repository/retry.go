@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"for_avito_tech_with_gin/pkg/logging"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"math/rand"
+	"time"
+)
+
+// Коды ошибок postgres, после которых транзакцию можно просто повторить
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// txOptions транзакции с деньгами идут в repeatable read: параллельное изменение тех же строк откатывает транзакцию
+// с ошибкой сериализации, и ее повторяют целиком, а не продолжают на устаревших данных
+var txOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead}
+
+// RetryConfig повторы транзакций, которые postgres откатил из-за параллельных изменений тех же строк
+type RetryConfig struct {
+	MaxAttempts int           // вместе с первой попыткой, 0 и 1 - без повторов
+	BaseDelay   time.Duration // пауза перед первым повтором, дальше удваивается
+	MaxDelay    time.Duration // потолок паузы
+}
+
+// IsRetryable ошибка сериализации или дедлок: транзакция откачена целиком и ее можно повторить
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailure || pqErr.Code == deadlockDetected
+}
+
+// inTx выполняет fn в транзакции. На *sql.DB транзакция при ошибке сериализации или дедлоке повторяется целиком
+// до c.MaxAttempts раз, после этого - ErrRetriesExhausted. Внутри unit of work fn идет в его транзакции без повторов:
+// повторять можно только весь unit of work, это делает Repository.InTx
+func inTx(ctx context.Context, db Querier, c RetryConfig, fn func(tx Querier) error) error {
+	conn, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	return retry(ctx, c, func() error {
+		return runTx(ctx, conn, fn)
+	})
+}
+
+// retry повторяет fn, пока она падает с ошибкой сериализации или дедлоком, с паузой от c.BaseDelay до c.MaxDelay
+func retry(ctx context.Context, c RetryConfig, fn func() error) error {
+	delay := c.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt >= c.MaxAttempts {
+			return errors.Wrapf(ErrRetriesExhausted, "%d attempts, last error: %v", attempt, err)
+		}
+
+		logging.Package(ctx, "repository").WithField("attempt", attempt).Debugf("retry transaction: %v", err)
+		if err := sleep(ctx, jitter(delay)); err != nil {
+			return err
+		}
+		if delay *= 2; delay > c.MaxDelay {
+			delay = c.MaxDelay
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, fn func(tx Querier) error) error {
+	tx, err := db.BeginTx(ctx, txOptions)
+	if err != nil {
+		return errors.Wrap(err, "filed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// jitter случайная пауза от delay/2 до delay, чтобы столкнувшиеся транзакции не повторялись одновременно
+func jitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	testData := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Serialization Failure", err: &pq.Error{Code: "40001"}, expected: true},
+		{name: "Deadlock", err: errors.Wrap(&pq.Error{Code: "40P01"}, "filed update balance"), expected: true},
+		{name: "Unique Violation", err: &pq.Error{Code: "23505"}, expected: false},
+		{name: "Other", err: errors.Errorf("lol kek cheburek."), expected: false},
+		{name: "Nil", err: nil, expected: false},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// assert
+			assert.Equal(t, testCase.expected, IsRetryable(testCase.err))
+		})
+	}
+}
+
+func TestUserRepository_Post_retry(t *testing.T) {
+	update := `update users set balance = balance \+ \$1 where user_id = \$2`
+	insert := `insert into transactions \(type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id\)`
+	posting := `insert into postings`
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	retry := RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedError    error
+	}{
+		{
+			name: "OK After Deadlock",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WillReturnError(&pq.Error{Code: "40P01"})
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(120))
+				mock.ExpectQuery(insert).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "ERR Retries Exhausted",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 3; i++ {
+					mock.ExpectBegin()
+					mock.ExpectQuery(update).WillReturnError(&pq.Error{Code: "40001"})
+					mock.ExpectRollback()
+				}
+			},
+			expectedError: ErrRetriesExhausted,
+		},
+		{
+			name: "ERR Not Retryable",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WillReturnError(&pq.Error{Code: "23505"})
+				mock.ExpectRollback()
+			},
+			expectedError: &pq.Error{Code: "23505"},
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			repo := NewUserRepository(db, 500).WithRetry(retry)
+			result, err := repo.Post(context.Background(), model.NewCredit("71", 20, model.TransactionInfo{}))
+
+			// assert
+			if testCase.expectedError != nil {
+				assert.Error(t, err)
+				assert.Equal(t, testCase.expectedError, errors.Cause(err))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, float32(120), *result.Balance)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_jitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := jitter(100 * time.Millisecond)
+
+		// assert
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 100*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), jitter(0))
+}
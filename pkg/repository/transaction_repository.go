@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/pkg/errors"
+)
+
+type TransactionRepository struct {
+	db                 Querier
+	retry              RetryConfig
+	defaultCreditLimit float32
+}
+
+func NewTransactionRepository(db Querier, defaultCreditLimit float32) *TransactionRepository {
+	return &TransactionRepository{db: db, defaultCreditLimit: defaultCreditLimit}
+}
+
+// WithRetry повторы ReverseTransaction при ошибках сериализации и дедлоках
+func (r *TransactionRepository) WithRetry(c RetryConfig) *TransactionRepository {
+	r.retry = c
+	return r
+}
+
+func (r *TransactionRepository) GetTransaction(ctx context.Context, transactionId int) (*model.Transaction, error) {
+	var transaction model.Transaction
+	err := r.db.QueryRowContext(ctx, "select "+transactionFields+" from transactions where id = $1;", transactionId).
+		Scan(transaction.GetFields()...)
+	if err == sql.ErrNoRows {
+		return nil, errors.Wrapf(ErrTransactionNotFound, "filed to get transaction %d", transactionId)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to get transaction %d", transactionId)
+	}
+
+	return &transaction, nil
+}
+
+// ReverseTransaction создает компенсирующую операцию на sum (если sum = 0 - на весь остаток), деньги идут в обратную сторону.
+// Исходная операция блокируется на время транзакции, поэтому двойной возврат одной и той же суммы невозможен
+func (r *TransactionRepository) ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error) {
+	var reversal model.Transaction
+	err := inTx(ctx, r.db, r.retry, func(tx Querier) error {
+		var original model.Transaction
+		err := tx.QueryRowContext(ctx, "select "+transactionFields+" from transactions where id = $1 for update;", transactionId).
+			Scan(original.GetFields()...)
+		if err == sql.ErrNoRows {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return errors.Wrap(err, "filed to get transaction")
+		}
+
+		var reversedSum float32
+		err = tx.QueryRowContext(ctx, "select coalesce(sum(sum), 0) from transactions where reversed_id = $1;", transactionId).Scan(&reversedSum)
+		if err != nil {
+			return errors.Wrap(err, "filed to get reversed sum")
+		}
+
+		remaining := original.Sum - reversedSum
+		if remaining <= 0 {
+			return ErrAlreadyReversed
+		}
+		reversalSum := sum
+		if reversalSum == 0 {
+			reversalSum = remaining
+		}
+		if reversalSum > remaining {
+			return ErrReversalExceedsSum
+		}
+
+		postings, err := getPostings(ctx, tx, transactionId)
+		if err != nil {
+			return errors.Wrap(err, "filed to get postings")
+		}
+
+		// проводки исходной операции с обратным знаком: деньги возвращаются от получателя к отправителю,
+		// с учетом кредитного лимита получателя
+		transaction, _, err := post(ctx, tx, model.NewReversal(original, postings, reversalSum, info), allowNegative, r.defaultCreditLimit)
+		if err != nil {
+			return errors.Wrap(err, "filed to save reversal")
+		}
+		reversal = *transaction
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to reverse transaction %d", transactionId)
+	}
+
+	return &reversal, nil
+}
+
+func getPostings(ctx context.Context, tx Querier, transactionId int) ([]model.Posting, error) {
+	rows, err := tx.QueryContext(ctx, "select account, user_id, amount from postings where transaction_id = $1 order by id;", transactionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var postings []model.Posting
+	for rows.Next() {
+		var posting model.Posting
+		if err := rows.Scan(posting.GetFields()...); err != nil {
+			return nil, err
+		}
+		postings = append(postings, posting)
+	}
+
+	return postings, rows.Err()
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var transactionColumns = []string{"id", "type", "sender_id", "receiver_id", "sum", "order_id", "service_id", "comment", "source", "reversed_id", "created_at"}
+
+func TestTransactionRepository_GetTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, 0)
+
+	userId := "71"
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+
+	testData := []struct {
+		name                string
+		mockSqlxBehavior    func()
+		expectedTransaction *model.Transaction
+		expectedError       error
+		wantError           bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select id, type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id, created_at ` +
+					`from transactions where id = \$1;`).WithArgs(5).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(5, model.TransactionAddFunds, nil, userId, 100, "", "", "", "", nil, createdAt))
+			},
+			expectedTransaction: &model.Transaction{Id: 5, Type: model.TransactionAddFunds, ReceiverId: &userId, Sum: 100, CreatedAt: createdAt},
+		},
+		{
+			name: "Not Found",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select (.+) from transactions where id = \$1;`).WithArgs(5).WillReturnError(sql.ErrNoRows)
+			},
+			expectedError: ErrTransactionNotFound,
+			wantError:     true,
+		},
+		{
+			name: "ERR",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select (.+) from transactions where id = \$1;`).WithArgs(5).WillReturnError(fmt.Errorf("error"))
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior()
+
+			transaction, err := repo.GetTransaction(context.Background(), 5)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+				if testCase.expectedError != nil {
+					assert.True(t, errors.Is(err, testCase.expectedError))
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedTransaction, transaction)
+			}
+		})
+	}
+}
+
+func TestTransactionRepository_ReverseTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewTransactionRepository(db, 0)
+
+	senderId, receiverId, transactionId := "71", "56", 5
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	selectOriginal := `select (.+) from transactions where id = \$1 for update;`
+	selectReversed := `select coalesce\(sum\(sum\), 0\) from transactions where reversed_id = \$1;`
+	debit := `update users set balance = balance - \$1 where user_id = \$2 and \(\$3 or balance - \$1 >= -coalesce\(credit_limit, \$4\)\) returning balance;`
+	selectPostings := `select account, user_id, amount from postings where transaction_id = \$1 order by id;`
+	credit := `update users set balance = balance \+ \$1 where user_id = \$2 returning balance;`
+	insert := `insert into transactions \(type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id\) ` +
+		`values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9\) returning id, created_at;`
+	posting := `insert into postings \(transaction_id, account, user_id, amount\) values \(\$1, \$2, \$3, \$4\);`
+	postingColumns := []string{"account", "user_id", "amount"}
+
+	type args struct {
+		sum           float32
+		allowNegative bool
+		info          model.TransactionInfo
+	}
+
+	testData := []struct {
+		name                string
+		args                args
+		mockSqlxBehavior    func(args args)
+		expectedTransaction *model.Transaction
+		expectedError       error
+		wantError           bool
+	}{
+		{
+			name: "OK Full Transfer",
+			args: args{info: model.TransactionInfo{Comment: "refund"}},
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionId, model.TransactionFundsTransfer, senderId, receiverId, 100, "", "", "", "", nil, createdAt))
+				mock.ExpectQuery(selectReversed).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(30))
+				mock.ExpectQuery(selectPostings).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(postingColumns).
+					AddRow(model.AccountUser, senderId, -100).AddRow(model.AccountUser, receiverId, 100))
+				mock.ExpectQuery(debit).WithArgs(float32(70), receiverId, false, float32(0)).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(30))
+				mock.ExpectQuery(credit).WithArgs(float32(70), senderId).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(70))
+				mock.ExpectQuery(insert).
+					WithArgs(model.TransactionReversal, receiverId, senderId, float32(70), "", "", "refund", "", transactionId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(6, createdAt))
+				mock.ExpectExec(posting).WithArgs(6, model.AccountUser, senderId, float32(70)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WithArgs(6, model.AccountUser, receiverId, float32(-70)).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+			expectedTransaction: &model.Transaction{
+				Id:              6,
+				Type:            model.TransactionReversal,
+				SenderId:        &receiverId,
+				ReceiverId:      &senderId,
+				Sum:             70,
+				TransactionInfo: model.TransactionInfo{Comment: "refund"},
+				ReversedId:      &transactionId,
+				CreatedAt:       createdAt,
+			},
+		},
+		{
+			name: "OK Partial Write Off",
+			args: args{sum: 20},
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionId, model.TransactionWriteOffFunds, senderId, nil, 100, "", "", "", "", nil, createdAt))
+				mock.ExpectQuery(selectReversed).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+				mock.ExpectQuery(selectPostings).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(postingColumns).
+					AddRow(model.AccountUser, senderId, -100).AddRow(model.AccountRevenue, nil, 100))
+				mock.ExpectQuery(credit).WithArgs(float32(20), senderId).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(20))
+				mock.ExpectQuery(insert).
+					WithArgs(model.TransactionReversal, nil, senderId, float32(20), "", "", "", "", transactionId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(6, createdAt))
+				mock.ExpectExec(posting).WithArgs(6, model.AccountUser, senderId, float32(20)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WithArgs(6, model.AccountRevenue, nil, float32(-20)).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+			expectedTransaction: &model.Transaction{
+				Id:         6,
+				Type:       model.TransactionReversal,
+				ReceiverId: &senderId,
+				Sum:        20,
+				ReversedId: &transactionId,
+				CreatedAt:  createdAt,
+			},
+		},
+		{
+			name: "Not Found",
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrTransactionNotFound,
+			wantError:     true,
+		},
+		{
+			name: "Already Reversed",
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionId, model.TransactionFundsTransfer, senderId, receiverId, 100, "", "", "", "", nil, createdAt))
+				mock.ExpectQuery(selectReversed).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrAlreadyReversed,
+			wantError:     true,
+		},
+		{
+			name: "Exceeds Sum",
+			args: args{sum: 80},
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionId, model.TransactionFundsTransfer, senderId, receiverId, 100, "", "", "", "", nil, createdAt))
+				mock.ExpectQuery(selectReversed).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(30))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrReversalExceedsSum,
+			wantError:     true,
+		},
+		{
+			name: "Insufficient Funds",
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionId, model.TransactionAddFunds, nil, receiverId, 100, "", "", "", "", nil, createdAt))
+				mock.ExpectQuery(selectReversed).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+				mock.ExpectQuery(selectPostings).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(postingColumns).
+					AddRow(model.AccountCashIn, nil, -100).AddRow(model.AccountUser, receiverId, 100))
+				mock.ExpectQuery(debit).WithArgs(float32(100), receiverId, false, float32(0)).WillReturnRows(sqlmock.NewRows([]string{"balance"}))
+				mock.ExpectRollback()
+			},
+			expectedError: ErrInsufficientFunds,
+			wantError:     true,
+		},
+		{
+			name: "Error in Begin",
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+		{
+			name: "Error in Insert",
+			args: args{allowNegative: true},
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectOriginal).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(transactionColumns).
+					AddRow(transactionId, model.TransactionAddFunds, nil, receiverId, 100, "", "", "", "", nil, createdAt))
+				mock.ExpectQuery(selectReversed).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+				mock.ExpectQuery(selectPostings).WithArgs(transactionId).WillReturnRows(sqlmock.NewRows(postingColumns).
+					AddRow(model.AccountCashIn, nil, -100).AddRow(model.AccountUser, receiverId, 100))
+				mock.ExpectQuery(debit).WithArgs(float32(100), receiverId, true, float32(0)).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(30))
+				mock.ExpectQuery(insert).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior(testCase.args)
+
+			transaction, err := repo.ReverseTransaction(context.Background(), transactionId, testCase.args.sum, testCase.args.allowNegative, testCase.args.info)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+				if testCase.expectedError != nil {
+					assert.True(t, errors.Is(err, testCase.expectedError))
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedTransaction, transaction)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
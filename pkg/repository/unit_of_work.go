@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"github.com/pkg/errors"
+)
+
+// Querier общее у *sql.DB и *sql.Tx: репозитории работают с бд напрямую или внутри транзакции unit of work
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type PostgresUnitOfWork struct {
+	db                 *sql.DB
+	defaultCreditLimit float32
+}
+
+func NewUnitOfWork(db *sql.DB, defaultCreditLimit float32) *PostgresUnitOfWork {
+	return &PostgresUnitOfWork{db: db, defaultCreditLimit: defaultCreditLimit}
+}
+
+// Begin открывает транзакцию, все вызовы репозиториев из Tx идут в ней
+func (u *PostgresUnitOfWork) Begin(ctx context.Context) (Tx, error) {
+	tx, err := u.db.BeginTx(ctx, txOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "filed to begin unit of work")
+	}
+	return &postgresTx{
+		Tx:                    tx,
+		UserRepository:        NewUserRepository(tx, u.defaultCreditLimit),
+		TransactionRepository: NewTransactionRepository(tx, u.defaultCreditLimit),
+		LedgerRepository:      NewLedgerRepository(tx),
+		AuditRepository:       NewAuditRepository(tx),
+	}, nil
+}
+
+type postgresTx struct {
+	*sql.Tx
+	*UserRepository
+	*TransactionRepository
+	*LedgerRepository
+	*AuditRepository
+}
+
+// InTx выполняет fn в транзакции unit of work и коммитит ее. Если транзакция упала с ошибкой сериализации или дедлоком,
+// fn повторяется целиком в новой транзакции до r.Retry.MaxAttempts раз, после этого - ErrRetriesExhausted.
+// Ошибки fn возвращаются как есть, fn не должна менять ничего кроме бд: она может выполниться несколько раз
+func (r *Repository) InTx(ctx context.Context, fn func(tx Tx) error) error {
+	return retry(ctx, r.Retry, func() error {
+		tx, err := r.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	insertUser := `insert into users \(user_id, balance, external_ref\)`
+	update := `update users set balance = balance \+ \$1 where user_id = \$2`
+	insert := `insert into transactions \(type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id\)`
+	posting := `insert into postings`
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		commit           bool
+		wantError        bool
+	}{
+		{
+			name: "OK",
+			// методы репозитория не открывают свои транзакции, все идет в одной
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertUser).WithArgs("71", float32(0), "", float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, 71, 0, 500, "active", "", createdAt, createdAt))
+				mock.ExpectQuery(update).WithArgs(float32(20), "71").
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(20))
+				mock.ExpectQuery(insert).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+			commit: true,
+		},
+		{
+			name: "Rollback",
+			// ошибка начисления откатывает и созданного юзера
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertUser).WithArgs("71", float32(0), "", float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, 71, 0, 500, "active", "", createdAt, createdAt))
+				mock.ExpectQuery(update).WithArgs(float32(20), "71").WillReturnError(errors.Errorf("lol kek cheburek."))
+				mock.ExpectRollback()
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			tx, err := NewUnitOfWork(db, 500).Begin(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = tx.CreateUser(context.Background(), "71", 0, "")
+			assert.NoError(t, err)
+			_, err = tx.Post(context.Background(), model.NewCredit("71", 20, model.TransactionInfo{}))
+			if err == nil && testCase.commit {
+				err = tx.Commit()
+			}
+			_ = tx.Rollback()
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUnitOfWork_Begin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin().WillReturnError(errors.Errorf("lol kek cheburek."))
+
+	_, err = NewUnitOfWork(db, 500).Begin(context.Background())
+
+	// assert
+	assert.EqualError(t, err, "filed to begin unit of work: lol kek cheburek.")
+}
+
+func TestRepository_InTx(t *testing.T) {
+	update := `update users set balance = balance \+ \$1 where user_id = \$2`
+	insert := `insert into transactions \(type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id\)`
+	posting := `insert into postings`
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	retry := RetryConfig{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+	uniqueViolation := &pq.Error{Code: "23505"}
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedCalls    int
+		expectedError    error
+	}{
+		{
+			name: "OK After Serialization Failure in Commit",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(120))
+				mock.ExpectQuery(insert).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit().WillReturnError(&pq.Error{Code: "40001"})
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(120))
+				mock.ExpectQuery(insert).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(2, createdAt))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(3, 1))
+				mock.ExpectExec(posting).WillReturnResult(sqlmock.NewResult(4, 1))
+				mock.ExpectCommit()
+			},
+			expectedCalls: 2,
+		},
+		{
+			name: "ERR Retries Exhausted",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				for i := 0; i < 2; i++ {
+					mock.ExpectBegin()
+					mock.ExpectQuery(update).WillReturnError(&pq.Error{Code: "40P01"})
+					mock.ExpectRollback()
+				}
+			},
+			expectedCalls: 2,
+			expectedError: ErrRetriesExhausted,
+		},
+		{
+			name: "ERR Not Retryable",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WillReturnError(uniqueViolation)
+				mock.ExpectRollback()
+			},
+			expectedCalls: 1,
+			expectedError: uniqueViolation,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			repo := &Repository{UnitOfWork: NewUnitOfWork(db, 500), Retry: retry}
+
+			// test
+			calls := 0
+			err = repo.InTx(context.Background(), func(tx Tx) error {
+				calls++
+				_, err := tx.Post(context.Background(), model.NewCredit("71", 20, model.TransactionInfo{}))
+				return err
+			})
+
+			// assert
+			if testCase.expectedError != nil {
+				assert.True(t, errors.Is(err, testCase.expectedError), err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, testCase.expectedCalls, calls)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
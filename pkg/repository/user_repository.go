@@ -1,99 +1,266 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"for_avito_tech_with_gin/pkg/model"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"time"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db                 Querier
+	replica            *sql.DB
+	retry              RetryConfig
+	defaultCreditLimit float32
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+// NewUserRepository defaultCreditLimit - кредитный лимит для юзеров, у которых не задан свой
+func NewUserRepository(db Querier, defaultCreditLimit float32) *UserRepository {
+	return &UserRepository{db: db, defaultCreditLimit: defaultCreditLimit}
 }
 
-func (r *UserRepository) CreateUser(userId int, balance float32) error {
-	_, err := r.db.Query("insert into users (user_id, balance) values ($1, $2);", userId, balance)
+// WithReplica GetUser, IsUserExist и GetTransactions с контекстом FromReplica читают из replica, nil - из основной бд
+func (r *UserRepository) WithReplica(replica *sql.DB) *UserRepository {
+	r.replica = replica
+	return r
+}
+
+// WithRetry повторы Post и SetStatus при ошибках сериализации и дедлоках
+func (r *UserRepository) WithRetry(c RetryConfig) *UserRepository {
+	r.retry = c
+	return r
+}
+
+// reader реплика для чтений, которые допускают отставание, остальное - основная бд
+func (r *UserRepository) reader(ctx context.Context) Querier {
+	if r.replica != nil && isFromReplica(ctx) {
+		return r.replica
+	}
+	return r.db
+}
+
+// CreateUser создает юзера, если юзер с таким userId уже есть - ErrUserAlreadyExists
+func (r *UserRepository) CreateUser(ctx context.Context, userId string, balance float32, externalRef string) (*model.User, error) {
+	var user model.User
+	err := r.db.QueryRowContext(ctx, "insert into users (user_id, balance, external_ref) values ($1, $2, $3) on conflict (user_id) do nothing "+
+		"returning "+userFields(4)+";", userId, balance, externalRef, r.defaultCreditLimit).Scan(user.GetFields()...)
+	if err == sql.ErrNoRows {
+		return nil, errors.Wrapf(ErrUserAlreadyExists, "filed to create user %s", userId)
+	}
 	if err != nil {
-		return errors.Wrapf(err, "filed to create user %d", userId)
+		return nil, errors.Wrapf(err, "filed to create user %s", userId)
 	}
-	return nil
+	return &user, nil
 }
 
-func (r *UserRepository) GetUser(userId int) (*model.User, error) {
+func (r *UserRepository) GetUser(ctx context.Context, userId string) (*model.User, error) {
 	var user model.User
-	err := r.db.QueryRow("select id, user_id, balance from users where user_id = $1", userId).Scan(user.GetFields()...)
+	err := r.reader(ctx).QueryRowContext(ctx, "select "+userFields(2)+" from users where user_id = $1;", userId, r.defaultCreditLimit).
+		Scan(user.GetFields()...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "filed to get user %d", userId)
+		return nil, errors.Wrapf(err, "filed to get user %s", userId)
 	}
 
 	return &user, err
 }
 
-func (r *UserRepository) IsUserExist(userId int) (bool, error) {
+// GetUserForUpdate достает юзера и блокирует его строку до конца транзакции: параллельная операция с этим юзером ждет
+// блокировку, а после коммита текущей падает с ошибкой сериализации и повторяется целиком. Имеет смысл только внутри
+// unit of work
+func (r *UserRepository) GetUserForUpdate(ctx context.Context, userId string) (*model.User, error) {
+	var user model.User
+	err := r.db.QueryRowContext(ctx, "select "+userFields(2)+" from users where user_id = $1 for update;", userId, r.defaultCreditLimit).
+		Scan(user.GetFields()...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to lock user %s", userId)
+	}
+
+	return &user, nil
+}
+
+// GetUsersForUpdate как GetUserForUpdate, но для нескольких юзеров одним запросом. Строки блокируются по порядку user_id,
+// как и в Post, поэтому встречные переводы не ловят дедлок. Юзеров, которых нет, нет и в результате
+func (r *UserRepository) GetUsersForUpdate(ctx context.Context, userIds ...string) (map[string]*model.User, error) {
+	rows, err := r.db.QueryContext(ctx, "select "+userFields(2)+" from users where user_id = any($1) order by user_id for update;",
+		pq.Array(userIds), r.defaultCreditLimit)
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to lock users %v", userIds)
+	}
+	defer rows.Close()
+
+	users := make(map[string]*model.User, len(userIds))
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(user.GetFields()...); err != nil {
+			return nil, errors.Wrap(err, "filed to scan user")
+		}
+		users[user.UserId] = &user
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "filed to lock users %v", userIds)
+	}
+
+	return users, nil
+}
+
+func (r *UserRepository) IsUserExist(ctx context.Context, userId string) (bool, error) {
 	var c int
-	err := r.db.QueryRow("select count(1) from users where user_id = $1;", userId).Scan(&c)
+	err := r.reader(ctx).QueryRowContext(ctx, "select count(1) from users where user_id = $1;", userId).Scan(&c)
 	if err != nil {
-		return false, errors.Wrapf(err, "filed to check is user %d exist", userId)
+		return false, errors.Wrapf(err, "filed to check is user %s exist", userId)
 	}
 
 	return c > 0, nil
 }
 
-func (r *UserRepository) UpdateBalance(userId int, sum float32) (*model.User, error) {
-	var user model.User
+// Post проводит операцию entry (начисление, списание, перевод): меняет балансы кошельков и пишет операцию с проводками в журнал.
+// Кошелек уходит в минус, только если хватает кредитного лимита, иначе ErrInsufficientFunds
+func (r *UserRepository) Post(ctx context.Context, entry model.JournalEntry) (*model.OperationResult, error) {
+	var result *model.OperationResult
+	err := inTx(ctx, r.db, r.retry, func(tx Querier) error {
+		transaction, balances, err := post(ctx, tx, entry, false, r.defaultCreditLimit)
+		if err != nil {
+			return err
+		}
 
-	tx, err := r.db.Begin()
+		result = &model.OperationResult{Transaction: transaction}
+		sender, receiver := balanceOf(balances, transaction.SenderId), balanceOf(balances, transaction.ReceiverId)
+		switch {
+		case sender != nil && receiver != nil:
+			result.SenderBalance, result.ReceiverBalance = sender, receiver
+		case sender != nil:
+			result.Balance = sender
+		default:
+			result.Balance = receiver
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.Wrapf(err, "filed to begin transaction and update balance for user %d", userId)
+		return nil, errors.Wrapf(err, "filed to post %s", entry.Type)
 	}
-	defer tx.Rollback()
 
-	err = tx.QueryRow("select id, user_id, balance from users where user_id = $1;", userId).Scan(user.GetFields()...)
+	return result, nil
+}
+
+// SetCreditLimit задает юзеру кредитный лимит, nil - вернуть лимит по умолчанию
+func (r *UserRepository) SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) (*model.User, error) {
+	var user model.User
+	err := r.db.QueryRowContext(ctx, "update users set credit_limit = $1 where user_id = $2 returning "+userFields(3)+";",
+		creditLimit, userId, r.defaultCreditLimit).Scan(user.GetFields()...)
 	if err != nil {
-		return nil, errors.Wrapf(err, "filed to get user and update balance for user %d", userId)
+		return nil, errors.Wrapf(err, "filed to set credit limit for user %s", userId)
 	}
 
-	err = tx.QueryRow("update users set balance = $1 where user_id = $2;", user.Balance+sum, userId).Scan(user.GetFields()...)
+	return &user, nil
+}
+
+// SetStatus меняет статус юзера и сохраняет смену статуса вместе с причиной в историю. Закрыть можно только юзера
+// с нулевым балансом, иначе - ErrNonZeroBalance, а закрытого юзера уже не переоткрыть - ErrUserClosed. Оба условия
+// проверяются в том же update, параллельное начисление или смена статуса не проскочат
+func (r *UserRepository) SetStatus(ctx context.Context, userId string, status string, reason string) error {
+	err := inTx(ctx, r.db, r.retry, func(tx Querier) error {
+		res, err := tx.ExecContext(ctx, "update users set status = $1 where user_id = $2 and status <> 'closed' and ($1 <> 'closed' or balance = 0);",
+			status, userId)
+		if err != nil {
+			return err
+		}
+		updated, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			var closed bool
+			err := tx.QueryRowContext(ctx, "select status = 'closed' from users where user_id = $1;", userId).Scan(&closed)
+			if err != nil {
+				return errors.Wrap(err, "filed to get status")
+			}
+			if closed {
+				return ErrUserClosed
+			}
+			return ErrNonZeroBalance
+		}
+
+		_, err = tx.ExecContext(ctx, "insert into user_status_changes (user_id, status, reason) values ($1, $2, $3);", userId, status, reason)
+		if err != nil {
+			return errors.Wrap(err, "filed to save status change")
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.Wrapf(err, "filed update balance for user %d", userId)
+		return errors.Wrapf(err, "filed to set status for user %s", userId)
 	}
 
-	return &user, tx.Commit()
+	return nil
 }
 
-func (r *UserRepository) CreateFundsTransaction(senderId int, receiverId int, sum float32) error {
+// GetLimits возвращает персональные лимиты юзера, если они не заданы - пустые Limits
+func (r *UserRepository) GetLimits(ctx context.Context, userId string) (*model.Limits, error) {
+	var limits model.Limits
+	err := r.db.QueryRowContext(ctx, "select max_transaction_sum, daily_debit, monthly_debit, daily_transfer, monthly_transfer, hourly_transfers "+
+		"from user_limits where user_id = $1;", userId).Scan(limits.GetFields()...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrapf(err, "filed to get limits of user %s", userId)
+	}
 
-	var sender model.User
-	var receiver model.User
+	return &limits, nil
+}
 
-	tx, err := r.db.Begin()
+// SetLimits заменяет персональные лимиты юзера, nil значения - действуют лимиты из конфига
+func (r *UserRepository) SetLimits(ctx context.Context, userId string, limits model.Limits) error {
+	_, err := r.db.ExecContext(ctx, "insert into user_limits (user_id, max_transaction_sum, daily_debit, monthly_debit, daily_transfer, monthly_transfer, hourly_transfers) "+
+		"values ($1, $2, $3, $4, $5, $6, $7) on conflict (user_id) do update set "+
+		"max_transaction_sum = excluded.max_transaction_sum, daily_debit = excluded.daily_debit, monthly_debit = excluded.monthly_debit, "+
+		"daily_transfer = excluded.daily_transfer, monthly_transfer = excluded.monthly_transfer, hourly_transfers = excluded.hourly_transfers;",
+		userId, limits.MaxTransactionSum, limits.DailyDebit, limits.MonthlyDebit, limits.DailyTransfer, limits.MonthlyTransfer, limits.HourlyTransfers)
 	if err != nil {
-		return errors.Wrapf(err, "filed to begin transaction and create transaction between %d and %d users", senderId, receiverId)
+		return errors.Wrapf(err, "filed to set limits of user %s", userId)
 	}
-	defer tx.Rollback()
+	return nil
+}
 
-	err = tx.QueryRow("select id, user_id, balance from users where user_id = $1;", senderId).Scan(sender.GetFields()...)
+// GetSpending возвращает сумму и количество операций типа transactionType, которые юзер отправил начиная с since.
+// created_at - timestamptz, поэтому since сравнивается как момент времени, независимо от TimeZone сервера бд
+func (r *UserRepository) GetSpending(ctx context.Context, userId string, transactionType string, since time.Time) (float32, int, error) {
+	var sum float32
+	var count int
+	err := r.db.QueryRowContext(ctx, "select coalesce(sum(sum), 0), count(1) from transactions where sender_id = $1 and type = $2 and created_at >= $3;",
+		userId, transactionType, since).Scan(&sum, &count)
 	if err != nil {
-		return errors.Wrapf(err, "filed to get user %d and create transaction between %d and %d users", senderId, senderId, receiverId)
+		return 0, 0, errors.Wrapf(err, "filed to get %s spending of user %s", transactionType, userId)
 	}
 
-	err = tx.QueryRow("select id, user_id, balance from users where user_id = $1;", receiverId).Scan(receiver.GetFields()...)
-	if err != nil {
-		return errors.Wrapf(err, "filed to get user %d and create transaction between %d and %d users", receiverId, senderId, receiverId)
-	}
+	return sum, count, nil
+}
 
-	_, err = tx.Exec("update users set balance = $1 where user_id = $2;", sender.Balance-sum, senderId)
+// GetTransactions возвращает историю операций юзера, сначала новые
+func (r *UserRepository) GetTransactions(ctx context.Context, userId string) ([]model.Transaction, error) {
+	rows, err := r.reader(ctx).QueryContext(ctx, "select "+transactionFields+" from transactions where sender_id = $1 or receiver_id = $1 order by created_at desc, id desc;", userId)
 	if err != nil {
-		return errors.Wrapf(err, "filed to update user %d and create transaction between %d and %d users", senderId, senderId, receiverId)
+		return nil, errors.Wrapf(err, "filed to get transactions of user %s", userId)
 	}
+	defer rows.Close()
 
-	_, err = tx.Exec("update users set balance = $1 where user_id = $2;", receiver.Balance+sum, receiverId)
-	if err != nil {
-		return errors.Wrapf(err, "filed to update user %d and create transaction between %d and %d users", senderId, senderId, receiverId)
+	transactions := make([]model.Transaction, 0)
+	for rows.Next() {
+		var transaction model.Transaction
+		if err := rows.Scan(transaction.GetFields()...); err != nil {
+			return nil, errors.Wrapf(err, "filed to scan transaction of user %s", userId)
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "filed to get transactions of user %s", userId)
 	}
 
-	return tx.Commit()
+	return transactions, nil
 }
+
+// userFields поля юзера в порядке model.User.GetFields(), creditLimitParam - номер параметра с кредитным лимитом по умолчанию
+func userFields(creditLimitParam int) string {
+	return fmt.Sprintf("id, user_id, balance, coalesce(credit_limit, $%d), status, external_ref, created_at, updated_at", creditLimitParam)
+}
+
+const transactionFields = "id, type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id, created_at"
@@ -1,13 +1,20 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"for_avito_tech_with_gin/pkg/model"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
+var userColumns = []string{"id", "user_id", "balance", "credit_limit", "status", "external_ref", "created_at", "updated_at"}
+
 func TestUserRepository_CreateUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -15,39 +22,62 @@ func TestUserRepository_CreateUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, 500)
+
+	insert := `insert into users \(user_id, balance, external_ref\) values \(\$1, \$2, \$3\) on conflict \(user_id\) do nothing ` +
+		`returning id, user_id, balance, coalesce\(credit_limit, \$4\), status, external_ref, created_at, updated_at;`
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
 
 	type args struct {
-		userId  int
-		balance float32
+		userId      string
+		balance     float32
+		externalRef string
 	}
 
 	testData := []struct {
 		name             string
 		args             args
 		mockSqlxBehavior func(args args)
+		expectedUser     model.User
+		expectedError    error
 		wantError        bool
 	}{
 		{
 			name: "OK",
 			args: args{
-				userId:  71,
-				balance: 1000,
+				userId:      "71",
+				balance:     1000,
+				externalRef: "crm-71",
 			},
 			mockSqlxBehavior: func(args args) {
-				mock.ExpectQuery(`insert into users \(user_id, balance\) values \(\$1, \$2\);`).WithArgs(args.userId, args.balance).
-					WillReturnRows(sqlmock.NewRows([]string{}))
+				mock.ExpectQuery(insert).WithArgs(args.userId, args.balance, args.externalRef, float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow(1, 71, 1000, 500, "active", "crm-71", createdAt, createdAt))
 			},
+			expectedUser: model.User{Id: 1, UserId: "71", Balance: 1000, CreditLimit: 500, Status: model.UserStatusActive,
+				ExternalRef: "crm-71", CreatedAt: createdAt, UpdatedAt: createdAt},
 			wantError: false,
 		},
+		{
+			name: "Already Exists",
+			args: args{
+				userId:  "71",
+				balance: 1000,
+			},
+			mockSqlxBehavior: func(args args) {
+				mock.ExpectQuery(insert).WithArgs(args.userId, args.balance, args.externalRef, float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns))
+			},
+			expectedError: ErrUserAlreadyExists,
+			wantError:     true,
+		},
 		{
 			name: "ERR",
 			args: args{
-				userId:  71,
+				userId:  "71",
 				balance: 1000,
 			},
 			mockSqlxBehavior: func(args args) {
-				mock.ExpectQuery(`insert into users \(user_id, balance\) values \(\$1, \$2\);`).WithArgs(args.userId, args.balance).
+				mock.ExpectQuery(insert).WithArgs(args.userId, args.balance, args.externalRef, float32(500)).
 					WillReturnError(fmt.Errorf("error"))
 			},
 			wantError: true,
@@ -58,13 +88,17 @@ func TestUserRepository_CreateUser(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.mockSqlxBehavior(testCase.args)
 
-			err := repo.CreateUser(testCase.args.userId, testCase.args.balance)
+			user, err := repo.CreateUser(context.Background(), testCase.args.userId, testCase.args.balance, testCase.args.externalRef)
 
 			// assert
 			if testCase.wantError {
 				assert.Error(t, err)
+				if testCase.expectedError != nil {
+					assert.True(t, errors.Is(err, testCase.expectedError))
+				}
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedUser, *user)
 			}
 		})
 	}
@@ -77,10 +111,10 @@ func TestUserRepository_GetUser(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, 500)
 
 	type args struct {
-		userId int
+		userId string
 	}
 
 	testData := []struct {
@@ -93,28 +127,51 @@ func TestUserRepository_GetUser(t *testing.T) {
 		{
 			name: "OK",
 			args: args{
-				userId: 71,
+				userId: "71",
 			},
 			mockSqlxBehavior: func(args args, user model.User) {
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1`).WithArgs(args.userId).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-						AddRow(user.Id, user.UserId, user.Balance))
+				mock.ExpectQuery(`select id, user_id, balance, coalesce\(credit_limit, \$2\), status, external_ref, created_at, updated_at from users where user_id = \$1;`).
+					WithArgs(args.userId, float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).
+						AddRow(user.Id, user.UserId, user.Balance, user.CreditLimit, user.Status, user.ExternalRef, user.CreatedAt, user.UpdatedAt))
 			},
 			expectedUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 2000,
+				Id:          71,
+				UserId:      "71",
+				Balance:     2000,
+				CreditLimit: 500,
+				Status:      model.UserStatusFrozen,
+			},
+			wantError: false,
+		},
+		{
+			name: "OK UUID",
+			args: args{
+				userId: "3f2b8c1e-9d4a-4e5b-8c7d-1a2b3c4d5e6f",
+			},
+			mockSqlxBehavior: func(args args, user model.User) {
+				mock.ExpectQuery(`select id, user_id, balance, coalesce\(credit_limit, \$2\), status, external_ref, created_at, updated_at from users where user_id = \$1;`).
+					WithArgs(args.userId, float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).
+						AddRow(user.Id, user.UserId, user.Balance, user.CreditLimit, user.Status, user.ExternalRef, user.CreatedAt, user.UpdatedAt))
+			},
+			expectedUser: model.User{
+				Id:          72,
+				UserId:      "3f2b8c1e-9d4a-4e5b-8c7d-1a2b3c4d5e6f",
+				Balance:     100,
+				CreditLimit: 500,
+				Status:      model.UserStatusActive,
 			},
 			wantError: false,
 		},
 		{
 			name: "ERR",
 			args: args{
-				userId: 71,
+				userId: "71",
 			},
 			mockSqlxBehavior: func(args args, user model.User) {
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1`).WithArgs(args.userId).
-					WillReturnError(fmt.Errorf("error"))
+				mock.ExpectQuery(`select id, user_id, balance, coalesce\(credit_limit, \$2\), status, external_ref, created_at, updated_at from users where user_id = \$1;`).
+					WithArgs(args.userId, float32(500)).WillReturnError(fmt.Errorf("error"))
 			},
 			expectedUser: model.User{},
 			wantError:    true,
@@ -125,7 +182,7 @@ func TestUserRepository_GetUser(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.mockSqlxBehavior(testCase.args, testCase.expectedUser)
 
-			user, err := repo.GetUser(testCase.args.userId)
+			user, err := repo.GetUser(context.Background(), testCase.args.userId)
 
 			// assert
 			if testCase.wantError {
@@ -138,6 +195,129 @@ func TestUserRepository_GetUser(t *testing.T) {
 	}
 }
 
+func TestUserRepository_GetUserForUpdate(t *testing.T) {
+	selectUser := `select id, user_id, balance, coalesce\(credit_limit, \$2\), status, external_ref, created_at, updated_at from users ` +
+		`where user_id = \$1 for update;`
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedUser     *model.User
+		wantError        bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectUser).WithArgs("71", float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow(71, "71", 2000, 500, model.UserStatusActive, "", time.Time{}, time.Time{}))
+			},
+			expectedUser: &model.User{Id: 71, UserId: "71", Balance: 2000, CreditLimit: 500, Status: model.UserStatusActive},
+		},
+		{
+			name: "ERR",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectUser).WithArgs("71", float32(500)).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			user, err := NewUserRepository(db, 500).GetUserForUpdate(context.Background(), "71")
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedUser, user)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUserRepository_GetUsersForUpdate(t *testing.T) {
+	selectUsers := `select id, user_id, balance, coalesce\(credit_limit, \$2\), status, external_ref, created_at, updated_at from users ` +
+		`where user_id = any\(\$1\) order by user_id for update;`
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func(mock sqlmock.Sqlmock)
+		expectedUsers    map[string]*model.User
+		wantError        bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectUsers).WithArgs(pq.Array([]string{"72", "71"}), float32(500)).WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(71, "71", 2000, 500, model.UserStatusActive, "", time.Time{}, time.Time{}).
+					AddRow(72, "72", 0, 500, model.UserStatusFrozen, "", time.Time{}, time.Time{}))
+			},
+			expectedUsers: map[string]*model.User{
+				"71": {Id: 71, UserId: "71", Balance: 2000, CreditLimit: 500, Status: model.UserStatusActive},
+				"72": {Id: 72, UserId: "72", CreditLimit: 500, Status: model.UserStatusFrozen},
+			},
+		},
+		{
+			// юзера 72 еще нет
+			name: "OK Not Exist",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectUsers).WithArgs(pq.Array([]string{"72", "71"}), float32(500)).WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(71, "71", 2000, 500, model.UserStatusActive, "", time.Time{}, time.Time{}))
+			},
+			expectedUsers: map[string]*model.User{
+				"71": {Id: 71, UserId: "71", Balance: 2000, CreditLimit: 500, Status: model.UserStatusActive},
+			},
+		},
+		{
+			name: "ERR",
+			mockSqlxBehavior: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectUsers).WithArgs(pq.Array([]string{"72", "71"}), float32(500)).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+			testCase.mockSqlxBehavior(mock)
+
+			// test
+			users, err := NewUserRepository(db, 500).GetUsersForUpdate(context.Background(), "72", "71")
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedUsers, users)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestUserRepository_IsUserExist(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -145,10 +325,10 @@ func TestUserRepository_IsUserExist(t *testing.T) {
 	}
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, 500)
 
 	type args struct {
-		userId int
+		userId string
 	}
 
 	testData := []struct {
@@ -161,7 +341,7 @@ func TestUserRepository_IsUserExist(t *testing.T) {
 		{
 			name: "OK True",
 			args: args{
-				userId: 71,
+				userId: "71",
 			},
 			mockSqlxBehavior: func(args args) {
 				mock.ExpectQuery(`select count\(1\) from users where user_id = \$1`).WithArgs(args.userId).
@@ -173,7 +353,7 @@ func TestUserRepository_IsUserExist(t *testing.T) {
 		{
 			name: "OK False",
 			args: args{
-				userId: 71,
+				userId: "71",
 			},
 			mockSqlxBehavior: func(args args) {
 				mock.ExpectQuery(`select count\(1\) from users where user_id = \$1`).WithArgs(args.userId).
@@ -185,7 +365,7 @@ func TestUserRepository_IsUserExist(t *testing.T) {
 		{
 			name: "ERR",
 			args: args{
-				userId: 71,
+				userId: "71",
 			},
 			mockSqlxBehavior: func(args args) {
 				mock.ExpectQuery(`select count\(1\) from users where user_id = \$1`).WithArgs(args.userId).
@@ -199,7 +379,7 @@ func TestUserRepository_IsUserExist(t *testing.T) {
 		t.Run(testCase.name, func(t *testing.T) {
 			testCase.mockSqlxBehavior(testCase.args)
 
-			ex, err := repo.IsUserExist(testCase.args.userId)
+			ex, err := repo.IsUserExist(context.Background(), testCase.args.userId)
 
 			// assert
 			if testCase.wantError {
@@ -212,127 +392,160 @@ func TestUserRepository_IsUserExist(t *testing.T) {
 	}
 }
 
-func TestUserRepository_UpdateBalance(t *testing.T) {
+func TestUserRepository_Post(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, 500)
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	senderId, receiverId := "71", "56"
+	creditBalance, debitBalance := float32(100), float32(-120)
+	senderBalance, receiverBalance := float32(-100), float32(1300)
 
-	type args struct {
-		userId int
-		sum    float32
-	}
+	debit := `update users set balance = balance - \$1 where user_id = \$2 and \(\$3 or balance - \$1 >= -coalesce\(credit_limit, \$4\)\) returning balance;`
+	credit := `update users set balance = balance \+ \$1 where user_id = \$2 returning balance;`
+	insert := `insert into transactions \(type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id\)`
+	posting := `insert into postings \(transaction_id, account, user_id, amount\) values \(\$1, \$2, \$3, \$4\);`
 
 	testData := []struct {
 		name             string
-		args             args
-		originalUser     model.User
-		mockSqlxBehavior func(args args, origUser model.User, exUser model.User)
-		expectedUser     model.User
+		entry            model.JournalEntry
+		mockSqlxBehavior func()
+		expectedResult   *model.OperationResult
+		expectedError    error
 		wantError        bool
 	}{
 		{
-			name: "OK +",
-			args: args{
-				userId: 71,
-				sum:    20,
-			},
-			mockSqlxBehavior: func(args args, origUser model.User, exUser model.User) {
+			name:  "OK Credit",
+			entry: model.NewCredit("71", 20, model.TransactionInfo{OrderId: "17", Comment: "top up"}),
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.userId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(origUser.Id, origUser.UserId, origUser.Balance))
-				mock.ExpectQuery(`update users set balance = \$1 where user_id = \$2;`).
-					WithArgs(origUser.Balance+args.sum, args.userId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(exUser.Id, exUser.UserId, exUser.Balance))
+				mock.ExpectQuery(credit).WithArgs(float32(20), "71").
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(100))
+				mock.ExpectQuery(insert).
+					WithArgs(model.TransactionAddFunds, nil, "71", float32(20), "17", "", "top up", "", nil).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WithArgs(1, model.AccountCashIn, nil, float32(-20)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WithArgs(1, model.AccountUser, "71", float32(20)).WillReturnResult(sqlmock.NewResult(2, 1))
 				mock.ExpectCommit()
 			},
-			originalUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 80,
-			},
-			expectedUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 100,
+			expectedResult: &model.OperationResult{
+				Transaction: &model.Transaction{Id: 1, Type: model.TransactionAddFunds, ReceiverId: &senderId, Sum: 20,
+					TransactionInfo: model.TransactionInfo{OrderId: "17", Comment: "top up"}, CreatedAt: createdAt},
+				Balance: &creditBalance,
 			},
 			wantError: false,
 		},
 		{
-			name: "OK -",
-			args: args{
-				userId: 71,
-				sum:    -20,
-			},
-			mockSqlxBehavior: func(args args, origUser model.User, exUser model.User) {
+			name:  "OK Write Off Into Credit",
+			entry: model.NewWriteOff("71", 200, model.TransactionInfo{}),
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.userId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(origUser.Id, origUser.UserId, origUser.Balance))
-				mock.ExpectQuery(`update users set balance = \$1 where user_id = \$2;`).
-					WithArgs(origUser.Balance+args.sum, args.userId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(exUser.Id, exUser.UserId, exUser.Balance))
+				mock.ExpectQuery(debit).WithArgs(float32(200), "71", false, float32(500)).
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(-120))
+				mock.ExpectQuery(insert).
+					WithArgs(model.TransactionWriteOffFunds, "71", nil, float32(200), "", "", "", "", nil).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WithArgs(1, model.AccountUser, "71", float32(-200)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WithArgs(1, model.AccountRevenue, nil, float32(200)).WillReturnResult(sqlmock.NewResult(2, 1))
 				mock.ExpectCommit()
 			},
-			originalUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 80,
+			expectedResult: &model.OperationResult{
+				Transaction: &model.Transaction{Id: 1, Type: model.TransactionWriteOffFunds, SenderId: &senderId, Sum: 200, CreatedAt: createdAt},
+				Balance:     &debitBalance,
 			},
-			expectedUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 60,
+			wantError: false,
+		},
+		{
+			// кошельки меняются по порядку user_id: сначала получатель 56, потом отправитель 71
+			name:  "OK Transfer",
+			entry: model.NewTransfer("71", "56", 300, model.TransactionInfo{ServiceId: "delivery"}),
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(credit).WithArgs(float32(300), "56").
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1300))
+				mock.ExpectQuery(debit).WithArgs(float32(300), "71", false, float32(500)).
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(-100))
+				mock.ExpectQuery(insert).
+					WithArgs(model.TransactionFundsTransfer, "71", "56", float32(300), "", "delivery", "", "", nil).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WithArgs(1, model.AccountUser, "71", float32(-300)).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(posting).WithArgs(1, model.AccountUser, "56", float32(300)).WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectCommit()
+			},
+			expectedResult: &model.OperationResult{
+				Transaction: &model.Transaction{Id: 1, Type: model.TransactionFundsTransfer, SenderId: &senderId, ReceiverId: &receiverId,
+					Sum: 300, TransactionInfo: model.TransactionInfo{ServiceId: "delivery"}, CreatedAt: createdAt},
+				SenderBalance:   &senderBalance,
+				ReceiverBalance: &receiverBalance,
 			},
 			wantError: false,
 		},
 		{
-			name: "Error in Begin",
-			mockSqlxBehavior: func(args args, user model.User, exUser model.User) {
+			name:  "Over Credit Limit",
+			entry: model.NewWriteOff("71", 1000, model.TransactionInfo{}),
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(debit).WithArgs(float32(1000), "71", false, float32(500)).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedError: ErrInsufficientFunds,
+			wantError:     true,
+		},
+		{
+			name: "Unbalanced Entry",
+			entry: model.JournalEntry{Type: model.TransactionAddFunds, Postings: []model.Posting{
+				{Account: model.AccountUser, UserId: &senderId, Amount: 20},
+			}},
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			expectedError: ErrUnbalancedEntry,
+			wantError:     true,
+		},
+		{
+			name:  "Error in Begin",
+			entry: model.NewCredit("71", 20, model.TransactionInfo{}),
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
 			},
 			wantError: true,
 		},
 		{
-			name: "ERR bad args",
-			mockSqlxBehavior: func(args args, origUser model.User, exUser model.User) {
+			name:  "Error in Update",
+			entry: model.NewCredit("71", 20, model.TransactionInfo{}),
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(nil).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(origUser.Id, origUser.UserId, origUser.Balance).RowError(1, fmt.Errorf("some error")))
+				mock.ExpectQuery(credit).WithArgs(float32(20), "71").WillReturnError(fmt.Errorf("some error"))
 				mock.ExpectRollback()
 			},
 			wantError: true,
 		},
 		{
-			name: "Error in Get 1",
-			args: args{
-				userId: 71,
-			},
-			mockSqlxBehavior: func(args args, origUser model.User, exUser model.User) {
+			name:  "Error in Insert Transaction",
+			entry: model.NewWriteOff("71", 20, model.TransactionInfo{}),
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.userId).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectQuery(debit).WithArgs(float32(20), "71", false, float32(500)).
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(60))
+				mock.ExpectQuery(insert).WillReturnError(fmt.Errorf("some error"))
 				mock.ExpectRollback()
 			},
 			wantError: true,
 		},
 		{
-			name: "Error in Get 2",
-			args: args{
-				userId: 71,
-			},
-			mockSqlxBehavior: func(args args, origUser model.User, exUser model.User) {
+			name:  "Error in Insert Posting",
+			entry: model.NewWriteOff("71", 20, model.TransactionInfo{}),
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.userId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(origUser.Id, origUser.UserId, origUser.Balance))
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.userId).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectQuery(debit).WithArgs(float32(20), "71", false, float32(500)).
+					WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(60))
+				mock.ExpectQuery(insert).WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectExec(posting).WillReturnError(fmt.Errorf("some error"))
 				mock.ExpectRollback()
 			},
 			wantError: true,
@@ -341,187 +554,468 @@ func TestUserRepository_UpdateBalance(t *testing.T) {
 
 	for _, testCase := range testData {
 		t.Run(testCase.name, func(t *testing.T) {
-			testCase.mockSqlxBehavior(testCase.args, testCase.originalUser, testCase.expectedUser)
+			testCase.mockSqlxBehavior()
 
-			user, err := repo.UpdateBalance(testCase.args.userId, testCase.args.sum)
+			result, err := repo.Post(context.Background(), testCase.entry)
 
 			// assert
 			if testCase.wantError {
 				assert.Error(t, err)
+				if testCase.expectedError != nil {
+					assert.True(t, errors.Is(err, testCase.expectedError))
+				}
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, testCase.expectedUser, *user)
+				assert.Equal(t, testCase.expectedResult, result)
 			}
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
-func TestUserRepository_CreateFundsTransaction(t *testing.T) {
+func TestUserRepository_SetCreditLimit(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
 	}
 	defer db.Close()
 
-	repo := NewUserRepository(db)
+	repo := NewUserRepository(db, 500)
 
-	type args struct {
-		senderId   int
-		receiverId int
-		sum        float32
-	}
+	update := `update users set credit_limit = \$1 where user_id = \$2 returning id, user_id, balance, coalesce\(credit_limit, \$3\), status, external_ref, created_at, updated_at;`
+	creditLimit := float32(10000)
 
 	testData := []struct {
 		name             string
-		args             args
-		senderUser       model.User
-		receiverUser     model.User
-		mockSqlxBehavior func(args args, sender, receiver model.User)
+		creditLimit      *float32
+		mockSqlxBehavior func(creditLimit *float32)
+		expectedUser     model.User
 		wantError        bool
 	}{
 		{
-			name: "OK +",
-			args: args{
-				senderId:   71,
-				receiverId: 56,
-			},
-			senderUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 2000,
+			name:        "OK",
+			creditLimit: &creditLimit,
+			mockSqlxBehavior: func(creditLimit *float32) {
+				mock.ExpectQuery(update).WithArgs(creditLimit, "71", float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow("71", "71", 100, 10000, "active", "", time.Time{}, time.Time{}))
 			},
-			receiverUser: model.User{
-				Id:      56,
-				UserId:  56,
-				Balance: 100,
+			expectedUser: model.User{Id: 71, UserId: "71", Balance: 100, CreditLimit: 10000, Status: model.UserStatusActive},
+		},
+		{
+			name:        "OK Reset To Default",
+			creditLimit: nil,
+			mockSqlxBehavior: func(creditLimit *float32) {
+				mock.ExpectQuery(update).WithArgs(nil, "71", float32(500)).
+					WillReturnRows(sqlmock.NewRows(userColumns).AddRow("71", "71", 100, 500, "active", "", time.Time{}, time.Time{}))
 			},
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
-				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.senderId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(sender.Id, sender.UserId, sender.Balance))
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.receiverId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(receiver.Id, receiver.UserId, receiver.Balance))
-				mock.ExpectExec(`update users set balance = $1 where user_id = $2;`).
-					WithArgs(sender.Balance-args.sum, args.senderId).WillReturnResult(sqlmock.NewResult(1, 0))
-				mock.ExpectExec(`update users set balance = $1 where user_id = $2;`).
-					WithArgs(receiver.Balance+args.sum, args.receiverId).WillReturnResult(sqlmock.NewResult(1, 0))
-				mock.ExpectCommit()
+			expectedUser: model.User{Id: 71, UserId: "71", Balance: 100, CreditLimit: 500, Status: model.UserStatusActive},
+		},
+		{
+			name:        "ERR",
+			creditLimit: &creditLimit,
+			mockSqlxBehavior: func(creditLimit *float32) {
+				mock.ExpectQuery(update).WithArgs(creditLimit, "71", float32(500)).WillReturnError(fmt.Errorf("error"))
 			},
 			wantError: true,
 		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior(testCase.creditLimit)
+
+			user, err := repo.SetCreditLimit(context.Background(), "71", testCase.creditLimit)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedUser, *user)
+			}
+		})
+	}
+}
+
+func TestUserRepository_GetTransactions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, 500)
+
+	userId, otherId := "71", "56"
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	columns := []string{"id", "type", "sender_id", "receiver_id", "sum", "order_id", "service_id", "comment", "source", "reversed_id", "created_at"}
+
+	testData := []struct {
+		name                 string
+		mockSqlxBehavior     func()
+		expectedTransactions []model.Transaction
+		wantError            bool
+	}{
 		{
-			name: "Error in Begin",
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
-				mock.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			name: "OK",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select id, type, sender_id, receiver_id, sum, order_id, service_id, comment, source, reversed_id, created_at ` +
+					`from transactions where sender_id = \$1 or receiver_id = \$1 order by created_at desc, id desc;`).
+					WithArgs(userId).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(2, model.TransactionFundsTransfer, otherId, userId, 50, "o-1", "", "gift", "web", nil, createdAt).
+					AddRow(1, model.TransactionWriteOffFunds, userId, nil, 10, "", "s-1", "", "", nil, createdAt))
+			},
+			expectedTransactions: []model.Transaction{
+				{
+					Id:              2,
+					Type:            model.TransactionFundsTransfer,
+					SenderId:        &otherId,
+					ReceiverId:      &userId,
+					Sum:             50,
+					TransactionInfo: model.TransactionInfo{OrderId: "o-1", Comment: "gift", Source: "web"},
+					CreatedAt:       createdAt,
+				},
+				{
+					Id:              1,
+					Type:            model.TransactionWriteOffFunds,
+					SenderId:        &userId,
+					Sum:             10,
+					TransactionInfo: model.TransactionInfo{ServiceId: "s-1"},
+					CreatedAt:       createdAt,
+				},
+			},
+			wantError: false,
+		},
+		{
+			name: "OK Empty",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select (.+) from transactions`).WithArgs(userId).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expectedTransactions: []model.Transaction{},
+			wantError:            false,
+		},
+		{
+			name: "ERR",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select (.+) from transactions`).WithArgs(userId).WillReturnError(fmt.Errorf("error"))
 			},
 			wantError: true,
 		},
 		{
-			name: "ERR bad args",
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
-				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(nil).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(sender.Id, sender.UserId, sender.Balance).RowError(1, fmt.Errorf("some error")))
-				mock.ExpectRollback()
+			name: "ERR Row",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(`select (.+) from transactions`).WithArgs(userId).WillReturnRows(sqlmock.NewRows(columns).
+					AddRow(1, model.TransactionAddFunds, nil, userId, 10, "", "", "", "", nil, createdAt).
+					RowError(0, fmt.Errorf("error")))
 			},
 			wantError: true,
 		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior()
+
+			transactions, err := repo.GetTransactions(context.Background(), userId)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedTransactions, transactions)
+			}
+		})
+	}
+}
+
+func TestUserRepository_WithReplica(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer replica.Close()
+
+	repo := NewUserRepository(db, 500).WithReplica(replica)
+	count := `select count\(1\) from users where user_id = \$1;`
+
+	// чтения из FromReplica идут в реплику, остальные - в основную бд
+	replicaMock.ExpectQuery(count).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(count).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	fromReplica, err := repo.IsUserExist(FromReplica(context.Background()), "71")
+	assert.NoError(t, err)
+	assert.True(t, fromReplica)
+	fromPrimary, err := repo.IsUserExist(context.Background(), "71")
+	assert.NoError(t, err)
+	assert.True(t, fromPrimary)
+
+	// assert
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+
+	// без реплики FromReplica читает из основной бд
+	mock.ExpectQuery(count).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	ex, err := NewUserRepository(db, 500).IsUserExist(FromReplica(context.Background()), "71")
+	assert.NoError(t, err)
+	assert.False(t, ex)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, 500)
+
+	query := `select max_transaction_sum, daily_debit, monthly_debit, daily_transfer, monthly_transfer, hourly_transfers from user_limits where user_id = \$1;`
+	columns := []string{"max_transaction_sum", "daily_debit", "monthly_debit", "daily_transfer", "monthly_transfer", "hourly_transfers"}
+	dailyDebit, hourlyTransfers := float32(1000), 5
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func()
+		expectedLimits   model.Limits
+		wantError        bool
+	}{
 		{
-			name: "Error in Get 1",
-			args: args{
-				senderId: 71,
+			name: "OK",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(query).WithArgs("71").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(nil, 1000, nil, nil, nil, 5))
 			},
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
-				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.senderId).WillReturnError(fmt.Errorf("some error"))
-				mock.ExpectRollback()
+			expectedLimits: model.Limits{DailyDebit: &dailyDebit, HourlyTransfers: &hourlyTransfers},
+		},
+		{
+			name: "OK No Limits",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(query).WithArgs("71").WillReturnError(sql.ErrNoRows)
 			},
-			wantError: true,
+			expectedLimits: model.Limits{},
 		},
 		{
-			name: "Error in Get 2",
-			args: args{
-				senderId:   71,
-				receiverId: 56,
+			name: "ERR",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(query).WithArgs("71").WillReturnError(fmt.Errorf("error"))
 			},
-			senderUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 2000,
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior()
+
+			limits, err := repo.GetLimits(context.Background(), "71")
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedLimits, *limits)
+			}
+		})
+	}
+}
+
+func TestUserRepository_SetLimits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, 500)
+
+	monthlyTransfer := float32(20000)
+	limits := model.Limits{MonthlyTransfer: &monthlyTransfer}
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func()
+		wantError        bool
+	}{
+		{
+			name: "OK",
+			mockSqlxBehavior: func() {
+				mock.ExpectExec(`insert into user_limits (.+) on conflict \(user_id\) do update set`).
+					WithArgs("71", nil, nil, nil, nil, limits.MonthlyTransfer, nil).
+					WillReturnResult(sqlmock.NewResult(1, 1))
 			},
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
-				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.senderId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(sender.Id, sender.UserId, sender.Balance))
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.receiverId).WillReturnError(fmt.Errorf("some error"))
-				mock.ExpectRollback()
+		},
+		{
+			name: "ERR",
+			mockSqlxBehavior: func() {
+				mock.ExpectExec(`insert into user_limits`).WillReturnError(fmt.Errorf("error"))
 			},
 			wantError: true,
 		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior()
+
+			err := repo.SetLimits(context.Background(), "71", limits)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUserRepository_GetSpending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, 500)
+
+	query := `select coalesce\(sum\(sum\), 0\), count\(1\) from transactions where sender_id = \$1 and type = \$2 and created_at >= \$3;`
+	since := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testData := []struct {
+		name             string
+		mockSqlxBehavior func()
+		expectedSum      float32
+		expectedCount    int
+		wantError        bool
+	}{
 		{
-			name: "Error in Exec 1",
-			args: args{
-				senderId:   71,
-				receiverId: 56,
+			name: "OK",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(query).WithArgs("71", model.TransactionFundsTransfer, since).
+					WillReturnRows(sqlmock.NewRows([]string{"sum", "count"}).AddRow(1500, 3))
 			},
-			senderUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 2000,
+			expectedSum:   1500,
+			expectedCount: 3,
+		},
+		{
+			name: "ERR",
+			mockSqlxBehavior: func() {
+				mock.ExpectQuery(query).WithArgs("71", model.TransactionFundsTransfer, since).WillReturnError(fmt.Errorf("error"))
 			},
-			receiverUser: model.User{
-				Id:      56,
-				UserId:  56,
-				Balance: 100,
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.mockSqlxBehavior()
+
+			sum, count, err := repo.GetSpending(context.Background(), "71", model.TransactionFundsTransfer, since)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectedSum, sum)
+				assert.Equal(t, testCase.expectedCount, count)
+			}
+		})
+	}
+}
+
+func TestUserRepository_SetStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, 500)
+
+	update := `update users set status = \$1 where user_id = \$2 and status <> 'closed' and \(\$1 <> 'closed' or balance = 0\);`
+	selectStatus := `select status = 'closed' from users where user_id = \$1;`
+	insert := `insert into user_status_changes \(user_id, status, reason\) values \(\$1, \$2, \$3\);`
+
+	testData := []struct {
+		name             string
+		status           string
+		mockSqlxBehavior func()
+		expectedError    error
+		wantError        bool
+	}{
+		{
+			name:   "OK",
+			status: model.UserStatusFrozen,
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(update).WithArgs(model.UserStatusFrozen, "71").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(insert).WithArgs("71", model.UserStatusFrozen, "compromised").WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
 			},
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
+		},
+		{
+			name:   "ERR Update",
+			status: model.UserStatusFrozen,
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.senderId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(sender.Id, sender.UserId, sender.Balance))
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.receiverId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(receiver.Id, receiver.UserId, receiver.Balance))
-				mock.ExpectExec(`update users set balance = $1 where user_id = $2;`).
-					WithArgs(sender.Balance-args.sum, args.senderId).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectExec(update).WithArgs(model.UserStatusFrozen, "71").WillReturnError(fmt.Errorf("error"))
 				mock.ExpectRollback()
 			},
 			wantError: true,
 		},
 		{
-			name: "Error in Exec 2",
-			args: args{
-				senderId:   71,
-				receiverId: 56,
+			name:   "ERR Insert",
+			status: model.UserStatusFrozen,
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(update).WithArgs(model.UserStatusFrozen, "71").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(insert).WithArgs("71", model.UserStatusFrozen, "compromised").WillReturnError(fmt.Errorf("error"))
+				mock.ExpectRollback()
 			},
-			senderUser: model.User{
-				Id:      71,
-				UserId:  71,
-				Balance: 2000,
+			wantError: true,
+		},
+		{
+			// баланс успел измениться после проверки в сервисе
+			name:   "ERR Non Zero Balance",
+			status: model.UserStatusClosed,
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(update).WithArgs(model.UserStatusClosed, "71").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(selectStatus).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"closed"}).AddRow(false))
+				mock.ExpectRollback()
 			},
-			receiverUser: model.User{
-				Id:      56,
-				UserId:  56,
-				Balance: 100,
+			expectedError: ErrNonZeroBalance,
+			wantError:     true,
+		},
+		{
+			// юзера успели закрыть после проверки в сервисе
+			name:   "ERR User Closed",
+			status: model.UserStatusActive,
+			mockSqlxBehavior: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(update).WithArgs(model.UserStatusActive, "71").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(selectStatus).WithArgs("71").WillReturnRows(sqlmock.NewRows([]string{"closed"}).AddRow(true))
+				mock.ExpectRollback()
 			},
-			mockSqlxBehavior: func(args args, sender, receiver model.User) {
+			expectedError: ErrUserClosed,
+			wantError:     true,
+		},
+		{
+			name:   "ERR Select Status",
+			status: model.UserStatusActive,
+			mockSqlxBehavior: func() {
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.senderId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(sender.Id, sender.UserId, sender.Balance))
-				mock.ExpectQuery(`select id, user_id, balance from users where user_id = \$1;`).
-					WithArgs(args.receiverId).WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "balance"}).
-					AddRow(receiver.Id, receiver.UserId, receiver.Balance))
-				mock.ExpectExec(`update users set balance = $1 where user_id = $2;`).
-					WithArgs(sender.Balance-args.sum, args.senderId).WillReturnResult(sqlmock.NewResult(1, 0))
-				mock.ExpectExec(`update users set balance = $1 where user_id = $2;`).
-					WithArgs(receiver.Balance+args.sum, args.receiverId).WillReturnError(fmt.Errorf("some error"))
+				mock.ExpectExec(update).WithArgs(model.UserStatusActive, "71").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(selectStatus).WithArgs("71").WillReturnError(fmt.Errorf("error"))
 				mock.ExpectRollback()
 			},
 			wantError: true,
@@ -530,16 +1024,19 @@ func TestUserRepository_CreateFundsTransaction(t *testing.T) {
 
 	for _, testCase := range testData {
 		t.Run(testCase.name, func(t *testing.T) {
-			testCase.mockSqlxBehavior(testCase.args, testCase.senderUser, testCase.receiverUser)
+			testCase.mockSqlxBehavior()
 
-			err := repo.CreateFundsTransaction(testCase.args.senderId, testCase.args.receiverId, testCase.args.sum)
+			err := repo.SetStatus(context.Background(), "71", testCase.status, "compromised")
 
 			// assert
-			if testCase.wantError {
+			if testCase.expectedError != nil {
+				assert.True(t, errors.Is(err, testCase.expectedError), err)
+			} else if testCase.wantError {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 			}
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
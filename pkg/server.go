@@ -2,6 +2,9 @@ package pkg
 
 import (
 	"context"
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"net"
 	"net/http"
 	"time"
 )
@@ -10,18 +13,54 @@ type Server struct {
 	httpServer *http.Server
 }
 
-func (s Server) Run(address string, handler http.Handler) error {
-	s.httpServer = &http.Server{
-		Addr:           address,
-		Handler:        handler,
-		MaxHeaderBytes: 1 << 20, // 1 Mb
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+// ServerConfig таймауты и лимиты http сервера, 0 - значение по умолчанию из net/http
+type ServerConfig struct {
+	ReadTimeout       time.Duration // на чтение всего запроса вместе с телом
+	ReadHeaderTimeout time.Duration // на чтение заголовков, 0 - как ReadTimeout
+	WriteTimeout      time.Duration // на запись ответа, отсчитывается с конца чтения заголовков
+	IdleTimeout       time.Duration // сколько держать keep-alive соединение без запросов, 0 - как ReadTimeout
+	MaxHeaderBytes    int
+}
+
+// NewServer tlsConfig - сертификаты сервера и проверка клиентских сертификатов, nil - обычный http
+func NewServer(address string, handler http.Handler, tlsConfig *tls.Config, c ServerConfig) *Server {
+	return &Server{httpServer: &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+		ReadTimeout:       c.ReadTimeout,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+	}}
+}
+
+// Run принимает запросы до Shutdown, после Shutdown возвращает nil
+func (s *Server) Run() error {
+	if s.httpServer.TLSConfig != nil {
+		// сертификаты уже в TLSConfig
+		return ignoreClosed(s.httpServer.ListenAndServeTLS("", ""))
 	}
+	return ignoreClosed(s.httpServer.ListenAndServe())
+}
 
-	return s.httpServer.ListenAndServe()
+// Serve то же, что Run, но на готовом listener
+func (s *Server) Serve(listener net.Listener) error {
+	if s.httpServer.TLSConfig != nil {
+		return ignoreClosed(s.httpServer.ServeTLS(listener, "", ""))
+	}
+	return ignoreClosed(s.httpServer.Serve(listener))
 }
 
-func (s Server) Shutdown(ctx context.Context) error {
+// Shutdown перестает принимать соединения и ждет завершения запросов в работе, пока не отменен ctx
+func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpServer.Shutdown(ctx)
 }
+
+func ignoreClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package pkg_test
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg"
+	"for_avito_tech_with_gin/pkg/handler"
+	"for_avito_tech_with_gin/pkg/lifecycle"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/service"
+	mock_service "for_avito_tech_with_gin/pkg/service/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_Shutdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testData := []struct {
+		name               string
+		shutdownTimeout    time.Duration
+		finishTransfer     bool
+		expectedStatusCode int
+		wantError          bool
+	}{
+		{
+			name:               "In-Flight Transfer Completes",
+			shutdownTimeout:    5 * time.Second,
+			finishTransfer:     true,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:            "Shutdown Deadline",
+			shutdownTimeout: 100 * time.Millisecond,
+			wantError:       true,
+		},
+	}
+
+	for _, testCase := range testData {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			started, release := make(chan struct{}), make(chan struct{})
+			defer close(release)
+			user := mock_service.NewMockUser(c)
+			user.EXPECT().FundsTransfer(gomock.Any(), "1", "2", float32(100), model.TransactionInfo{}).
+				DoAndReturn(func(ctx context.Context, senderId string, receiverId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+					close(started)
+					<-release
+					return &model.OperationResult{Transaction: &model.Transaction{Id: 1}}, nil
+				})
+
+			// test server
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := pkg.NewServer(listener.Addr().String(), handler.NewHandler(&service.Service{User: user}, nil, nil).InitRouters(handler.RouterConfig{}), nil, pkg.ServerConfig{})
+			manager := lifecycle.NewManager(testCase.shutdownTimeout)
+			manager.Add(lifecycle.Component{Name: "http server", Run: func(ctx context.Context) error {
+				return srv.Serve(listener)
+			}, Stop: srv.Shutdown})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stopped := make(chan error, 1)
+			go func() { stopped <- manager.Run(ctx) }()
+
+			// perform request
+			responses := make(chan *http.Response, 1)
+			go func() {
+				resp, err := http.Post("http://"+listener.Addr().String()+"/api/v1/funds_transfer", "application/json",
+					strings.NewReader(`{"sender_id":"1", "receiver_id": 2, "sum": 100}`))
+				if err != nil {
+					responses <- nil
+					return
+				}
+				resp.Body.Close()
+				responses <- resp
+			}()
+			<-started
+			cancel()
+
+			// assert
+			if !testCase.finishTransfer {
+				assert.Error(t, <-stopped)
+				return
+			}
+			select {
+			case <-stopped:
+				t.Fatal("server stopped before in-flight transfer completed")
+			case <-time.After(100 * time.Millisecond):
+			}
+			release <- struct{}{}
+			resp := <-responses
+			if assert.NotNil(t, resp) {
+				assert.Equal(t, testCase.expectedStatusCode, resp.StatusCode)
+			}
+			assert.NoError(t, <-stopped)
+		})
+	}
+}
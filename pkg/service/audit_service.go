@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"for_avito_tech_with_gin/pkg/logging"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	"for_avito_tech_with_gin/pkg/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// ActorSystem действия без клиента, например сверка по расписанию
+	ActorSystem = "system"
+
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type actorKey struct{}
+
+// WithActor кладет в ctx, кто выполняет действие, он пишется в журнал аудита
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor кто выполняет действие из ctx, по умолчанию system
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
+
+type AuditService struct {
+	repo *repository.Repository
+}
+
+func NewAuditService(repo *repository.Repository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// GetAuditLog записи журнала аудита по фильтру, без limit - первые 100
+func (r *AuditService) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.GetAuditLog", attribute.String("audit.actor", filter.Actor),
+		attribute.String("user.id", filter.UserId))
+	defer span.End()
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditLimit {
+		return nil, &WrongParam{Param: "limit"}
+	}
+	if filter.AfterId < 0 {
+		return nil, &WrongParam{Param: "after_id"}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, &WrongParam{Param: "to"}
+	}
+
+	entries, err := r.repo.GetAuditLog(ctx, filter)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	return entries, nil
+}
+
+// VerifyAuditLog проходит весь журнал аудита и пересчитывает цепочку хэшей: находит измененные записи и разрывы цепочки
+// на месте удаленных. Удаление записей с конца цепочку не рвет, его видно только по lastHash - хэшу последней записи
+// из прошлой проверки
+func (r *AuditService) VerifyAuditLog(ctx context.Context, lastHash string) (*model.AuditVerification, error) {
+	ctx, span := tracing.Start(ctx, "AuditService.VerifyAuditLog")
+	defer span.End()
+
+	verification := model.AuditVerification{Problems: make([]model.AuditProblem, 0)}
+	lastHashFound := lastHash == ""
+	filter := model.AuditFilter{Limit: maxAuditLimit}
+	for {
+		entries, err := r.repo.GetAuditLog(ctx, filter)
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+
+		for _, e := range entries {
+			if e.Hash != e.ComputeHash() {
+				verification.Problems = append(verification.Problems, model.AuditProblem{Id: e.Id, Problem: model.AuditEntryModified})
+			}
+			if e.PrevHash != verification.LastHash {
+				verification.Problems = append(verification.Problems, model.AuditProblem{Id: e.Id, Problem: model.AuditChainBroken})
+			}
+			if e.Hash == lastHash {
+				lastHashFound = true
+			}
+			verification.Entries++
+			verification.LastId = e.Id
+			verification.LastHash = e.Hash
+		}
+
+		if len(entries) < filter.Limit {
+			break
+		}
+		filter.AfterId = verification.LastId
+	}
+	if !lastHashFound {
+		verification.Problems = append(verification.Problems, model.AuditProblem{Id: verification.LastId, Problem: model.AuditLastHashNotFound})
+	}
+
+	verification.Valid = len(verification.Problems) == 0
+	if !verification.Valid {
+		logger(ctx).WithField("problems", verification.Problems).Error("audit log is tampered")
+	}
+	return &verification, nil
+}
+
+// audit пишет действие в журнал аудита в транзакции tx, в которой идет само действие: если запись не сохранилась,
+// действие откатывается вместе с ней. Без журнала аудита (repo.Audit == nil) ничего не пишет
+func audit(ctx context.Context, repo *repository.Repository, tx repository.Audit, action string, userIds []string, transactionId *int, details interface{}) error {
+	if repo.Audit == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(details)
+	if err != nil {
+		return errors.Wrapf(err, "filed to marshal audit %s details", action)
+	}
+	entry := model.AuditEntry{
+		RequestId:     logging.RequestId(ctx),
+		Actor:         Actor(ctx),
+		Action:        action,
+		UserIds:       userIds,
+		TransactionId: transactionId,
+		Details:       data,
+	}
+	if _, err := tx.AppendAudit(ctx, entry); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// auditTransaction пишет в журнал аудита операцию с деньгами
+func auditTransaction(ctx context.Context, repo *repository.Repository, tx repository.Audit, t *model.Transaction) error {
+	userIds := make([]string, 0, 2)
+	for _, userId := range []*string{t.SenderId, t.ReceiverId} {
+		if userId != nil {
+			userIds = append(userIds, *userId)
+		}
+	}
+	return audit(ctx, repo, tx, t.Type, userIds, &t.Id, struct {
+		Sum        float32 `json:"sum"`
+		ReversedId *int    `json:"reversed_id,omitempty"`
+		model.TransactionInfo
+	}{t.Sum, t.ReversedId, t.TransactionInfo})
+}
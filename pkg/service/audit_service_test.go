@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	mock_repository "for_avito_tech_with_gin/pkg/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// auditChain цепочка из n записей с правильными хэшами
+func auditChain(n int) []model.AuditEntry {
+	createdAt := time.Date(2022, 1, 25, 10, 30, 0, 0, time.UTC)
+	entries := make([]model.AuditEntry, 0, n)
+	prevHash := ""
+	for i := 1; i <= n; i++ {
+		e := model.AuditEntry{
+			Id:        int64(i),
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Minute),
+			Actor:     "cert:CN=billing",
+			Action:    model.TransactionAddFunds,
+			UserIds:   []string{"56"},
+			Details:   json.RawMessage(`{"sum":100}`),
+			PrevHash:  prevHash,
+		}
+		e.Hash = e.ComputeHash()
+		prevHash = e.Hash
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAuditService_GetAuditLog(t *testing.T) {
+	from := time.Date(2022, 1, 25, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	entries := auditChain(2)
+
+	testData := []struct {
+		name                   string
+		filter                 model.AuditFilter
+		mockRepositoryBehavior func(s *mock_repository.MockAudit)
+		expectedEntries        []model.AuditEntry
+		expectedError          error
+	}{
+		{
+			name:   "OK",
+			filter: model.AuditFilter{UserId: "56", From: &from, To: &to},
+			mockRepositoryBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{UserId: "56", From: &from, To: &to, Limit: 100}).Return(entries, nil)
+			},
+			expectedEntries: entries,
+		},
+		{
+			name:                   "Wrong Limit",
+			filter:                 model.AuditFilter{Limit: 1001},
+			mockRepositoryBehavior: func(s *mock_repository.MockAudit) {},
+			expectedError:          &WrongParam{Param: "limit"},
+		},
+		{
+			name:                   "Wrong After Id",
+			filter:                 model.AuditFilter{AfterId: -1},
+			mockRepositoryBehavior: func(s *mock_repository.MockAudit) {},
+			expectedError:          &WrongParam{Param: "after_id"},
+		},
+		{
+			name:                   "Wrong Range",
+			filter:                 model.AuditFilter{From: &to, To: &from},
+			mockRepositoryBehavior: func(s *mock_repository.MockAudit) {},
+			expectedError:          &WrongParam{Param: "to"},
+		},
+		{
+			name:   "Error in GetAuditLog",
+			filter: model.AuditFilter{Actor: "cli", Limit: 10},
+			mockRepositoryBehavior: func(s *mock_repository.MockAudit) {
+				s.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{Actor: "cli", Limit: 10}).Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockAudit(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			services := NewAuditService(&repository.Repository{Audit: repo})
+
+			// test
+			entries, err := services.GetAuditLog(context.Background(), testCase.filter)
+
+			// assert
+			assert.Equal(t, testCase.expectedEntries, entries)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestAuditService_VerifyAuditLog(t *testing.T) {
+	testData := []struct {
+		name             string
+		entries          func() []model.AuditEntry
+		lastHash         func(entries []model.AuditEntry) string
+		expectedProblems []model.AuditProblem
+	}{
+		{
+			name:             "OK",
+			entries:          func() []model.AuditEntry { return auditChain(3) },
+			lastHash:         func(entries []model.AuditEntry) string { return entries[1].Hash },
+			expectedProblems: []model.AuditProblem{},
+		},
+		{
+			name:             "OK Empty",
+			entries:          func() []model.AuditEntry { return []model.AuditEntry{} },
+			lastHash:         func(entries []model.AuditEntry) string { return "" },
+			expectedProblems: []model.AuditProblem{},
+		},
+		{
+			name: "Modified",
+			entries: func() []model.AuditEntry {
+				entries := auditChain(3)
+				entries[1].Details = json.RawMessage(`{"sum":1000}`)
+				return entries
+			},
+			lastHash:         func(entries []model.AuditEntry) string { return "" },
+			expectedProblems: []model.AuditProblem{{Id: 2, Problem: model.AuditEntryModified}},
+		},
+		{
+			// хэш пересчитан вместе с правкой: запись сходится сама с собой, но следующая на нее уже не ссылается
+			name: "Modified With Hash",
+			entries: func() []model.AuditEntry {
+				entries := auditChain(3)
+				entries[1].Actor = "cli"
+				entries[1].Hash = entries[1].ComputeHash()
+				return entries
+			},
+			lastHash:         func(entries []model.AuditEntry) string { return "" },
+			expectedProblems: []model.AuditProblem{{Id: 3, Problem: model.AuditChainBroken}},
+		},
+		{
+			name: "Deleted",
+			entries: func() []model.AuditEntry {
+				entries := auditChain(3)
+				return append(entries[:1], entries[2])
+			},
+			lastHash:         func(entries []model.AuditEntry) string { return "" },
+			expectedProblems: []model.AuditProblem{{Id: 3, Problem: model.AuditChainBroken}},
+		},
+		{
+			name:             "Deleted From End",
+			entries:          func() []model.AuditEntry { return auditChain(2) },
+			lastHash:         func(entries []model.AuditEntry) string { return auditChain(3)[2].Hash },
+			expectedProblems: []model.AuditProblem{{Id: 2, Problem: model.AuditLastHashNotFound}},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			entries := testCase.entries()
+			repo := mock_repository.NewMockAudit(c)
+			repo.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{Limit: maxAuditLimit}).Return(entries, nil)
+
+			services := NewAuditService(&repository.Repository{Audit: repo})
+
+			// test
+			verification, err := services.VerifyAuditLog(context.Background(), testCase.lastHash(entries))
+
+			// assert
+			assert.NoError(t, err)
+			assert.Equal(t, len(entries), verification.Entries)
+			assert.Equal(t, testCase.expectedProblems, verification.Problems)
+			assert.Equal(t, len(testCase.expectedProblems) == 0, verification.Valid)
+		})
+	}
+}
+
+func TestAuditService_VerifyAuditLog_Pages(t *testing.T) {
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	entries := auditChain(maxAuditLimit + 1)
+	repo := mock_repository.NewMockAudit(c)
+	gomock.InOrder(
+		repo.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{Limit: maxAuditLimit}).Return(entries[:maxAuditLimit], nil),
+		repo.EXPECT().GetAuditLog(gomock.Any(), model.AuditFilter{AfterId: maxAuditLimit, Limit: maxAuditLimit}).Return(entries[maxAuditLimit:], nil),
+	)
+
+	services := NewAuditService(&repository.Repository{Audit: repo})
+
+	// test
+	verification, err := services.VerifyAuditLog(context.Background(), "")
+
+	// assert
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Equal(t, maxAuditLimit+1, verification.Entries)
+	assert.Equal(t, entries[maxAuditLimit].Hash, verification.LastHash)
+}
+
+// TestAuditService_VerifyAuditLog_ConcurrentAppends параллельные записи в журнал аудита на настоящей бд: цепочка не должна
+// разветвиться. Нужна отдельная бд с примененными миграциями в TEST_DATABASE_URL, записи из журнала уже не удалить
+func TestAuditService_VerifyAuditLog_ConcurrentAppends(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	// init deps
+	ctx := context.Background()
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a database connection", err)
+	}
+	defer db.Close()
+	repo := repository.NewRepository(db, nil, 0, repository.RetryConfig{MaxAttempts: 100, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond})
+
+	// test
+	const workers, appends = 8, 10
+	errs := make(chan error, workers*appends)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < appends; i++ {
+				errs <- repo.InTx(ctx, func(tx repository.Tx) error {
+					// как в операциях сервиса: снимок транзакции берется еще до записи в журнал
+					if _, err := tx.GetAuditLog(ctx, model.AuditFilter{Limit: 1}); err != nil {
+						return err
+					}
+					_, err := tx.AppendAudit(ctx, model.AuditEntry{Actor: "system", Action: "concurrency_test"})
+					return err
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// assert
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	verification, err := NewAuditService(repo).VerifyAuditLog(ctx, "")
+	assert.NoError(t, err)
+	assert.True(t, verification.Valid, verification.Problems)
+}
+
+func TestUserService_SetStatus_Audit(t *testing.T) {
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	// статус читается, меняется и пишется в журнал аудита в одной транзакции
+	tx := mock_repository.NewMockTx(c)
+	gomock.InOrder(
+		tx.EXPECT().IsUserExist(gomock.Any(), "56").Return(true, nil),
+		tx.EXPECT().GetUserForUpdate(gomock.Any(), "56").Return(&model.User{UserId: "56", Status: model.UserStatusActive}, nil),
+		tx.EXPECT().SetStatus(gomock.Any(), "56", model.UserStatusFrozen, "fraud").Return(nil),
+		tx.EXPECT().AppendAudit(gomock.Any(), model.AuditEntry{
+			RequestId: "",
+			Actor:     "cert:CN=support",
+			Action:    model.AuditSetStatus,
+			UserIds:   []string{"56"},
+			Details:   json.RawMessage(`{"from":"active","reason":"fraud","to":"frozen"}`),
+		}).Return(&model.AuditEntry{}, nil),
+		tx.EXPECT().Commit().Return(nil),
+	)
+	tx.EXPECT().Rollback().Return(nil)
+	uow := mock_repository.NewMockUnitOfWork(c)
+	uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+
+	services := NewUserService(&repository.Repository{Audit: mock_repository.NewMockAudit(c), UnitOfWork: uow},
+		model.Limits{}, false, true)
+
+	// test
+	err := services.SetStatus(WithActor(context.Background(), "cert:CN=support"), "56", model.UserStatusFrozen, "fraud")
+
+	// assert
+	assert.NoError(t, err)
+}
+
+func TestTransactionService_ReverseTransaction_AuditError(t *testing.T) {
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	senderId := "56"
+	reversedId := 5
+	original := &model.Transaction{Id: reversedId, Type: model.TransactionWriteOffFunds, SenderId: &senderId, Sum: 100}
+	reversal := &model.Transaction{Id: 6, Type: model.TransactionReversal, ReceiverId: &senderId, Sum: 100, ReversedId: &reversedId}
+
+	users := mock_repository.NewMockUser(c)
+	users.EXPECT().GetUser(gomock.Any(), senderId).Return(&model.User{UserId: senderId, Status: model.UserStatusActive}, nil)
+	transactions := mock_repository.NewMockTransaction(c)
+	transactions.EXPECT().GetTransaction(gomock.Any(), reversedId).Return(original, nil)
+
+	// без записи в журнале аудита возврат не сохраняется: транзакция откатывается без Commit
+	tx := mock_repository.NewMockTx(c)
+	tx.EXPECT().ReverseTransaction(gomock.Any(), reversedId, float32(0), false, model.TransactionInfo{}).Return(reversal, nil)
+	tx.EXPECT().AppendAudit(gomock.Any(), model.AuditEntry{
+		Actor:         ActorSystem,
+		Action:        model.TransactionReversal,
+		UserIds:       []string{senderId},
+		TransactionId: &reversal.Id,
+		Details:       json.RawMessage(`{"sum":100,"reversed_id":5}`),
+	}).Return(nil, errors.Errorf("lol kek cheburek."))
+	tx.EXPECT().Rollback().Return(nil)
+	uow := mock_repository.NewMockUnitOfWork(c)
+	uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+
+	services := NewTransactionService(&repository.Repository{User: users, Transaction: transactions,
+		Audit: mock_repository.NewMockAudit(c), UnitOfWork: uow})
+
+	// test
+	result, err := services.ReverseTransaction(context.Background(), reversedId, 0, false, model.TransactionInfo{})
+
+	// assert
+	assert.Nil(t, result)
+	assert.Equal(t, &InternalServerError{}, err)
+}
+
+func TestLedgerService_Reconcile_Audit(t *testing.T) {
+	// init deps
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	correctionId := 12
+	discrepancy := model.Discrepancy{UserId: "71", Balance: 120, LedgerBalance: 100, Difference: 20}
+	corrected := discrepancy
+	corrected.CorrectionId = &correctionId
+
+	ledger := mock_repository.NewMockLedger(c)
+	ledger.EXPECT().GetLedger(gomock.Any()).Return(&model.Ledger{UnbalancedTransactions: []int{}, Balanced: true}, nil)
+	ledger.EXPECT().GetDiscrepancies(gomock.Any()).Return([]model.Discrepancy{discrepancy}, nil)
+
+	// исправление users.balance и запись о нем в журнале аудита сохраняются вместе
+	tx := mock_repository.NewMockTx(c)
+	gomock.InOrder(
+		tx.EXPECT().CorrectBalance(gomock.Any(), "71").Return(&corrected, nil),
+		tx.EXPECT().AppendAudit(gomock.Any(), model.AuditEntry{
+			Actor:   ActorSystem,
+			Action:  model.AuditCorrectBalance,
+			UserIds: []string{"71"},
+			Details: json.RawMessage(`{"user_id":"71","balance":120,"ledger_balance":100,"difference":20,"correction_id":12}`),
+		}).Return(&model.AuditEntry{}, nil),
+		tx.EXPECT().Commit().Return(nil),
+	)
+	tx.EXPECT().Rollback().Return(nil)
+	uow := mock_repository.NewMockUnitOfWork(c)
+	uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+
+	services := NewLedgerService(&repository.Repository{Ledger: ledger, Audit: mock_repository.NewMockAudit(c), UnitOfWork: uow})
+
+	// test
+	reconciliation, err := services.Reconcile(context.Background(), true)
+
+	// assert
+	assert.NoError(t, err)
+	assert.Equal(t, []model.Discrepancy{corrected}, reconciliation.Discrepancies)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	"for_avito_tech_with_gin/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"time"
+)
+
+type LedgerService struct {
+	repo *repository.Repository
+}
+
+func NewLedgerService(repo *repository.Repository) *LedgerService {
+	return &LedgerService{repo: repo}
+}
+
+// GetLedger балансы счетов по журналу и проверка, что все проводки сходятся в 0.
+// Несведенный журнал - ошибка в коде или ручная правка бд, поэтому он пишется в лог
+func (r *LedgerService) GetLedger(ctx context.Context) (*model.Ledger, error) {
+	ctx, span := tracing.Start(ctx, "LedgerService.GetLedger")
+	defer span.End()
+
+	ledger, err := r.repo.GetLedger(ctx)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	if !ledger.Balanced {
+		logger(ctx).WithField("total", ledger.Total).WithField("transactions", ledger.UnbalancedTransactions).
+			Error("ledger is not balanced")
+	}
+
+	return ledger, nil
+}
+
+// Reconcile сверяет кэш балансов с журналом: пересчитывает балансы кошельков по проводкам и возвращает расхождения
+// по юзерам. Если repair - исправляет users.balance по журналу, сам журнал не меняется. Несведенному журналу верить
+// нельзя, поэтому по нему ничего не исправляется
+func (r *LedgerService) Reconcile(ctx context.Context, repair bool) (*model.Reconciliation, error) {
+	ctx, span := tracing.Start(ctx, "LedgerService.Reconcile", attribute.Bool("reconcile.repair", repair))
+	defer span.End()
+
+	reconciliation := model.Reconciliation{StartedAt: time.Now().UTC()}
+	ledger, err := r.repo.GetLedger(ctx)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	reconciliation.UnbalancedTransactions = ledger.UnbalancedTransactions
+	reconciliation.Repaired = repair && len(reconciliation.UnbalancedTransactions) == 0
+
+	reconciliation.Discrepancies, err = r.repo.GetDiscrepancies(ctx)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	for i, d := range reconciliation.Discrepancies {
+		log := logger(ctx).WithField("user_id", d.UserId).WithField("balance", d.Balance).
+			WithField("ledger_balance", d.LedgerBalance)
+		if !reconciliation.Repaired {
+			log.Warn("balance does not match ledger")
+			continue
+		}
+
+		var corrected *model.Discrepancy
+		err := r.repo.InTx(ctx, func(tx repository.Tx) error {
+			var err error
+			corrected, err = tx.CorrectBalance(ctx, d.UserId)
+			if err != nil || corrected.CorrectionId == nil {
+				return err
+			}
+			return audit(ctx, r.repo, tx, model.AuditCorrectBalance, []string{d.UserId}, nil, corrected)
+		})
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+		reconciliation.Discrepancies[i] = *corrected
+		if corrected.CorrectionId != nil {
+			log.WithField("correction_id", *corrected.CorrectionId).Warn("balance does not match ledger, corrected")
+		}
+	}
+	if len(reconciliation.UnbalancedTransactions) > 0 {
+		logger(ctx).WithField("transactions", reconciliation.UnbalancedTransactions).Error("ledger is not balanced, balances are not corrected")
+	}
+
+	reconciliation.FinishedAt = time.Now().UTC()
+	return &reconciliation, nil
+}
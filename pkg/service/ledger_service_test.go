@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	mock_repository "for_avito_tech_with_gin/pkg/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLedgerService_GetLedger(t *testing.T) {
+	balanced := &model.Ledger{
+		Accounts: []model.AccountBalance{
+			{Account: model.AccountCashIn, Balance: -500},
+			{Account: model.AccountRevenue, Balance: 200},
+			{Account: model.AccountUser, Balance: 300},
+		},
+		UnbalancedTransactions: []int{},
+		Balanced:               true,
+	}
+	unbalanced := &model.Ledger{
+		Accounts:               []model.AccountBalance{{Account: model.AccountUser, Balance: 300}},
+		Total:                  300,
+		UnbalancedTransactions: []int{5},
+	}
+
+	testData := []struct {
+		name                   string
+		mockRepositoryBehavior func(s *mock_repository.MockLedger)
+		expectedLedger         *model.Ledger
+		expectedError          error
+	}{
+		{
+			name: "OK",
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(balanced, nil)
+			},
+			expectedLedger: balanced,
+		},
+		{
+			// несведенный журнал - не ошибка запроса, он отдается как есть
+			name: "OK Unbalanced",
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(unbalanced, nil)
+			},
+			expectedLedger: unbalanced,
+		},
+		{
+			name: "Error in GetLedger",
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockLedger(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			services := NewLedgerService(&repository.Repository{Ledger: repo})
+
+			// test
+			ledger, err := services.GetLedger(context.Background())
+
+			// assert
+			assert.Equal(t, testCase.expectedLedger, ledger)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestLedgerService_Reconcile(t *testing.T) {
+	correctionId := 12
+	ledger := &model.Ledger{UnbalancedTransactions: []int{}, Balanced: true}
+	unbalanced := &model.Ledger{UnbalancedTransactions: []int{7}}
+	discrepancy := model.Discrepancy{UserId: "71", Balance: 120, LedgerBalance: 100, Difference: 20}
+	corrected := model.Discrepancy{UserId: "71", Balance: 120, LedgerBalance: 100, Difference: 20, CorrectionId: &correctionId}
+
+	testData := []struct {
+		name                   string
+		repair                 bool
+		mockRepositoryBehavior func(s *mock_repository.MockLedger)
+		mockTxBehavior         mockTxBehavior
+		expectedDiscrepancies  []model.Discrepancy
+		expectedRepaired       bool
+		expectedConsistent     bool
+		expectedError          error
+	}{
+		{
+			name: "OK Report Only",
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(ledger, nil)
+				s.EXPECT().GetDiscrepancies(gomock.Any()).Return([]model.Discrepancy{discrepancy}, nil)
+			},
+			expectedDiscrepancies: []model.Discrepancy{discrepancy},
+		},
+		{
+			name:   "OK Repair",
+			repair: true,
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(ledger, nil)
+				s.EXPECT().GetDiscrepancies(gomock.Any()).Return([]model.Discrepancy{discrepancy}, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().CorrectBalance(gomock.Any(), "71").Return(&corrected, nil)
+			},
+			expectedDiscrepancies: []model.Discrepancy{corrected},
+			expectedRepaired:      true,
+			expectedConsistent:    true,
+		},
+		{
+			// по несведенному журналу балансы не исправляются
+			name:   "Repair With Unbalanced Ledger",
+			repair: true,
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(unbalanced, nil)
+				s.EXPECT().GetDiscrepancies(gomock.Any()).Return([]model.Discrepancy{discrepancy}, nil)
+			},
+			expectedDiscrepancies: []model.Discrepancy{discrepancy},
+		},
+		{
+			name: "Error in GetLedger",
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:   "Error in CorrectBalance",
+			repair: true,
+			mockRepositoryBehavior: func(s *mock_repository.MockLedger) {
+				s.EXPECT().GetLedger(gomock.Any()).Return(ledger, nil)
+				s.EXPECT().GetDiscrepancies(gomock.Any()).Return([]model.Discrepancy{discrepancy}, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().CorrectBalance(gomock.Any(), "71").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockLedger(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			uow := mock_repository.NewMockUnitOfWork(c)
+			if testCase.mockTxBehavior != nil {
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
+
+			services := NewLedgerService(&repository.Repository{Ledger: repo, UnitOfWork: uow})
+
+			// test
+			reconciliation, err := services.Reconcile(context.Background(), testCase.repair)
+
+			// assert
+			assert.Equal(t, testCase.expectedError, err)
+			if testCase.expectedError == nil {
+				assert.Equal(t, testCase.expectedDiscrepancies, reconciliation.Discrepancies)
+				assert.Equal(t, testCase.expectedRepaired, reconciliation.Repaired)
+				assert.Equal(t, testCase.expectedConsistent, reconciliation.Consistent())
+				assert.False(t, reconciliation.FinishedAt.Before(reconciliation.StartedAt))
+			}
+		})
+	}
+}
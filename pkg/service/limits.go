@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// checkLimits проверяет, что списание или перевод sum не выходит за лимиты юзера.
+// Дневные и месячные лимиты считаются с начала суток и месяца по UTC, количество переводов - за последний час.
+// repo - репозиторий или транзакция, в которой идет операция
+func (r *UserService) checkLimits(ctx context.Context, repo repository.User, userId string, transactionType string, sum float32) error {
+	overrides, err := repo.GetLimits(ctx, userId)
+	if err != nil {
+		return dbError(ctx, err)
+	}
+	limits := overrides.Merge(r.limits.Load().(model.Limits))
+
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	kind, daily, monthly := "debit", limits.DailyDebit, limits.MonthlyDebit
+	if transactionType == model.TransactionFundsTransfer {
+		kind, daily, monthly = "transfer", limits.DailyTransfer, limits.MonthlyTransfer
+	}
+
+	if limits.MaxTransactionSum != nil && sum > *limits.MaxTransactionSum {
+		return limitExceeded(ctx, userId, transactionType, sum, "max_transaction_sum")
+	}
+
+	periods := []struct {
+		limit *float32
+		since time.Time
+		name  string
+	}{
+		{daily, day, "daily_" + kind},
+		{monthly, month, "monthly_" + kind},
+	}
+	for _, period := range periods {
+		if period.limit == nil {
+			continue
+		}
+		spent, _, err := repo.GetSpending(ctx, userId, transactionType, period.since)
+		if err != nil {
+			return dbError(ctx, err)
+		}
+		if spent+sum > *period.limit {
+			return limitExceeded(ctx, userId, transactionType, sum, period.name)
+		}
+	}
+
+	if transactionType == model.TransactionFundsTransfer && limits.HourlyTransfers != nil {
+		_, count, err := repo.GetSpending(ctx, userId, transactionType, now.Add(-time.Hour))
+		if err != nil {
+			return dbError(ctx, err)
+		}
+		if count+1 > *limits.HourlyTransfers {
+			return limitExceeded(ctx, userId, transactionType, sum, "hourly_transfers")
+		}
+	}
+
+	return nil
+}
+
+// limitExceeded пишет нарушение лимита в лог для последующего разбора
+func limitExceeded(ctx context.Context, userId string, transactionType string, sum float32, limit string) error {
+	logger(ctx).WithFields(logrus.Fields{
+		"user_id":          userId,
+		"transaction_type": transactionType,
+		"sum":              sum,
+		"limit":            limit,
+	}).Warn("limit exceeded")
+
+	return &LimitExceeded{Id: userId, Limit: limit}
+}
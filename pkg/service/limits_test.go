@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	mock_repository "for_avito_tech_with_gin/pkg/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUserService_checkLimits(t *testing.T) {
+	sum1000, sum5000, count3 := float32(1000), float32(5000), 3
+
+	testData := []struct {
+		name                   string
+		transactionType        string
+		sum                    float32
+		limits                 model.Limits
+		mockRepositoryBehavior mockRepositoryBehavior
+		expectedError          error
+	}{
+		{
+			name:            "OK No Limits",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             100000,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:            "OK Within Daily Debit",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             500,
+			limits:          model.Limits{DailyDebit: &sum1000},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetSpending(gomock.Any(), "17", model.TransactionWriteOffFunds, gomock.Any()).Return(float32(500), 2, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:            "Max Transaction Sum",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             1500,
+			limits:          model.Limits{MaxTransactionSum: &sum1000},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+			},
+			expectedError: &LimitExceeded{Id: "17", Limit: "max_transaction_sum"},
+		},
+		{
+			name:            "User Override Raises Max Transaction Sum",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             1500,
+			limits:          model.Limits{MaxTransactionSum: &sum1000},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{MaxTransactionSum: &sum5000}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:            "Daily Debit",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             600,
+			limits:          model.Limits{DailyDebit: &sum1000, DailyTransfer: &sum5000},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetSpending(gomock.Any(), "17", model.TransactionWriteOffFunds, gomock.Any()).Return(float32(500), 2, nil)
+			},
+			expectedError: &LimitExceeded{Id: "17", Limit: "daily_debit"},
+		},
+		{
+			name:            "Monthly Transfer",
+			transactionType: model.TransactionFundsTransfer,
+			sum:             600,
+			limits:          model.Limits{DailyDebit: &sum1000},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{MonthlyTransfer: &sum5000}, nil)
+				s.EXPECT().GetSpending(gomock.Any(), "17", model.TransactionFundsTransfer, gomock.Any()).Return(float32(4500), 10, nil)
+			},
+			expectedError: &LimitExceeded{Id: "17", Limit: "monthly_transfer"},
+		},
+		{
+			name:            "Hourly Transfers",
+			transactionType: model.TransactionFundsTransfer,
+			sum:             100,
+			limits:          model.Limits{HourlyTransfers: &count3},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetSpending(gomock.Any(), "17", model.TransactionFundsTransfer, gomock.Any()).Return(float32(300), 3, nil)
+			},
+			expectedError: &LimitExceeded{Id: "17", Limit: "hourly_transfers"},
+		},
+		{
+			name:            "Hourly Transfers Not Applied To Debits",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             100,
+			limits:          model.Limits{HourlyTransfers: &count3},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:            "Error in GetLimits",
+			transactionType: model.TransactionWriteOffFunds,
+			sum:             100,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:            "Error in GetSpending",
+			transactionType: model.TransactionFundsTransfer,
+			sum:             100,
+			limits:          model.Limits{DailyTransfer: &sum1000},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetSpending(gomock.Any(), "17", model.TransactionFundsTransfer, gomock.Any()).Return(float32(0), 0, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockUser(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			services := NewUserService(&repository.Repository{User: repo}, testCase.limits, true, true)
+
+			// test
+			err := services.checkLimits(context.Background(), repo, "17", testCase.transactionType, testCase.sum)
+
+			// assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
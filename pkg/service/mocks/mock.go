@@ -5,6 +5,8 @@
 package mock_service
 
 import (
+	context "context"
+	model "for_avito_tech_with_gin/pkg/model"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,58 +36,292 @@ func (m *MockUser) EXPECT() *MockUserMockRecorder {
 }
 
 // AddFunds mocks base method.
-func (m *MockUser) AddFunds(userId int, sum float32) error {
+func (m *MockUser) AddFunds(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddFunds", userId, sum)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "AddFunds", ctx, userId, sum, info)
+	ret0, _ := ret[0].(*model.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // AddFunds indicates an expected call of AddFunds.
-func (mr *MockUserMockRecorder) AddFunds(userId, sum interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) AddFunds(ctx, userId, sum, info interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFunds", reflect.TypeOf((*MockUser)(nil).AddFunds), userId, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFunds", reflect.TypeOf((*MockUser)(nil).AddFunds), ctx, userId, sum, info)
+}
+
+// CreateUser mocks base method.
+func (m *MockUser) CreateUser(ctx context.Context, userId, externalRef string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, userId, externalRef)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockUserMockRecorder) CreateUser(ctx, userId, externalRef interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockUser)(nil).CreateUser), ctx, userId, externalRef)
 }
 
 // FundsTransfer mocks base method.
-func (m *MockUser) FundsTransfer(senderId, receiverId int, sum float32) error {
+func (m *MockUser) FundsTransfer(ctx context.Context, senderId, receiverId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FundsTransfer", senderId, receiverId, sum)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "FundsTransfer", ctx, senderId, receiverId, sum, info)
+	ret0, _ := ret[0].(*model.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // FundsTransfer indicates an expected call of FundsTransfer.
-func (mr *MockUserMockRecorder) FundsTransfer(senderId, receiverId, sum interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) FundsTransfer(ctx, senderId, receiverId, sum, info interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FundsTransfer", reflect.TypeOf((*MockUser)(nil).FundsTransfer), senderId, receiverId, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FundsTransfer", reflect.TypeOf((*MockUser)(nil).FundsTransfer), ctx, senderId, receiverId, sum, info)
 }
 
 // GetBalance mocks base method.
-func (m *MockUser) GetBalance(userId int) (float32, error) {
+func (m *MockUser) GetBalance(ctx context.Context, userId string) (*model.Balance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBalance", userId)
-	ret0, _ := ret[0].(float32)
+	ret := m.ctrl.Call(m, "GetBalance", ctx, userId)
+	ret0, _ := ret[0].(*model.Balance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetBalance indicates an expected call of GetBalance.
-func (mr *MockUserMockRecorder) GetBalance(userId interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) GetBalance(ctx, userId interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockUser)(nil).GetBalance), userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockUser)(nil).GetBalance), ctx, userId)
 }
 
-// WriteOffFunds mocks base method.
-func (m *MockUser) WriteOffFunds(userId int, sum float32) error {
+// GetHistory mocks base method.
+func (m *MockUser) GetHistory(ctx context.Context, userId string) ([]model.Transaction, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WriteOffFunds", userId, sum)
+	ret := m.ctrl.Call(m, "GetHistory", ctx, userId)
+	ret0, _ := ret[0].([]model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockUserMockRecorder) GetHistory(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockUser)(nil).GetHistory), ctx, userId)
+}
+
+// GetUser mocks base method.
+func (m *MockUser) GetUser(ctx context.Context, userId string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userId)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockUserMockRecorder) GetUser(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockUser)(nil).GetUser), ctx, userId)
+}
+
+// SetCreditLimit mocks base method.
+func (m *MockUser) SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCreditLimit", ctx, userId, creditLimit)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// SetCreditLimit indicates an expected call of SetCreditLimit.
+func (mr *MockUserMockRecorder) SetCreditLimit(ctx, userId, creditLimit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCreditLimit", reflect.TypeOf((*MockUser)(nil).SetCreditLimit), ctx, userId, creditLimit)
+}
+
+// SetLimits mocks base method.
+func (m *MockUser) SetLimits(ctx context.Context, userId string, limits model.Limits) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLimits", ctx, userId, limits)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLimits indicates an expected call of SetLimits.
+func (mr *MockUserMockRecorder) SetLimits(ctx, userId, limits interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLimits", reflect.TypeOf((*MockUser)(nil).SetLimits), ctx, userId, limits)
+}
+
+// SetStatus mocks base method.
+func (m *MockUser) SetStatus(ctx context.Context, userId, status, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetStatus", ctx, userId, status, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetStatus indicates an expected call of SetStatus.
+func (mr *MockUserMockRecorder) SetStatus(ctx, userId, status, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetStatus", reflect.TypeOf((*MockUser)(nil).SetStatus), ctx, userId, status, reason)
+}
+
+// WriteOffFunds mocks base method.
+func (m *MockUser) WriteOffFunds(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteOffFunds", ctx, userId, sum, info)
+	ret0, _ := ret[0].(*model.OperationResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // WriteOffFunds indicates an expected call of WriteOffFunds.
-func (mr *MockUserMockRecorder) WriteOffFunds(userId, sum interface{}) *gomock.Call {
+func (mr *MockUserMockRecorder) WriteOffFunds(ctx, userId, sum, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOffFunds", reflect.TypeOf((*MockUser)(nil).WriteOffFunds), ctx, userId, sum, info)
+}
+
+// MockTransaction is a mock of Transaction interface.
+type MockTransaction struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransactionMockRecorder
+}
+
+// MockTransactionMockRecorder is the mock recorder for MockTransaction.
+type MockTransactionMockRecorder struct {
+	mock *MockTransaction
+}
+
+// NewMockTransaction creates a new mock instance.
+func NewMockTransaction(ctrl *gomock.Controller) *MockTransaction {
+	mock := &MockTransaction{ctrl: ctrl}
+	mock.recorder = &MockTransactionMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransaction) EXPECT() *MockTransactionMockRecorder {
+	return m.recorder
+}
+
+// ReverseTransaction mocks base method.
+func (m *MockTransaction) ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransaction", ctx, transactionId, sum, allowNegative, info)
+	ret0, _ := ret[0].(*model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReverseTransaction indicates an expected call of ReverseTransaction.
+func (mr *MockTransactionMockRecorder) ReverseTransaction(ctx, transactionId, sum, allowNegative, info interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransaction", reflect.TypeOf((*MockTransaction)(nil).ReverseTransaction), ctx, transactionId, sum, allowNegative, info)
+}
+
+// MockLedger is a mock of Ledger interface.
+type MockLedger struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerMockRecorder
+}
+
+// MockLedgerMockRecorder is the mock recorder for MockLedger.
+type MockLedgerMockRecorder struct {
+	mock *MockLedger
+}
+
+// NewMockLedger creates a new mock instance.
+func NewMockLedger(ctrl *gomock.Controller) *MockLedger {
+	mock := &MockLedger{ctrl: ctrl}
+	mock.recorder = &MockLedgerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedger) EXPECT() *MockLedgerMockRecorder {
+	return m.recorder
+}
+
+// GetLedger mocks base method.
+func (m *MockLedger) GetLedger(ctx context.Context) (*model.Ledger, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedger", ctx)
+	ret0, _ := ret[0].(*model.Ledger)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedger indicates an expected call of GetLedger.
+func (mr *MockLedgerMockRecorder) GetLedger(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedger", reflect.TypeOf((*MockLedger)(nil).GetLedger), ctx)
+}
+
+// Reconcile mocks base method.
+func (m *MockLedger) Reconcile(ctx context.Context, repair bool) (*model.Reconciliation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reconcile", ctx, repair)
+	ret0, _ := ret[0].(*model.Reconciliation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reconcile indicates an expected call of Reconcile.
+func (mr *MockLedgerMockRecorder) Reconcile(ctx, repair interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconcile", reflect.TypeOf((*MockLedger)(nil).Reconcile), ctx, repair)
+}
+
+// MockAudit is a mock of Audit interface.
+type MockAudit struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditMockRecorder
+}
+
+// MockAuditMockRecorder is the mock recorder for MockAudit.
+type MockAuditMockRecorder struct {
+	mock *MockAudit
+}
+
+// NewMockAudit creates a new mock instance.
+func NewMockAudit(ctrl *gomock.Controller) *MockAudit {
+	mock := &MockAudit{ctrl: ctrl}
+	mock.recorder = &MockAuditMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAudit) EXPECT() *MockAuditMockRecorder {
+	return m.recorder
+}
+
+// GetAuditLog mocks base method.
+func (m *MockAudit) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditLog", ctx, filter)
+	ret0, _ := ret[0].([]model.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditLog indicates an expected call of GetAuditLog.
+func (mr *MockAuditMockRecorder) GetAuditLog(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditLog", reflect.TypeOf((*MockAudit)(nil).GetAuditLog), ctx, filter)
+}
+
+// VerifyAuditLog mocks base method.
+func (m *MockAudit) VerifyAuditLog(ctx context.Context, lastHash string) (*model.AuditVerification, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAuditLog", ctx, lastHash)
+	ret0, _ := ret[0].(*model.AuditVerification)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAuditLog indicates an expected call of VerifyAuditLog.
+func (mr *MockAuditMockRecorder) VerifyAuditLog(ctx, lastHash interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteOffFunds", reflect.TypeOf((*MockUser)(nil).WriteOffFunds), userId, sum)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAuditLog", reflect.TypeOf((*MockAudit)(nil).VerifyAuditLog), ctx, lastHash)
 }
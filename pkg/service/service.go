@@ -1,24 +1,90 @@
 package service
 
 import (
+	"context"
+	"for_avito_tech_with_gin/pkg/logging"
+	"for_avito_tech_with_gin/pkg/model"
 	"for_avito_tech_with_gin/pkg/repository"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 //go:generate mockgen -source=service.go -destination=mocks/mock.go
 
 type User interface {
-	AddFunds(userId int, sum float32) error
-	WriteOffFunds(userId int, sum float32) error
-	FundsTransfer(senderId int, receiverId int, sum float32) error
-	GetBalance(userId int) (float32, error)
+	CreateUser(ctx context.Context, userId string, externalRef string) (*model.User, error)
+	GetUser(ctx context.Context, userId string) (*model.User, error)
+	AddFunds(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error)
+	WriteOffFunds(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error)
+	FundsTransfer(ctx context.Context, senderId string, receiverId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error)
+	GetBalance(ctx context.Context, userId string) (*model.Balance, error)
+	SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) error
+	SetLimits(ctx context.Context, userId string, limits model.Limits) error
+	SetStatus(ctx context.Context, userId string, status string, reason string) error
+	GetHistory(ctx context.Context, userId string) ([]model.Transaction, error)
+}
+
+type Transaction interface {
+	ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error)
+}
+
+type Ledger interface {
+	GetLedger(ctx context.Context) (*model.Ledger, error)
+	Reconcile(ctx context.Context, repair bool) (*model.Reconciliation, error)
+}
+
+type Audit interface {
+	GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error)
+	VerifyAuditLog(ctx context.Context, lastHash string) (*model.AuditVerification, error)
 }
 
 type Service struct {
 	User
+	Transaction
+	Ledger
+	Audit
+
+	users *UserService
 }
 
-func NewService(r *repository.Repository) *Service {
+func NewService(r *repository.Repository, limits model.Limits, allowFrozenCredits bool, implicitCreation bool) *Service {
+	users := NewUserService(r, limits, allowFrozenCredits, implicitCreation)
 	return &Service{
-		User: NewUserService(r),
+		User:        users,
+		Transaction: NewTransactionService(r),
+		Ledger:      NewLedgerService(r),
+		Audit:       NewAuditService(r),
+		users:       users,
+	}
+}
+
+// SetDefaultLimits меняет лимиты для юзеров, у которых не заданы свои, при перезагрузке конфига
+func (s *Service) SetDefaultLimits(limits model.Limits) {
+	if s.users != nil {
+		s.users.SetDefaultLimits(limits)
+	}
+}
+
+// logger логгер сервисов, уровень задается в log.packages.service
+func logger(ctx context.Context) *logrus.Entry {
+	return logging.Package(ctx, "service")
+}
+
+// dbError логирует ошибку бд и переводит ее в ответ. Конфликт с параллельными изменениями, который не разрешился повторами
+// в репозитории, - ConcurrentUpdate, запрос можно повторить
+func dbError(ctx context.Context, err error) error {
+	logger(ctx).Error(err)
+	if errors.Is(err, repository.ErrRetriesExhausted) || repository.IsRetryable(err) {
+		return &ConcurrentUpdate{}
+	}
+	return &InternalServerError{}
+}
+
+// txError переводит в ответ ошибку из Repository.InTx: ошибки сервиса из fn возвращаются как есть, ошибки бд - через dbError
+func txError(ctx context.Context, err error) error {
+	var responseErr ResponseError
+	if errors.As(err, &responseErr) {
+		return responseErr
 	}
+	return dbError(ctx, err)
 }
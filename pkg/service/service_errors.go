@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 type ResponseError interface {
@@ -10,6 +11,12 @@ type ResponseError interface {
 	StatusCode() int
 }
 
+// RetryableError ошибка, после которой запрос можно повторить через RetryAfter (заголовок Retry-After)
+type RetryableError interface {
+	ResponseError
+	RetryAfter() time.Duration
+}
+
 // NegativeSum - для ситуаций, когда в запросе пришла сумма <=0
 type NegativeSum struct{}
 
@@ -34,11 +41,11 @@ func (r *SameId) StatusCode() int {
 
 // UserNotFound - для ситуаций, когда в базе не нашлось нужного юзера
 type UserNotFound struct {
-	Id int
+	Id string
 }
 
 func (r *UserNotFound) Error() string {
-	return fmt.Sprintf("user %d does not exist.", r.Id)
+	return fmt.Sprintf("user %s does not exist.", r.Id)
 }
 
 func (r *UserNotFound) StatusCode() int {
@@ -47,11 +54,11 @@ func (r *UserNotFound) StatusCode() int {
 
 // InsufficientFunds - для ситуаций, когда у юзера не хватает денег для перевода
 type InsufficientFunds struct {
-	Id int
+	Id string
 }
 
 func (r *InsufficientFunds) Error() string {
-	return fmt.Sprintf("user %d has insufficient funds.", r.Id)
+	return fmt.Sprintf("user %s has insufficient funds.", r.Id)
 }
 
 func (r *InsufficientFunds) StatusCode() int {
@@ -69,6 +76,21 @@ func (r *InternalServerError) StatusCode() int {
 	return http.StatusInternalServerError
 }
 
+// ConcurrentUpdate - для ситуаций, когда операция не прошла из-за параллельных операций с теми же юзерами
+type ConcurrentUpdate struct{}
+
+func (r *ConcurrentUpdate) Error() string {
+	return "too many concurrent operations, try again later."
+}
+
+func (r *ConcurrentUpdate) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (r *ConcurrentUpdate) RetryAfter() time.Duration {
+	return time.Second
+}
+
 // WrongParam - для ситуаций, когда в запросе указан кривой параметр, например неподдерживаемая валюта
 type WrongParam struct {
 	Param string
@@ -81,3 +103,135 @@ func (r *WrongParam) Error() string {
 func (r *WrongParam) StatusCode() int {
 	return http.StatusPreconditionFailed
 }
+
+// TransactionNotFound - для ситуаций, когда в базе не нашлось нужной операции
+type TransactionNotFound struct {
+	Id int
+}
+
+func (r *TransactionNotFound) Error() string {
+	return fmt.Sprintf("transaction %d does not exist.", r.Id)
+}
+
+func (r *TransactionNotFound) StatusCode() int {
+	return http.StatusNotFound
+}
+
+// NotReversible - для ситуаций, когда пытаются отменить операцию, которую отменять нельзя (например сам возврат)
+type NotReversible struct {
+	Id int
+}
+
+func (r *NotReversible) Error() string {
+	return fmt.Sprintf("transaction %d can't be reversed.", r.Id)
+}
+
+func (r *NotReversible) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// AlreadyReversed - для ситуаций, когда операцию уже вернули полностью
+type AlreadyReversed struct {
+	Id int
+}
+
+func (r *AlreadyReversed) Error() string {
+	return fmt.Sprintf("transaction %d has already been reversed.", r.Id)
+}
+
+func (r *AlreadyReversed) StatusCode() int {
+	return http.StatusConflict
+}
+
+// ReversalExceedsSum - для ситуаций, когда сумма возврата больше невозвращенного остатка операции
+type ReversalExceedsSum struct {
+	Id int
+}
+
+func (r *ReversalExceedsSum) Error() string {
+	return fmt.Sprintf("reversal sum exceeds remaining sum of transaction %d.", r.Id)
+}
+
+func (r *ReversalExceedsSum) StatusCode() int {
+	return http.StatusPreconditionFailed
+}
+
+// LimitExceeded - для ситуаций, когда операция превышает лимит юзера на списания или переводы
+type LimitExceeded struct {
+	Id    string
+	Limit string
+}
+
+func (r *LimitExceeded) Error() string {
+	return fmt.Sprintf("user %s exceeded %s limit.", r.Id, r.Limit)
+}
+
+func (r *LimitExceeded) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// UserFrozen - для ситуаций, когда операция недоступна замороженному юзеру
+type UserFrozen struct {
+	Id string
+}
+
+func (r *UserFrozen) Error() string {
+	return fmt.Sprintf("user %s is frozen.", r.Id)
+}
+
+func (r *UserFrozen) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// UserClosed - для ситуаций, когда юзер закрыт и с ним уже ничего нельзя делать
+type UserClosed struct {
+	Id string
+}
+
+func (r *UserClosed) Error() string {
+	return fmt.Sprintf("user %s is closed.", r.Id)
+}
+
+func (r *UserClosed) StatusCode() int {
+	return http.StatusForbidden
+}
+
+// StatusNotChanged - для ситуаций, когда юзеру ставят статус, который у него уже есть
+type StatusNotChanged struct {
+	Id     string
+	Status string
+}
+
+func (r *StatusNotChanged) Error() string {
+	return fmt.Sprintf("user %s is already %s.", r.Id, r.Status)
+}
+
+func (r *StatusNotChanged) StatusCode() int {
+	return http.StatusConflict
+}
+
+// NonZeroBalance - для ситуаций, когда закрывают юзера, у которого остались деньги или долг
+type NonZeroBalance struct {
+	Id string
+}
+
+func (r *NonZeroBalance) Error() string {
+	return fmt.Sprintf("user %s balance is not zero.", r.Id)
+}
+
+func (r *NonZeroBalance) StatusCode() int {
+	return http.StatusConflict
+}
+
+// UserAlreadyExists - для ситуаций, когда создают юзера, который уже есть
+type UserAlreadyExists struct {
+	Id string
+}
+
+func (r *UserAlreadyExists) Error() string {
+	return fmt.Sprintf("user %s already exists.", r.Id)
+}
+
+func (r *UserAlreadyExists) StatusCode() int {
+	return http.StatusConflict
+}
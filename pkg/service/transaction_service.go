@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	"for_avito_tech_with_gin/pkg/tracing"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type TransactionService struct {
+	repo *repository.Repository
+}
+
+func NewTransactionService(repo *repository.Repository) *TransactionService {
+	return &TransactionService{repo: repo}
+}
+
+// ReverseTransaction отменяет операцию transactionId полностью (sum = 0) или частично.
+// Исходная операция не удаляется, вместо нее создается связанная с ней компенсирующая операция
+func (r *TransactionService) ReverseTransaction(ctx context.Context, transactionId int, sum float32, allowNegative bool, info model.TransactionInfo) (*model.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.ReverseTransaction", attribute.Int("transaction.id", transactionId))
+	defer span.End()
+
+	if sum < 0 {
+		return nil, &NegativeSum{}
+	}
+	if err := validateTransactionInfo(info); err != nil {
+		return nil, err
+	}
+
+	original, err := r.repo.GetTransaction(ctx, transactionId)
+	if errors.Is(err, repository.ErrTransactionNotFound) {
+		return nil, &TransactionNotFound{Id: transactionId}
+	}
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	// начальный остаток - не операция юзера, а баланс, который был до истории операций
+	if original.Type == model.TransactionReversal || original.Type == model.TransactionOpeningBalance {
+		return nil, &NotReversible{Id: transactionId}
+	}
+
+	// замороженным юзерам возврат делать можно (например вернуть украденное), закрытым - нет
+	for _, userId := range []*string{original.SenderId, original.ReceiverId} {
+		if userId == nil {
+			continue
+		}
+		user, err := r.repo.GetUser(ctx, *userId)
+		if err != nil {
+			return nil, dbError(ctx, err)
+		}
+		if user.Status == model.UserStatusClosed {
+			return nil, &UserClosed{Id: *userId}
+		}
+	}
+
+	var reversal *model.Transaction
+	err = r.repo.InTx(ctx, func(tx repository.Tx) error {
+		var err error
+		reversal, err = tx.ReverseTransaction(ctx, transactionId, sum, allowNegative, info)
+		if err != nil {
+			return err
+		}
+		return auditTransaction(ctx, r.repo, tx, reversal)
+	})
+	switch {
+	case err == nil:
+		return reversal, nil
+	case errors.Is(err, repository.ErrAlreadyReversed):
+		return nil, &AlreadyReversed{Id: transactionId}
+	case errors.Is(err, repository.ErrReversalExceedsSum):
+		return nil, &ReversalExceedsSum{Id: transactionId}
+	case errors.Is(err, repository.ErrInsufficientFunds) && original.ReceiverId != nil:
+		return nil, &InsufficientFunds{Id: *original.ReceiverId}
+	default:
+		return nil, dbError(ctx, err)
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
+	"for_avito_tech_with_gin/pkg/repository"
+	mock_repository "for_avito_tech_with_gin/pkg/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type mockTransactionRepositoryBehavior func(s *mock_repository.MockTransaction)
+
+func TestTransactionService_ReverseTransaction(t *testing.T) {
+	senderId, receiverId, transactionId := "17", "18", 5
+	transfer := &model.Transaction{Id: transactionId, Type: model.TransactionFundsTransfer, SenderId: &senderId, ReceiverId: &receiverId, Sum: 100}
+	reversal := &model.Transaction{Id: 6, Type: model.TransactionReversal, SenderId: &receiverId, ReceiverId: &senderId, Sum: 100, ReversedId: &transactionId}
+
+	testData := []struct {
+		name                   string
+		sum                    float32
+		allowNegative          bool
+		info                   model.TransactionInfo
+		mockRepositoryBehavior mockTransactionRepositoryBehavior
+		mockUserBehavior       mockRepositoryBehavior // если nil - оба юзера активны
+		mockTxBehavior         mockTxBehavior
+		expectedTransaction    *model.Transaction
+		expectedError          error
+	}{
+		{
+			name: "OK",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(0), false, model.TransactionInfo{}).Return(reversal, nil)
+			},
+			expectedTransaction: reversal,
+			expectedError:       nil,
+		},
+		{
+			name:          "OK Partial Allow Negative",
+			sum:           30,
+			allowNegative: true,
+			info:          model.TransactionInfo{Comment: "частичный возврат"},
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(30), true, model.TransactionInfo{Comment: "частичный возврат"}).Return(reversal, nil)
+			},
+			expectedTransaction: reversal,
+			expectedError:       nil,
+		},
+		{
+			name:                   "Incorrect Sum",
+			sum:                    -30,
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {},
+			expectedError:          &NegativeSum{},
+		},
+		{
+			name:                   "Wrong Info",
+			info:                   model.TransactionInfo{Source: "call center"},
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {},
+			expectedError:          &WrongParam{Param: "source"},
+		},
+		{
+			name: "Transaction Not Found",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(nil, errors.Wrap(repository.ErrTransactionNotFound, "lol kek cheburek."))
+			},
+			expectedError: &TransactionNotFound{Id: 5},
+		},
+		{
+			name: "Error in GetTransaction",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name: "Reversal Of Reversal",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(&model.Transaction{Id: 5, Type: model.TransactionReversal}, nil)
+			},
+			expectedError: &NotReversible{Id: 5},
+		},
+		{
+			name: "Reversal Of Opening Balance",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(&model.Transaction{Id: 5, Type: model.TransactionOpeningBalance}, nil)
+			},
+			expectedError: &NotReversible{Id: 5},
+		},
+		{
+			name: "OK Frozen Receiver",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(0), false, model.TransactionInfo{}).Return(reversal, nil)
+			},
+			mockUserBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusActive}, nil)
+				s.EXPECT().GetUser(gomock.Any(), "18").Return(&model.User{Id: 18, UserId: "18", Status: model.UserStatusFrozen}, nil)
+			},
+			expectedTransaction: reversal,
+			expectedError:       nil,
+		},
+		{
+			name: "Sender Closed",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockUserBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusClosed}, nil)
+			},
+			expectedError: &UserClosed{Id: "17"},
+		},
+		{
+			name: "Error in GetUser",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockUserBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name: "Already Reversed",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(0), false, model.TransactionInfo{}).
+					Return(nil, errors.Wrap(repository.ErrAlreadyReversed, "lol kek cheburek."))
+			},
+			expectedError: &AlreadyReversed{Id: 5},
+		},
+		{
+			name: "Exceeds Sum",
+			sum:  500,
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(500), false, model.TransactionInfo{}).
+					Return(nil, errors.Wrap(repository.ErrReversalExceedsSum, "lol kek cheburek."))
+			},
+			expectedError: &ReversalExceedsSum{Id: 5},
+		},
+		{
+			name: "Receiver Insufficient Funds",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(0), false, model.TransactionInfo{}).
+					Return(nil, errors.Wrap(repository.ErrInsufficientFunds, "lol kek cheburek."))
+			},
+			expectedError: &InsufficientFunds{Id: "18"},
+		},
+		{
+			name: "Error in ReverseTransaction",
+			mockRepositoryBehavior: func(s *mock_repository.MockTransaction) {
+				s.EXPECT().GetTransaction(gomock.Any(), 5).Return(transfer, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().ReverseTransaction(gomock.Any(), 5, float32(0), false, model.TransactionInfo{}).
+					Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockTransaction(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			users := mock_repository.NewMockUser(c)
+			if testCase.mockUserBehavior != nil {
+				testCase.mockUserBehavior(users)
+			} else {
+				users.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(&model.User{Status: model.UserStatusActive}, nil).AnyTimes()
+			}
+
+			uow := mock_repository.NewMockUnitOfWork(c)
+			if testCase.mockTxBehavior != nil {
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
+
+			services := NewTransactionService(&repository.Repository{User: users, Transaction: repo, UnitOfWork: uow})
+
+			// test
+			transaction, err := services.ReverseTransaction(context.Background(), transactionId, testCase.sum, testCase.allowNegative, testCase.info)
+
+			// assert
+			assert.Equal(t, testCase.expectedTransaction, transaction)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
@@ -1,144 +1,535 @@
 package service
 
 import (
+	"context"
+	"for_avito_tech_with_gin/pkg/model"
 	"for_avito_tech_with_gin/pkg/repository"
+	"for_avito_tech_with_gin/pkg/tracing"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"regexp"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxUserIdLength             = 64
+	maxTransactionIdLength      = 64
+	maxTransactionCommentLength = 255
+)
+
+var (
+	userIdRegexp        = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+	transactionIdRegexp = regexp.MustCompile(`^[A-Za-z0-9_.:-]*$`)
 )
 
 type UserService struct {
-	repo *repository.Repository
+	repo               *repository.Repository
+	limits             atomic.Value // model.Limits, меняются при перезагрузке конфига
+	allowFrozenCredits bool
+	implicitCreation   bool
 }
 
-func NewUserService(repo *repository.Repository) *UserService {
-	return &UserService{repo: repo}
+// NewUserService limits - лимиты на списания и переводы для юзеров, у которых не заданы свои,
+// allowFrozenCredits - можно ли начислять деньги замороженным юзерам,
+// implicitCreation - создавать ли несуществующих юзеров при начислении и переводе им денег
+func NewUserService(repo *repository.Repository, limits model.Limits, allowFrozenCredits bool, implicitCreation bool) *UserService {
+	s := &UserService{repo: repo, allowFrozenCredits: allowFrozenCredits, implicitCreation: implicitCreation}
+	s.limits.Store(limits)
+	return s
 }
 
-// TODO: объединить AddFunds и WriteOffFunds
+// SetDefaultLimits меняет лимиты для юзеров, у которых не заданы свои, на лету
+func (r *UserService) SetDefaultLimits(limits model.Limits) {
+	r.limits.Store(limits)
+}
 
-func (r *UserService) AddFunds(userId int, sum float32) error {
-	if sum <= 0 {
-		return &NegativeSum{}
+// CreateUser явно создает юзера с нулевым балансом
+func (r *UserService) CreateUser(ctx context.Context, userId string, externalRef string) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateUser", attribute.String("user.id", userId))
+	defer span.End()
+
+	if err := validateUserId(userId, "id"); err != nil {
+		return nil, err
+	}
+	if len(externalRef) > maxTransactionIdLength || !transactionIdRegexp.MatchString(externalRef) {
+		return nil, &WrongParam{Param: "external_ref"}
+	}
+
+	user, err := r.repo.CreateUser(ctx, userId, 0, externalRef)
+	if errors.Is(err, repository.ErrUserAlreadyExists) {
+		return nil, &UserAlreadyExists{Id: userId}
+	}
+	if err != nil {
+		return nil, dbError(ctx, err)
 	}
 
-	ex, err := r.repo.IsUserExist(userId)
+	return user, nil
+}
+
+func (r *UserService) GetUser(ctx context.Context, userId string) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUser", attribute.String("user.id", userId))
+	defer span.End()
+
+	ex, err := r.repo.IsUserExist(ctx, userId)
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return nil, dbError(ctx, err)
 	}
 	if !ex {
-		err := r.repo.CreateUser(userId, sum)
+		return nil, &UserNotFound{Id: userId}
+	}
+
+	user, err := r.repo.GetUser(ctx, userId)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	return user, nil
+}
+
+// TODO: объединить AddFunds и WriteOffFunds
+
+func (r *UserService) AddFunds(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.AddFunds", attribute.String("user.id", userId))
+	defer span.End()
+
+	if sum <= 0 {
+		return nil, &NegativeSum{}
+	}
+	if err := validateUserId(userId, "id"); err != nil {
+		return nil, err
+	}
+	if err := validateTransactionInfo(info); err != nil {
+		return nil, err
+	}
+
+	// создание юзера и начисление - одна транзакция, чтобы при ошибке начисления не остался пустой юзер
+	var result *model.OperationResult
+	err := r.repo.InTx(ctx, func(tx repository.Tx) error {
+		ex, err := tx.IsUserExist(ctx, userId)
 		if err != nil {
-			logrus.Error(err)
-			return &InternalServerError{}
+			return err
+		}
+		if !ex {
+			if !r.implicitCreation {
+				return &UserNotFound{Id: userId}
+			}
+			if _, err := tx.CreateUser(ctx, userId, 0, ""); err != nil && !errors.Is(err, repository.ErrUserAlreadyExists) {
+				return err
+			}
+		} else if err := r.checkStatus(ctx, tx, userId, false); err != nil {
+			return err
 		}
-	} else {
-		_, err := r.repo.UpdateBalance(userId, sum)
+
+		result, err = tx.Post(ctx, model.NewCredit(userId, sum, info))
 		if err != nil {
-			logrus.Error(err)
-			return &InternalServerError{}
+			return err
 		}
+		return auditTransaction(ctx, r.repo, tx, result.Transaction)
+	})
+	if err != nil {
+		return nil, txError(ctx, err)
 	}
 
-	return nil
+	return result, nil
 }
 
-func (r *UserService) WriteOffFunds(userId int, sum float32) error {
+func (r *UserService) WriteOffFunds(ctx context.Context, userId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.WriteOffFunds", attribute.String("user.id", userId))
+	defer span.End()
+
 	if sum <= 0 {
-		return &NegativeSum{}
+		return nil, &NegativeSum{}
+	}
+	if err := validateTransactionInfo(info); err != nil {
+		return nil, err
 	}
 
-	ex, err := r.repo.IsUserExist(userId)
+	// строка юзера блокируется до проверки лимитов: параллельное списание ждет блокировку, после коммита текущего падает
+	// с ошибкой сериализации и повторяется целиком, уже считая потраченное вместе с текущим
+	var result *model.OperationResult
+	err := r.repo.InTx(ctx, func(tx repository.Tx) error {
+		ex, err := tx.IsUserExist(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if !ex {
+			return &UserNotFound{Id: userId}
+		}
+
+		user, err := tx.GetUserForUpdate(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if err := r.statusError(user, true); err != nil {
+			return err
+		}
+
+		if err := r.checkLimits(ctx, tx, userId, model.TransactionWriteOffFunds, sum); err != nil {
+			return err
+		}
+
+		if user.Balance+user.CreditLimit < sum {
+			return &InsufficientFunds{Id: userId}
+		}
+
+		// лимит проверяется еще раз атомарно в репозитории
+		result, err = tx.Post(ctx, model.NewWriteOff(userId, sum, info))
+		if errors.Is(err, repository.ErrInsufficientFunds) {
+			return &InsufficientFunds{Id: userId}
+		}
+		if err != nil {
+			return err
+		}
+		return auditTransaction(ctx, r.repo, tx, result.Transaction)
+	})
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return nil, txError(ctx, err)
 	}
-	if !ex {
-		return &UserNotFound{Id: userId}
+
+	return result, nil
+}
+
+func (r *UserService) FundsTransfer(ctx context.Context, senderId string, receiverId string, sum float32, info model.TransactionInfo) (*model.OperationResult, error) {
+	ctx, span := tracing.Start(ctx, "UserService.FundsTransfer", attribute.String("sender.id", senderId), attribute.String("receiver.id", receiverId))
+	defer span.End()
+
+	if sum <= 0 {
+		return nil, &NegativeSum{}
 	}
+	if senderId == receiverId {
+		return nil, &SameId{}
+	}
+	if err := validateUserId(receiverId, "receiver_id"); err != nil {
+		return nil, err
+	}
+	if err := validateTransactionInfo(info); err != nil {
+		return nil, err
+	}
+
+	// Все проверки, создание получателя и перевод - одна транзакция: если перевод не прошел, получатель не создается
+	var result *model.OperationResult
+	err := r.repo.InTx(ctx, func(tx repository.Tx) error {
+		// Строки отправителя и получателя блокируются сразу и по порядку user_id, как и в Post, поэтому встречные переводы
+		// не ловят дедлок. Параллельный перевод с теми же юзерами падает с ошибкой сериализации и повторяется целиком,
+		// поэтому лимиты не пропустят два перевода, посчитанные без друг друга
+		users, err := tx.GetUsersForUpdate(ctx, senderId, receiverId)
+		if err != nil {
+			return err
+		}
+
+		// Проверить существует ли отправляющий юзер (если не существует - вернуть ошибку)
+		sender, ok := users[senderId]
+		if !ok {
+			return &UserNotFound{Id: senderId}
+		}
+
+		// Проверить не заморожен и не закрыт ли отправляющий юзер (если да - вернуть ошибку)
+		if err := r.statusError(sender, true); err != nil {
+			return err
+		}
 
-	user, err := r.repo.GetUser(userId)
+		// Проверить не превышает ли перевод лимиты отправляющего юзера (если превышает - вернуть ошибку)
+		if err := r.checkLimits(ctx, tx, senderId, model.TransactionFundsTransfer, sum); err != nil {
+			return err
+		}
+
+		// Проверить достаточно ли средств у отправляющего юзера с учетом кредитного лимита (если нет - вернуть ошибку)
+		if sender.Balance+sender.CreditLimit < sum {
+			return &InsufficientFunds{Id: senderId}
+		}
+
+		// Проверить существует ли получающий юзер (если не существует - создать или вернуть ошибку, если существует - может ли он принимать деньги)
+		if receiver, ok := users[receiverId]; ok {
+			if err := r.statusError(receiver, false); err != nil {
+				return err
+			}
+		} else if !r.implicitCreation {
+			return &UserNotFound{Id: receiverId}
+		} else if _, err := tx.CreateUser(ctx, receiverId, 0, ""); err != nil && !errors.Is(err, repository.ErrUserAlreadyExists) {
+			return err
+		}
+
+		result, err = tx.Post(ctx, model.NewTransfer(senderId, receiverId, sum, info))
+		if errors.Is(err, repository.ErrInsufficientFunds) {
+			return &InsufficientFunds{Id: senderId}
+		}
+		if err != nil {
+			return err
+		}
+		return auditTransaction(ctx, r.repo, tx, result.Transaction)
+	})
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return nil, txError(ctx, err)
 	}
-	if user.Balance < sum {
-		return &InsufficientFunds{Id: userId}
+
+	return result, nil
+}
+
+func (r *UserService) GetBalance(ctx context.Context, userId string) (*model.Balance, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetBalance", attribute.String("user.id", userId))
+	defer span.End()
+	// баланс и история только читаются, небольшое отставание реплики тут допустимо
+	ctx = repository.FromReplica(ctx)
+
+	ex, err := r.repo.IsUserExist(ctx, userId)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	if !ex {
+		return nil, &UserNotFound{Id: userId}
 	}
 
-	if _, err := r.repo.UpdateBalance(userId, -sum); err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+	user, err := r.repo.GetUser(ctx, userId)
+	if err != nil {
+		return nil, dbError(ctx, err)
 	}
 
-	return nil
+	return &model.Balance{
+		Balance:     user.Balance,
+		CreditLimit: user.CreditLimit,
+		Available:   user.Balance + user.CreditLimit,
+	}, nil
 }
 
-func (r *UserService) FundsTransfer(senderId int, receiverId int, sum float32) error {
-	if sum <= 0 {
-		return &NegativeSum{}
+// SetLimits задает юзеру персональные лимиты на списания и переводы, nil значения - действуют лимиты по умолчанию
+func (r *UserService) SetLimits(ctx context.Context, userId string, limits model.Limits) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetLimits", attribute.String("user.id", userId))
+	defer span.End()
+
+	sums := []struct {
+		param string
+		limit *float32
+	}{
+		{"max_transaction_sum", limits.MaxTransactionSum},
+		{"daily_debit", limits.DailyDebit},
+		{"monthly_debit", limits.MonthlyDebit},
+		{"daily_transfer", limits.DailyTransfer},
+		{"monthly_transfer", limits.MonthlyTransfer},
 	}
-	if senderId == receiverId {
-		return &SameId{}
+	for _, sum := range sums {
+		if sum.limit != nil && *sum.limit < 0 {
+			return &WrongParam{Param: sum.param}
+		}
+	}
+	if limits.HourlyTransfers != nil && *limits.HourlyTransfers < 0 {
+		return &WrongParam{Param: "hourly_transfers"}
 	}
 
-	// Проверить существует ли отправляющий юзер (если не существует - вернуть ошибку)
-	ex, err := r.repo.IsUserExist(senderId)
+	ex, err := r.repo.IsUserExist(ctx, userId)
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return dbError(ctx, err)
 	}
 	if !ex {
-		return &UserNotFound{Id: senderId}
+		return &UserNotFound{Id: userId}
 	}
 
-	// Проверить достаточно ли средств у отправляющего юзера (если нет - вернуть ошибку)
-	user, err := r.repo.GetUser(senderId)
+	err = r.repo.InTx(ctx, func(tx repository.Tx) error {
+		if err := tx.SetLimits(ctx, userId, limits); err != nil {
+			return err
+		}
+		return audit(ctx, r.repo, tx, model.AuditSetLimits, []string{userId}, nil, limits)
+	})
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return dbError(ctx, err)
 	}
-	if user.Balance < sum {
-		return &InsufficientFunds{Id: senderId}
+
+	return nil
+}
+
+// SetStatus замораживает, размораживает или закрывает юзера. Закрыть можно только юзера с нулевым балансом,
+// закрытого юзера вернуть уже нельзя
+func (r *UserService) SetStatus(ctx context.Context, userId string, status string, reason string) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetStatus", attribute.String("user.id", userId))
+	defer span.End()
+
+	switch status {
+	case model.UserStatusActive, model.UserStatusFrozen, model.UserStatusClosed:
+	default:
+		return &WrongParam{Param: "status"}
+	}
+	if err := validateReason(reason); err != nil {
+		return err
+	}
+
+	// строка юзера блокируется до проверок: параллельная смена статуса ждет блокировку, а после коммита текущей падает
+	// с ошибкой сериализации и повторяется целиком, поэтому в аудит попадает актуальный "from"
+	var from string
+	err := r.repo.InTx(ctx, func(tx repository.Tx) error {
+		ex, err := tx.IsUserExist(ctx, userId)
+		if err != nil {
+			return err
+		}
+		if !ex {
+			return &UserNotFound{Id: userId}
+		}
+
+		user, err := tx.GetUserForUpdate(ctx, userId)
+		if err != nil {
+			return err
+		}
+		switch {
+		case user.Status == model.UserStatusClosed:
+			return &UserClosed{Id: userId}
+		case user.Status == status:
+			return &StatusNotChanged{Id: userId, Status: status}
+		case status == model.UserStatusClosed && user.Balance != 0:
+			return &NonZeroBalance{Id: userId}
+		}
+		from = user.Status
+
+		// баланс и статус проверяются еще раз атомарно в репозитории
+		err = tx.SetStatus(ctx, userId, status, reason)
+		switch {
+		case errors.Is(err, repository.ErrNonZeroBalance):
+			return &NonZeroBalance{Id: userId}
+		case errors.Is(err, repository.ErrUserClosed):
+			return &UserClosed{Id: userId}
+		case err != nil:
+			return err
+		}
+		return audit(ctx, r.repo, tx, model.AuditSetStatus, []string{userId}, nil, map[string]string{
+			"from":   from,
+			"to":     status,
+			"reason": reason,
+		})
+	})
+	if err != nil {
+		return txError(ctx, err)
+	}
+	logger(ctx).WithFields(logrus.Fields{
+		"user_id": userId,
+		"from":    from,
+		"to":      status,
+		"reason":  reason,
+	}).Info("user status changed")
+
+	return nil
+}
+
+// SetCreditLimit задает юзеру кредитный лимит, nil - вернуть лимит по умолчанию
+func (r *UserService) SetCreditLimit(ctx context.Context, userId string, creditLimit *float32) error {
+	ctx, span := tracing.Start(ctx, "UserService.SetCreditLimit", attribute.String("user.id", userId))
+	defer span.End()
+
+	if creditLimit != nil && *creditLimit < 0 {
+		return &WrongParam{Param: "credit_limit"}
 	}
 
-	// Проверить существует ли получающий юзер (если не существует - создать)
-	ex, err = r.repo.IsUserExist(receiverId)
+	ex, err := r.repo.IsUserExist(ctx, userId)
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return dbError(ctx, err)
 	}
 	if !ex {
-		err := r.repo.CreateUser(receiverId, 0)
-		if err != nil {
-			logrus.Error(err)
-			return &InternalServerError{}
-		}
+		return &UserNotFound{Id: userId}
 	}
 
-	err = r.repo.CreateFundsTransaction(senderId, receiverId, sum)
+	err = r.repo.InTx(ctx, func(tx repository.Tx) error {
+		if _, err := tx.SetCreditLimit(ctx, userId, creditLimit); err != nil {
+			return err
+		}
+		return audit(ctx, r.repo, tx, model.AuditSetCreditLimit, []string{userId}, nil, map[string]*float32{"credit_limit": creditLimit})
+	})
 	if err != nil {
-		logrus.Error(err)
-		return &InternalServerError{}
+		return dbError(ctx, err)
 	}
 
 	return nil
 }
 
-func (r *UserService) GetBalance(userId int) (float32, error) {
-	ex, err := r.repo.IsUserExist(userId)
+func (r *UserService) GetHistory(ctx context.Context, userId string) ([]model.Transaction, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetHistory", attribute.String("user.id", userId))
+	defer span.End()
+	ctx = repository.FromReplica(ctx)
+
+	ex, err := r.repo.IsUserExist(ctx, userId)
 	if err != nil {
-		logrus.Error(err)
-		return 0, &InternalServerError{}
+		return nil, dbError(ctx, err)
 	}
 	if !ex {
-		return 0, &UserNotFound{Id: userId}
+		return nil, &UserNotFound{Id: userId}
 	}
 
-	user, err := r.repo.GetUser(userId)
+	transactions, err := r.repo.GetTransactions(ctx, userId)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	return transactions, nil
+}
+
+// checkStatus достает юзера из repo и проверяет, можно ли с него списывать (debit) или ему начислять
+func (r *UserService) checkStatus(ctx context.Context, repo repository.User, userId string, debit bool) error {
+	user, err := repo.GetUser(ctx, userId)
 	if err != nil {
-		logrus.Error(err)
-		return 0, &InternalServerError{}
+		return dbError(ctx, err)
+	}
+
+	return r.statusError(user, debit)
+}
+
+// statusError возвращает ошибку, если статус юзера не позволяет списание (debit) или начисление
+func (r *UserService) statusError(user *model.User, debit bool) error {
+	switch {
+	case user.Status == model.UserStatusClosed:
+		return &UserClosed{Id: user.UserId}
+	case user.Status == model.UserStatusFrozen && (debit || !r.allowFrozenCredits):
+		return &UserFrozen{Id: user.UserId}
 	}
 
-	return user.Balance, nil
+	return nil
+}
+
+// validateReason причина смены статуса обязательна и проверяется так же, как комментарий к операции
+func validateReason(reason string) error {
+	if reason == "" || !utf8.ValidString(reason) || utf8.RuneCountInString(reason) > maxTransactionCommentLength {
+		return &WrongParam{Param: "reason"}
+	}
+	for _, c := range reason {
+		if unicode.IsControl(c) {
+			return &WrongParam{Param: "reason"}
+		}
+	}
+
+	return nil
+}
+
+// validateUserId проверяет id юзера перед тем, как его создать: до 64 символов, латиница, цифры и _ - . :
+// (подходят и старые целые id, и UUID)
+func validateUserId(userId string, param string) error {
+	if len(userId) > maxUserIdLength || !userIdRegexp.MatchString(userId) {
+		return &WrongParam{Param: param}
+	}
+
+	return nil
+}
+
+// validateTransactionInfo проверяет длину и набор символов необязательных полей операции
+func validateTransactionInfo(info model.TransactionInfo) error {
+	ids := []struct {
+		param string
+		value string
+	}{
+		{"order_id", info.OrderId},
+		{"service_id", info.ServiceId},
+		{"source", info.Source},
+	}
+	for _, id := range ids {
+		if len(id.value) > maxTransactionIdLength || !transactionIdRegexp.MatchString(id.value) {
+			return &WrongParam{Param: id.param}
+		}
+	}
+
+	if !utf8.ValidString(info.Comment) || utf8.RuneCountInString(info.Comment) > maxTransactionCommentLength {
+		return &WrongParam{Param: "comment"}
+	}
+	for _, c := range info.Comment {
+		if unicode.IsControl(c) {
+			return &WrongParam{Param: "comment"}
+		}
+	}
+
+	return nil
 }
@@ -1,78 +1,291 @@
 package service
 
 import (
+	"context"
 	"for_avito_tech_with_gin/pkg/model"
 	"for_avito_tech_with_gin/pkg/repository"
 	mock_repository "for_avito_tech_with_gin/pkg/repository/mocks"
 	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
 type mockRepositoryBehavior func(s *mock_repository.MockUser)
 
+// mockTxBehavior вызовы репозитория внутри транзакции, nil - транзакция не открывается
+type mockTxBehavior func(s *mock_repository.MockTx)
+
 func TestUserService_AddFunds(t *testing.T) {
+	result := &model.OperationResult{Transaction: &model.Transaction{Id: 5}}
+
 	testData := []struct {
-		name                   string
-		userId                 int
-		sum                    float32
-		mockRepositoryBehavior mockRepositoryBehavior
-		expectedError          error
+		name               string
+		userId             string
+		sum                float32
+		info               model.TransactionInfo
+		allowFrozenCredits bool
+		noImplicitCreation bool
+		beginError         error
+		mockTxBehavior     mockTxBehavior
+		expectedResult     *model.OperationResult
+		expectedError      error
 	}{
 		{
 			name:   "OK When Exist",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().UpdateBalance(17, float32(5000)).Return(&model.User{}, nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusActive}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("17", 5000, model.TransactionInfo{})).Return(result, nil)
 			},
-			expectedError: nil,
+			expectedResult: result,
+			expectedError:  nil,
 		},
 		{
 			name:   "OK When Not Exist",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, nil)
-				s.EXPECT().CreateUser(17, float32(5000)).Return(nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "17", float32(0), "").Return(&model.User{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("17", 5000, model.TransactionInfo{})).Return(result, nil)
 			},
-			expectedError: nil,
+			expectedResult: result,
+			expectedError:  nil,
 		},
 		{
-			name:                   "Incorrect Sum",
-			userId:                 17,
-			sum:                    0,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
-			expectedError:          &NegativeSum{},
+			name:   "OK UUID When Not Exist",
+			userId: "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13").Return(false, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13", float32(0), "").Return(&model.User{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("6f1c2a9e-3b7d-4e8a-9c21-5d4f0b7e8a13", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:          "Wrong Id",
+			userId:        "user 17",
+			sum:           5000,
+			expectedError: &WrongParam{Param: "id"},
+		},
+		{
+			name:   "OK With Info",
+			userId: "17",
+			sum:    5000,
+			info:   model.TransactionInfo{OrderId: "order-1", ServiceId: "42", Comment: "оплата заказа", Source: "web"},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusActive}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("17", 5000, model.TransactionInfo{
+					OrderId: "order-1", ServiceId: "42", Comment: "оплата заказа", Source: "web"})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:               "Not Exist Without Implicit Creation",
+			userId:             "17",
+			sum:                5000,
+			noImplicitCreation: true,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+			},
+			expectedError: &UserNotFound{Id: "17"},
+		},
+		{
+			name:               "OK Frozen",
+			userId:             "17",
+			sum:                5000,
+			allowFrozenCredits: true,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusFrozen}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("17", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:   "Frozen",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusFrozen}, nil)
+			},
+			expectedError: &UserFrozen{Id: "17"},
+		},
+		{
+			name:               "Closed",
+			userId:             "17",
+			sum:                5000,
+			allowFrozenCredits: true,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusClosed}, nil)
+			},
+			expectedError: &UserClosed{Id: "17"},
+		},
+		{
+			name:   "Error in GetUser",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:          "Incorrect Sum",
+			userId:        "17",
+			sum:           0,
+			expectedError: &NegativeSum{},
+		},
+		{
+			name:          "Wrong Info",
+			userId:        "17",
+			sum:           5000,
+			info:          model.TransactionInfo{OrderId: "order 1"},
+			expectedError: &WrongParam{Param: "order_id"},
 		},
 		{
 			name:   "Error in IsUserExist",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:   "Error in CreateUser",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, nil)
-				s.EXPECT().CreateUser(17, float32(5000)).Return(errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "17", float32(0), "").Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:   "Error in UpdateBalance",
-			userId: 17,
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusActive}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("17", 5000, model.TransactionInfo{})).Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:          "Error in Begin",
+			userId:        "17",
+			sum:           5000,
+			beginError:    errors.Errorf("lol kek cheburek."),
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:   "Error in Commit",
+			userId: "17",
 			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "17", float32(0), "").Return(&model.User{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewCredit("17", 5000, model.TransactionInfo{})).Return(result, nil)
+				s.EXPECT().Commit().Return(errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			uow := mock_repository.NewMockUnitOfWork(c)
+			switch {
+			case testCase.beginError != nil:
+				uow.EXPECT().Begin(gomock.Any()).Return(nil, testCase.beginError)
+			case testCase.mockTxBehavior != nil:
+				// изменения коммитятся, только если операция прошла, rollback после commit ничего не откатывает
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
+
+			services := NewUserService(&repository.Repository{UnitOfWork: uow}, model.Limits{}, testCase.allowFrozenCredits, !testCase.noImplicitCreation)
+
+			// test
+			result, err := services.AddFunds(context.Background(), testCase.userId, testCase.sum, testCase.info)
+
+			// assert
+			assert.Equal(t, testCase.expectedResult, result)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestUserService_CreateUser(t *testing.T) {
+	testData := []struct {
+		name                   string
+		userId                 string
+		externalRef            string
+		mockRepositoryBehavior mockRepositoryBehavior
+		expectedUser           *model.User
+		expectedError          error
+	}{
+		{
+			name:        "OK",
+			userId:      "17",
+			externalRef: "crm-17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().CreateUser(gomock.Any(), "17", float32(0), "crm-17").Return(&model.User{Id: 1, UserId: "17", ExternalRef: "crm-17"}, nil)
+			},
+			expectedUser:  &model.User{Id: 1, UserId: "17", ExternalRef: "crm-17"},
+			expectedError: nil,
+		},
+		{
+			name:                   "Wrong Id",
+			userId:                 "",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
+			expectedError:          &WrongParam{Param: "id"},
+		},
+		{
+			name:                   "Wrong External Ref",
+			userId:                 "17",
+			externalRef:            "crm 17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
+			expectedError:          &WrongParam{Param: "external_ref"},
+		},
+		{
+			name:   "Already Exists",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().CreateUser(gomock.Any(), "17", float32(0), "").Return(nil, errors.Wrap(repository.ErrUserAlreadyExists, "lol kek cheburek."))
+			},
+			expectedError: &UserAlreadyExists{Id: "17"},
+		},
+		{
+			name:   "Error in CreateUser",
+			userId: "17",
 			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().UpdateBalance(17, float32(5000)).Return(nil, errors.Errorf("lol kek cheburek."))
+				s.EXPECT().CreateUser(gomock.Any(), "17", float32(0), "").Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
@@ -88,91 +301,222 @@ func TestUserService_AddFunds(t *testing.T) {
 			repo := mock_repository.NewMockUser(c)
 			testCase.mockRepositoryBehavior(repo)
 
-			services := NewUserService(&repository.Repository{User: repo})
+			services := NewUserService(&repository.Repository{User: repo}, model.Limits{}, true, true)
 
 			// test
-			err := services.AddFunds(testCase.userId, testCase.sum)
+			user, err := services.CreateUser(context.Background(), testCase.userId, testCase.externalRef)
 
 			// assert
+			assert.Equal(t, testCase.expectedUser, user)
 			assert.Equal(t, testCase.expectedError, err)
 		})
 	}
 }
 
-func TestUserService_WriteOffFunds(t *testing.T) {
+func TestUserService_GetUser(t *testing.T) {
 	testData := []struct {
 		name                   string
-		userId                 int
-		sum                    float32
 		mockRepositoryBehavior mockRepositoryBehavior
+		expectedUser           *model.User
 		expectedError          error
 	}{
 		{
-			name:   "OK",
-			userId: 17,
-			sum:    5000,
+			name: "OK",
 			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 20000}, nil)
-				s.EXPECT().UpdateBalance(17, float32(-5000)).Return(&model.User{}, nil)
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 1, UserId: "17", Balance: 300}, nil)
 			},
+			expectedUser:  &model.User{Id: 1, UserId: "17", Balance: 300},
 			expectedError: nil,
 		},
+		{
+			name: "User Not Exist",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+			},
+			expectedError: &UserNotFound{Id: "17"},
+		},
+		{
+			name: "Error in GetUser",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockUser(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			services := NewUserService(&repository.Repository{User: repo}, model.Limits{}, true, true)
+
+			// test
+			user, err := services.GetUser(context.Background(), "17")
+
+			// assert
+			assert.Equal(t, testCase.expectedUser, user)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestUserService_WriteOffFunds(t *testing.T) {
+	result := &model.OperationResult{Transaction: &model.Transaction{Id: 5}}
+
+	testData := []struct {
+		name           string
+		userId         string
+		sum            float32
+		mockTxBehavior mockTxBehavior
+		expectedResult *model.OperationResult
+		expectedError  error
+	}{
+		{
+			name:   "OK",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 20000}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewWriteOff("17", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
 		{
 			name:   "User Not Exist",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
 			},
-			expectedError: &UserNotFound{Id: 17},
+			expectedError: &UserNotFound{Id: "17"},
 		},
 		{
-			name:                   "Incorrect Sum",
-			userId:                 17,
-			sum:                    -900,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
-			expectedError:          &NegativeSum{},
+			name:          "Incorrect Sum",
+			userId:        "17",
+			sum:           -900,
+			expectedError: &NegativeSum{},
 		},
 		{
 			name:   "Error in IsUserExist",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:   "Error in GetUser",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(nil, errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:   "Error in UpdateBalance",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 20000}, nil)
-				s.EXPECT().UpdateBalance(17, float32(-5000)).Return(nil, errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 20000}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewWriteOff("17", 5000, model.TransactionInfo{})).Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:   "Insufficient sum",
-			userId: 17,
+			userId: "17",
 			sum:    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 300}, nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 300}, nil)
+			},
+			expectedError: &InsufficientFunds{Id: "17"},
+		},
+		{
+			name:   "Limit Exceeded",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 20000}, nil)
+				maxSum := float32(1000)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{MaxTransactionSum: &maxSum}, nil)
+			},
+			expectedError: &LimitExceeded{Id: "17", Limit: "max_transaction_sum"},
+		},
+		{
+			name:   "Frozen",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 20000, Status: model.UserStatusFrozen}, nil)
 			},
-			expectedError: &InsufficientFunds{Id: 17},
+			expectedError: &UserFrozen{Id: "17"},
+		},
+		{
+			name:   "Closed",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusClosed}, nil)
+			},
+			expectedError: &UserClosed{Id: "17"},
+		},
+		{
+			name:   "OK Into Credit",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 300, CreditLimit: 10000}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewWriteOff("17", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:   "Insufficient sum With Credit",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 300, CreditLimit: 1000}, nil)
+			},
+			expectedError: &InsufficientFunds{Id: "17"},
+		},
+		{
+			name:   "Insufficient sum in UpdateBalance",
+			userId: "17",
+			sum:    5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 20000}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewWriteOff("17", 5000, model.TransactionInfo{})).
+					Return(nil, errors.Wrap(repository.ErrInsufficientFunds, "lol kek cheburek."))
+			},
+			expectedError: &InsufficientFunds{Id: "17"},
 		},
 	}
 
@@ -183,166 +527,371 @@ func TestUserService_WriteOffFunds(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 
-			repo := mock_repository.NewMockUser(c)
-			testCase.mockRepositoryBehavior(repo)
+			uow := mock_repository.NewMockUnitOfWork(c)
+			if testCase.mockTxBehavior != nil {
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
 
-			services := NewUserService(&repository.Repository{User: repo})
+			services := NewUserService(&repository.Repository{UnitOfWork: uow}, model.Limits{}, true, true)
 
 			// test
-			err := services.WriteOffFunds(testCase.userId, testCase.sum)
+			result, err := services.WriteOffFunds(context.Background(), testCase.userId, testCase.sum, model.TransactionInfo{})
 
 			// assert
+			assert.Equal(t, testCase.expectedResult, result)
 			assert.Equal(t, testCase.expectedError, err)
 		})
 	}
 }
 
 func TestUserService_FundsTransfer(t *testing.T) {
+	result := &model.OperationResult{Transaction: &model.Transaction{Id: 5}}
+
 	testData := []struct {
-		name                   string
-		senderId               int
-		receiverId             int
-		sum                    float32
-		mockRepositoryBehavior mockRepositoryBehavior
-		expectedError          error
+		name               string
+		senderId           string
+		receiverId         string
+		sum                float32
+		allowFrozenCredits bool
+		noImplicitCreation bool
+		beginError         error
+		mockTxBehavior     mockTxBehavior
+		retryTxBehavior    mockTxBehavior // вторая попытка, если первая упала с ошибкой сериализации или дедлоком
+		expectedResult     *model.OperationResult
+		expectedError      error
 	}{
 		{
 			name:       "OK 1",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 30000}, nil)
-				s.EXPECT().IsUserExist(18).Return(true, nil)
-				s.EXPECT().CreateFundsTransaction(17, 18, float32(5000)).Return(nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
 			},
-			expectedError: nil,
+			expectedResult: result,
+			expectedError:  nil,
 		},
 		{
 			name:       "OK 2",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 30000}, nil)
-				s.EXPECT().IsUserExist(18).Return(false, nil)
-				s.EXPECT().CreateUser(18, float32(0)).Return(nil)
-				s.EXPECT().CreateFundsTransaction(17, 18, float32(5000)).Return(nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "18", float32(0), "").Return(&model.User{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
 			},
-			expectedError: nil,
+			expectedResult: result,
+			expectedError:  nil,
 		},
 		{
-			name:                   "Same User",
-			senderId:               17,
-			receiverId:             17,
-			sum:                    5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
-			expectedError:          &SameId{},
+			name:          "Same User",
+			senderId:      "17",
+			receiverId:    "17",
+			sum:           5000,
+			expectedError: &SameId{},
 		},
 		{
-			name:                   "Incorrect Sum",
-			senderId:               17,
-			receiverId:             18,
-			sum:                    -900,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
-			expectedError:          &NegativeSum{},
+			name:          "Wrong Receiver Id",
+			senderId:      "17",
+			receiverId:    strings.Repeat("a", 65),
+			sum:           5000,
+			expectedError: &WrongParam{Param: "receiver_id"},
 		},
 		{
-			name:       "Error in IsUserExist 1",
-			senderId:   17,
-			receiverId: 18,
-			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, errors.Errorf("lol kek cheburek."))
-			},
-			expectedError: &InternalServerError{},
+			name:          "Incorrect Sum",
+			senderId:      "17",
+			receiverId:    "18",
+			sum:           -900,
+			expectedError: &NegativeSum{},
 		},
 		{
 			name:       "Sender User Not Exist",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{}, nil)
 			},
-			expectedError: &UserNotFound{Id: 17},
+			expectedError: &UserNotFound{Id: "17"},
 		},
 		{
-			name:       "Error in GetUser 1",
-			senderId:   17,
-			receiverId: 18,
+			name:       "Error in GetUsersForUpdate",
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(nil, errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:       "Sender Doesnt Have Enough Money",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 300}, nil)
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 300},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+			},
+			expectedError: &InsufficientFunds{Id: "17"},
+		},
+		{
+			name:               "Receiver Not Exist Without Implicit Creation",
+			senderId:           "17",
+			receiverId:         "18",
+			sum:                5000,
+			noImplicitCreation: true,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
 			},
-			expectedError: &InsufficientFunds{Id: 17},
+			expectedError: &UserNotFound{Id: "18"},
 		},
 		{
-			name:       "Error in IsUserExist 2",
-			senderId:   17,
-			receiverId: 18,
+			name:       "Sender Frozen",
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 30000}, nil)
-				s.EXPECT().IsUserExist(18).Return(false, errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000, Status: model.UserStatusFrozen},
+				}, nil)
 			},
-			expectedError: &InternalServerError{},
+			expectedError: &UserFrozen{Id: "17"},
+		},
+		{
+			name:               "Receiver Frozen",
+			senderId:           "17",
+			receiverId:         "18",
+			sum:                5000,
+			allowFrozenCredits: false,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusFrozen},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+			},
+			expectedError: &UserFrozen{Id: "18"},
+		},
+		{
+			name:               "OK Receiver Frozen",
+			senderId:           "17",
+			receiverId:         "18",
+			sum:                5000,
+			allowFrozenCredits: true,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusFrozen},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:               "Receiver Closed",
+			senderId:           "17",
+			receiverId:         "18",
+			sum:                5000,
+			allowFrozenCredits: true,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusClosed},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+			},
+			expectedError: &UserClosed{Id: "18"},
 		},
 		{
 			name:       "Error in CreateUser",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 30000}, nil)
-				s.EXPECT().IsUserExist(18).Return(false, nil)
-				s.EXPECT().CreateUser(18, float32(0)).Return(errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "18", float32(0), "").Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
 		{
 			name:       "Error in CreateFundsTransaction 1",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 30000}, nil)
-				s.EXPECT().IsUserExist(18).Return(false, nil)
-				s.EXPECT().CreateUser(18, float32(0)).Return(nil)
-				s.EXPECT().CreateFundsTransaction(17, 18, float32(5000)).Return(errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "18", float32(0), "").Return(&model.User{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
+		{
+			name:       "OK Into Credit",
+			senderId:   "17",
+			receiverId: "18",
+			sum:        5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 0, CreditLimit: 5000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:       "Insufficient Funds in CreateFundsTransaction",
+			senderId:   "17",
+			receiverId: "18",
+			sum:        5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).
+					Return(nil, errors.Wrap(repository.ErrInsufficientFunds, "lol kek cheburek."))
+			},
+			expectedError: &InsufficientFunds{Id: "17"},
+		},
 		{
 			name:       "Error in CreateFundsTransaction 2",
-			senderId:   17,
-			receiverId: 18,
+			senderId:   "17",
+			receiverId: "18",
 			sum:        5000,
-			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 30000}, nil)
-				s.EXPECT().IsUserExist(18).Return(true, nil)
-				s.EXPECT().CreateFundsTransaction(17, 18, float32(5000)).Return(errors.Errorf("lol kek cheburek."))
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(nil, errors.Errorf("lol kek cheburek."))
 			},
 			expectedError: &InternalServerError{},
 		},
+		{
+			name:          "Error in Begin",
+			senderId:      "17",
+			receiverId:    "18",
+			sum:           5000,
+			beginError:    errors.Errorf("lol kek cheburek."),
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:       "Error in Commit",
+			senderId:   "17",
+			receiverId: "18",
+			sum:        5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().CreateUser(gomock.Any(), "18", float32(0), "").Return(&model.User{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+				s.EXPECT().Commit().Return(errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:       "Retries Exhausted in CreateFundsTransaction",
+			senderId:   "17",
+			receiverId: "18",
+			sum:        5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).
+					Return(nil, errors.Wrap(repository.ErrRetriesExhausted, "lol kek cheburek."))
+			},
+			expectedError: &ConcurrentUpdate{},
+		},
+		{
+			// перевод повторяется целиком в новой транзакции, проверки идут заново
+			name:       "OK After Deadlock in Commit",
+			senderId:   "17",
+			receiverId: "18",
+			sum:        5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+				s.EXPECT().Commit().Return(&pq.Error{Code: "40P01"})
+			},
+			retryTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 25000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+			},
+			expectedResult: result,
+			expectedError:  nil,
+		},
+		{
+			name:       "Retries Exhausted in Commit",
+			senderId:   "17",
+			receiverId: "18",
+			sum:        5000,
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+				s.EXPECT().Commit().Return(&pq.Error{Code: "40001"})
+			},
+			retryTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().GetUsersForUpdate(gomock.Any(), "17", "18").Return(map[string]*model.User{
+					"17": {Id: 17, UserId: "17", Balance: 30000},
+					"18": {Id: 18, UserId: "18", Status: model.UserStatusActive},
+				}, nil)
+				s.EXPECT().GetLimits(gomock.Any(), "17").Return(&model.Limits{}, nil)
+				s.EXPECT().Post(gomock.Any(), model.NewTransfer("17", "18", 5000, model.TransactionInfo{})).Return(result, nil)
+				s.EXPECT().Commit().Return(&pq.Error{Code: "40001"})
+			},
+			expectedError: &ConcurrentUpdate{},
+		},
 	}
 
 	t.Parallel()
@@ -352,15 +901,35 @@ func TestUserService_FundsTransfer(t *testing.T) {
 			c := gomock.NewController(t)
 			defer c.Finish()
 
-			repo := mock_repository.NewMockUser(c)
-			testCase.mockRepositoryBehavior(repo)
+			uow := mock_repository.NewMockUnitOfWork(c)
+			switch {
+			case testCase.beginError != nil:
+				uow.EXPECT().Begin(gomock.Any()).Return(nil, testCase.beginError)
+			case testCase.mockTxBehavior != nil:
+				// изменения коммитятся, только если операция прошла, rollback после commit ничего не откатывает
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.retryTxBehavior != nil {
+					tx.EXPECT().Rollback().Return(nil)
+					tx = mock_repository.NewMockTx(c)
+					uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+					testCase.retryTxBehavior(tx)
+				}
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
 
-			services := NewUserService(&repository.Repository{User: repo})
+			retry := repository.RetryConfig{MaxAttempts: 2}
+			services := NewUserService(&repository.Repository{UnitOfWork: uow, Retry: retry}, model.Limits{}, testCase.allowFrozenCredits, !testCase.noImplicitCreation)
 
 			// test
-			err := services.FundsTransfer(testCase.senderId, testCase.receiverId, testCase.sum)
+			result, err := services.FundsTransfer(context.Background(), testCase.senderId, testCase.receiverId, testCase.sum, model.TransactionInfo{})
 
 			// assert
+			assert.Equal(t, testCase.expectedResult, result)
 			assert.Equal(t, testCase.expectedError, err)
 		})
 	}
@@ -369,47 +938,47 @@ func TestUserService_FundsTransfer(t *testing.T) {
 func TestUserService_GetBalance(t *testing.T) {
 	testData := []struct {
 		name                   string
-		userId                 int
+		userId                 string
 		mockRepositoryBehavior mockRepositoryBehavior
-		expectedBalance        float32
+		expectedBalance        *model.Balance
 		expectedError          error
 	}{
 		{
 			name:   "OK",
-			userId: 17,
+			userId: "17",
 			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(&model.User{Id: 17, UserId: 17, Balance: 10000}, nil)
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 10000, CreditLimit: 500}, nil)
 			},
-			expectedBalance: 10000,
+			expectedBalance: &model.Balance{Balance: 10000, CreditLimit: 500, Available: 10500},
 			expectedError:   nil,
 		},
 		{
 			name:   "Error in IsUserExist",
-			userId: 17,
+			userId: "17",
 			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, errors.Errorf("lol kek cheburek."))
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, errors.Errorf("lol kek cheburek."))
 			},
-			expectedBalance: 0,
+			expectedBalance: nil,
 			expectedError:   &InternalServerError{},
 		},
 		{
 			name:   "User Not Found",
-			userId: 17,
+			userId: "17",
 			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(false, nil)
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
 			},
-			expectedBalance: 0,
-			expectedError:   &UserNotFound{Id: 17},
+			expectedBalance: nil,
+			expectedError:   &UserNotFound{Id: "17"},
 		},
 		{
 			name:   "Error in GetUser",
-			userId: 17,
+			userId: "17",
 			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
-				s.EXPECT().IsUserExist(17).Return(true, nil)
-				s.EXPECT().GetUser(17).Return(nil, errors.Errorf("lol kek cheburek."))
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUser(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
 			},
-			expectedBalance: 0,
+			expectedBalance: nil,
 			expectedError:   &InternalServerError{},
 		},
 	}
@@ -424,10 +993,10 @@ func TestUserService_GetBalance(t *testing.T) {
 			repo := mock_repository.NewMockUser(c)
 			testCase.mockRepositoryBehavior(repo)
 
-			services := NewUserService(&repository.Repository{User: repo})
+			services := NewUserService(&repository.Repository{User: repo}, model.Limits{}, true, true)
 
 			// test
-			balance, err := services.GetBalance(testCase.userId)
+			balance, err := services.GetBalance(context.Background(), testCase.userId)
 
 			// assert
 			assert.Equal(t, testCase.expectedBalance, balance)
@@ -435,3 +1004,486 @@ func TestUserService_GetBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_SetCreditLimit(t *testing.T) {
+	creditLimit, negativeLimit := float32(10000), float32(-1)
+
+	testData := []struct {
+		name                   string
+		userId                 string
+		creditLimit            *float32
+		mockRepositoryBehavior mockRepositoryBehavior
+		mockTxBehavior         mockTxBehavior
+		expectedError          error
+	}{
+		{
+			name:        "OK",
+			userId:      "17",
+			creditLimit: &creditLimit,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().SetCreditLimit(gomock.Any(), "17", &creditLimit).Return(&model.User{}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:        "OK Reset",
+			userId:      "17",
+			creditLimit: nil,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().SetCreditLimit(gomock.Any(), "17", nil).Return(&model.User{}, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:                   "Negative Limit",
+			userId:                 "17",
+			creditLimit:            &negativeLimit,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
+			expectedError:          &WrongParam{Param: "credit_limit"},
+		},
+		{
+			name:        "User Not Found",
+			userId:      "17",
+			creditLimit: &creditLimit,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+			},
+			expectedError: &UserNotFound{Id: "17"},
+		},
+		{
+			name:        "Error in SetCreditLimit",
+			userId:      "17",
+			creditLimit: &creditLimit,
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().SetCreditLimit(gomock.Any(), "17", &creditLimit).Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockUser(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			uow := mock_repository.NewMockUnitOfWork(c)
+			if testCase.mockTxBehavior != nil {
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
+
+			services := NewUserService(&repository.Repository{User: repo, UnitOfWork: uow}, model.Limits{}, true, true)
+
+			// test
+			err := services.SetCreditLimit(context.Background(), testCase.userId, testCase.creditLimit)
+
+			// assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestUserService_SetLimits(t *testing.T) {
+	dailyDebit, negativeSum, negativeCount := float32(1000), float32(-1), -1
+
+	testData := []struct {
+		name                   string
+		userId                 string
+		limits                 model.Limits
+		mockRepositoryBehavior mockRepositoryBehavior
+		mockTxBehavior         mockTxBehavior
+		expectedError          error
+	}{
+		{
+			name:   "OK",
+			userId: "17",
+			limits: model.Limits{DailyDebit: &dailyDebit},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().SetLimits(gomock.Any(), "17", model.Limits{DailyDebit: &dailyDebit}).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:                   "Negative Sum Limit",
+			userId:                 "17",
+			limits:                 model.Limits{MonthlyTransfer: &negativeSum},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
+			expectedError:          &WrongParam{Param: "monthly_transfer"},
+		},
+		{
+			name:                   "Negative Count Limit",
+			userId:                 "17",
+			limits:                 model.Limits{HourlyTransfers: &negativeCount},
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {},
+			expectedError:          &WrongParam{Param: "hourly_transfers"},
+		},
+		{
+			name:   "User Not Found",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+			},
+			expectedError: &UserNotFound{Id: "17"},
+		},
+		{
+			name:   "Error in SetLimits",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+			},
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().SetLimits(gomock.Any(), "17", model.Limits{}).Return(errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockUser(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			uow := mock_repository.NewMockUnitOfWork(c)
+			if testCase.mockTxBehavior != nil {
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
+
+			services := NewUserService(&repository.Repository{User: repo, UnitOfWork: uow}, model.Limits{}, true, true)
+
+			// test
+			err := services.SetLimits(context.Background(), testCase.userId, testCase.limits)
+
+			// assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestUserService_SetStatus(t *testing.T) {
+	testData := []struct {
+		name           string
+		status         string
+		reason         string
+		mockTxBehavior mockTxBehavior
+		expectedError  error
+	}{
+		{
+			name:   "OK Freeze",
+			status: model.UserStatusFrozen,
+			reason: "compromised",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 300, Status: model.UserStatusActive}, nil)
+				s.EXPECT().SetStatus(gomock.Any(), "17", model.UserStatusFrozen, "compromised").Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:   "OK Unfreeze",
+			status: model.UserStatusActive,
+			reason: "проверка пройдена",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: 300, Status: model.UserStatusFrozen}, nil)
+				s.EXPECT().SetStatus(gomock.Any(), "17", model.UserStatusActive, "проверка пройдена").Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:   "OK Close",
+			status: model.UserStatusClosed,
+			reason: "by user request",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusFrozen}, nil)
+				s.EXPECT().SetStatus(gomock.Any(), "17", model.UserStatusClosed, "by user request").Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:          "Wrong Status",
+			status:        "deleted",
+			reason:        "compromised",
+			expectedError: &WrongParam{Param: "status"},
+		},
+		{
+			name:          "Empty Reason",
+			status:        model.UserStatusFrozen,
+			expectedError: &WrongParam{Param: "reason"},
+		},
+		{
+			name:   "User Not Found",
+			status: model.UserStatusFrozen,
+			reason: "compromised",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+			},
+			expectedError: &UserNotFound{Id: "17"},
+		},
+		{
+			name:   "Already Frozen",
+			status: model.UserStatusFrozen,
+			reason: "compromised",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusFrozen}, nil)
+			},
+			expectedError: &StatusNotChanged{Id: "17", Status: model.UserStatusFrozen},
+		},
+		{
+			name:   "Closed",
+			status: model.UserStatusActive,
+			reason: "mistake",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusClosed}, nil)
+			},
+			expectedError: &UserClosed{Id: "17"},
+		},
+		{
+			name:   "Close With Balance",
+			status: model.UserStatusClosed,
+			reason: "by user request",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Balance: -50, Status: model.UserStatusActive}, nil)
+			},
+			expectedError: &NonZeroBalance{Id: "17"},
+		},
+		{
+			// начисление прошло мимо блокировки строки
+			name:   "Close With Balance in SetStatus",
+			status: model.UserStatusClosed,
+			reason: "by user request",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusActive}, nil)
+				s.EXPECT().SetStatus(gomock.Any(), "17", model.UserStatusClosed, "by user request").
+					Return(errors.Wrap(repository.ErrNonZeroBalance, "lol kek cheburek."))
+			},
+			expectedError: &NonZeroBalance{Id: "17"},
+		},
+		{
+			// юзера закрыли мимо блокировки строки, переоткрыть его нельзя
+			name:   "Closed in SetStatus",
+			status: model.UserStatusActive,
+			reason: "mistake",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusFrozen}, nil)
+				s.EXPECT().SetStatus(gomock.Any(), "17", model.UserStatusActive, "mistake").
+					Return(errors.Wrap(repository.ErrUserClosed, "lol kek cheburek."))
+			},
+			expectedError: &UserClosed{Id: "17"},
+		},
+		{
+			name:   "Error in GetUserForUpdate",
+			status: model.UserStatusFrozen,
+			reason: "compromised",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:   "Error in SetStatus",
+			status: model.UserStatusFrozen,
+			reason: "compromised",
+			mockTxBehavior: func(s *mock_repository.MockTx) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetUserForUpdate(gomock.Any(), "17").Return(&model.User{Id: 17, UserId: "17", Status: model.UserStatusActive}, nil)
+				s.EXPECT().SetStatus(gomock.Any(), "17", model.UserStatusFrozen, "compromised").Return(errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			uow := mock_repository.NewMockUnitOfWork(c)
+			if testCase.mockTxBehavior != nil {
+				tx := mock_repository.NewMockTx(c)
+				uow.EXPECT().Begin(gomock.Any()).Return(tx, nil)
+				testCase.mockTxBehavior(tx)
+				if testCase.expectedError == nil {
+					tx.EXPECT().Commit().Return(nil)
+				}
+				tx.EXPECT().Rollback().Return(nil)
+			}
+
+			services := NewUserService(&repository.Repository{UnitOfWork: uow}, model.Limits{}, true, true)
+
+			// test
+			err := services.SetStatus(context.Background(), "17", testCase.status, testCase.reason)
+
+			// assert
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func TestUserService_GetHistory(t *testing.T) {
+	testData := []struct {
+		name                   string
+		userId                 string
+		mockRepositoryBehavior mockRepositoryBehavior
+		expectedTransactions   []model.Transaction
+		expectedError          error
+	}{
+		{
+			name:   "OK",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetTransactions(gomock.Any(), "17").Return([]model.Transaction{{Id: 1, Type: model.TransactionAddFunds, Sum: 100}}, nil)
+			},
+			expectedTransactions: []model.Transaction{{Id: 1, Type: model.TransactionAddFunds, Sum: 100}},
+			expectedError:        nil,
+		},
+		{
+			name:   "User Not Found",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, nil)
+			},
+			expectedError: &UserNotFound{Id: "17"},
+		},
+		{
+			name:   "Error in IsUserExist",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(false, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+		{
+			name:   "Error in GetTransactions",
+			userId: "17",
+			mockRepositoryBehavior: func(s *mock_repository.MockUser) {
+				s.EXPECT().IsUserExist(gomock.Any(), "17").Return(true, nil)
+				s.EXPECT().GetTransactions(gomock.Any(), "17").Return(nil, errors.Errorf("lol kek cheburek."))
+			},
+			expectedError: &InternalServerError{},
+		},
+	}
+
+	t.Parallel()
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			// init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockUser(c)
+			testCase.mockRepositoryBehavior(repo)
+
+			services := NewUserService(&repository.Repository{User: repo}, model.Limits{}, true, true)
+
+			// test
+			transactions, err := services.GetHistory(context.Background(), testCase.userId)
+
+			// assert
+			assert.Equal(t, testCase.expectedTransactions, transactions)
+			assert.Equal(t, testCase.expectedError, err)
+		})
+	}
+}
+
+func Test_validateTransactionInfo(t *testing.T) {
+	testData := []struct {
+		name          string
+		info          model.TransactionInfo
+		expectedError error
+	}{
+		{
+			name:          "OK Empty",
+			info:          model.TransactionInfo{},
+			expectedError: nil,
+		},
+		{
+			name:          "OK",
+			info:          model.TransactionInfo{OrderId: "A-17_b.3:x", ServiceId: "42", Comment: "Оплата заказа №17", Source: "mobile"},
+			expectedError: nil,
+		},
+		{
+			name:          "Long Order Id",
+			info:          model.TransactionInfo{OrderId: strings.Repeat("a", maxTransactionIdLength+1)},
+			expectedError: &WrongParam{Param: "order_id"},
+		},
+		{
+			name:          "Wrong Service Id Charset",
+			info:          model.TransactionInfo{ServiceId: "услуга"},
+			expectedError: &WrongParam{Param: "service_id"},
+		},
+		{
+			name:          "Wrong Source Charset",
+			info:          model.TransactionInfo{Source: "web app"},
+			expectedError: &WrongParam{Param: "source"},
+		},
+		{
+			name:          "OK Long Unicode Comment",
+			info:          model.TransactionInfo{Comment: strings.Repeat("я", maxTransactionCommentLength)},
+			expectedError: nil,
+		},
+		{
+			name:          "Long Comment",
+			info:          model.TransactionInfo{Comment: strings.Repeat("я", maxTransactionCommentLength+1)},
+			expectedError: &WrongParam{Param: "comment"},
+		},
+		{
+			name:          "Control Characters In Comment",
+			info:          model.TransactionInfo{Comment: "line\nbreak"},
+			expectedError: &WrongParam{Param: "comment"},
+		},
+		{
+			name:          "Invalid UTF-8 Comment",
+			info:          model.TransactionInfo{Comment: "\xff"},
+			expectedError: &WrongParam{Param: "comment"},
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expectedError, validateTransactionInfo(testCase.info))
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"for_avito_tech_with_gin/pkg/logging"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	CertFile       string        `mapstructure:"cert_file"`
+	KeyFile        string        `mapstructure:"key_file"`
+	MinVersion     string        `mapstructure:"min_version"`     // 1.2 или 1.3
+	ClientCAFile   string        `mapstructure:"client_ca_file"`  // CA клиентских сертификатов, если задан - клиенты без сертификата не принимаются (mTLS)
+	ReloadInterval time.Duration `mapstructure:"reload_interval"` // как часто проверять, не обновились ли файлы, 0 - только по Reload
+}
+
+var versions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// ParseVersion "1.2" или "1.3"
+func ParseVersion(version string) (uint16, error) {
+	v, ok := versions[version]
+	if !ok {
+		return 0, errors.Errorf("unknown tls version %q", version)
+	}
+	return v, nil
+}
+
+// Reloader держит сертификат сервера и CA клиентов и перечитывает их, когда меняются файлы, поэтому сертификаты
+// можно обновлять без перезапуска. Новые файлы применяются к новым соединениям
+type Reloader struct {
+	config     Config
+	minVersion uint16
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+func NewReloader(c Config) (*Reloader, error) {
+	minVersion, err := ParseVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Reloader{config: c, minVersion: minVersion}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig конфиг для http.Server, сертификаты берутся текущие на момент рукопожатия
+func (r *Reloader) TLSConfig() *tls.Config {
+	// GetCertificate нужен http.Server, чтобы не требовать файлы сертификата, рукопожатие идет с конфигом из configForClient
+	return &tls.Config{MinVersion: r.minVersion, GetCertificate: r.getCertificate, GetConfigForClient: r.configForClient}
+}
+
+func (r *Reloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *Reloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c := &tls.Config{MinVersion: r.minVersion, Certificates: []tls.Certificate{*r.cert}}
+	if r.clientCAs != nil {
+		c.ClientCAs = r.clientCAs
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// Reload перечитывает файлы. Если они не читаются или сертификат не подходит к ключу, остаются прежние
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return errors.Wrap(err, "error loading tls certificate")
+	}
+
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "error reading client ca")
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.Errorf("no certificates in client ca %s", r.config.ClientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.clientCAs, r.modTimes = &cert, clientCAs, modTimes
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Run проверяет файлы раз в ReloadInterval и перечитывает их, если они изменились. Блокируется, пока не отменен ctx
+func (r *Reloader) Run(ctx context.Context) error {
+	if r.config.ReloadInterval <= 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	log := logrus.WithField(logging.PackageField, "tls")
+	ticker := time.NewTicker(r.config.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.Error(errors.Wrap(err, "certificates are not reloaded"))
+				continue
+			}
+			log.Info("certificates reloaded")
+		}
+	}
+}
+
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		// файл могут заменять прямо сейчас, попробуем в следующий раз
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for file, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading %s", file)
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
@@ -0,0 +1,193 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert сертификат common name, подписанный parent, nil - самоподписанный CA
+func newTestCert(t *testing.T, commonName string, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeFile(t *testing.T, path string, content []byte) {
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	ca := newTestCert(t, "ca", 1, nil)
+	server := newTestCert(t, "server", 2, ca)
+	client := newTestCert(t, "billing", 3, ca)
+	otherCA := newTestCert(t, "other ca", 4, nil)
+	stranger := newTestCert(t, "stranger", 5, otherCA)
+
+	dir := t.TempDir()
+	c := Config{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		MinVersion:   "1.2",
+	}
+	writeFile(t, c.CertFile, server.certPEM)
+	writeFile(t, c.KeyFile, server.keyPEM)
+	writeFile(t, c.ClientCAFile, ca.certPEM)
+
+	reloader, err := NewReloader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// test server
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+	}))
+	srv.TLS = reloader.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(clientCert *testCert) (*http.Response, error) {
+		clientTLS := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if clientCert != nil {
+			clientTLS.Certificates = []tls.Certificate{clientCert.tlsCertificate(t)}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		return client.Get(srv.URL)
+	}
+
+	// assert
+	resp, err := get(client)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, big.NewInt(2), resp.TLS.PeerCertificates[0].SerialNumber)
+		resp.Body.Close()
+	}
+
+	_, err = get(nil)
+	assert.Error(t, err, "client without certificate")
+	_, err = get(stranger)
+	assert.Error(t, err, "client with certificate of unknown ca")
+
+	// сертификат сервера обновился, и клиенты другого CA теперь тоже свои
+	renewed := newTestCert(t, "server", 6, ca)
+	writeFile(t, c.CertFile, renewed.certPEM)
+	writeFile(t, c.KeyFile, renewed.keyPEM)
+	writeFile(t, c.ClientCAFile, append(ca.certPEM, otherCA.certPEM...))
+	assert.NoError(t, reloader.Reload())
+
+	resp, err = get(stranger)
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(6), resp.TLS.PeerCertificates[0].SerialNumber)
+		resp.Body.Close()
+	}
+
+	// битый ключ не ломает сервер, остается прежний сертификат
+	writeFile(t, c.KeyFile, []byte("lol kek cheburek."))
+	assert.Error(t, reloader.Reload())
+	resp, err = get(client)
+	if assert.NoError(t, err) {
+		assert.Equal(t, big.NewInt(6), resp.TLS.PeerCertificates[0].SerialNumber)
+		resp.Body.Close()
+	}
+}
+
+func TestReloader_changed(t *testing.T) {
+	ca := newTestCert(t, "ca", 1, nil)
+	dir := t.TempDir()
+	c := Config{CertFile: filepath.Join(dir, "ca.crt"), KeyFile: filepath.Join(dir, "ca.key"), MinVersion: "1.3"}
+	writeFile(t, c.CertFile, ca.certPEM)
+	writeFile(t, c.KeyFile, ca.keyPEM)
+
+	reloader, err := NewReloader(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, reloader.changed())
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(c.CertFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert
+	assert.True(t, reloader.changed())
+}
+
+func TestNewReloader_errors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewReloader(Config{MinVersion: "1.1"})
+	assert.EqualError(t, err, `unknown tls version "1.1"`)
+
+	_, err = NewReloader(Config{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key"), MinVersion: "1.2"})
+	assert.Error(t, err)
+}
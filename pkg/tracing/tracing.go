@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ServiceName имя сервиса в трейсах по умолчанию
+	ServiceName = "balance-service"
+
+	instrumentationName = "for_avito_tech_with_gin"
+
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	Exporter    string  `mapstructure:"exporter"`     // otlp, stdout или пусто - спаны не экспортируются
+	Endpoint    string  `mapstructure:"endpoint"`     // host:port OTLP коллектора (http)
+	Insecure    bool    `mapstructure:"insecure"`     // без TLS до коллектора
+	ServiceName string  `mapstructure:"service_name"` // имя сервиса в трейсах
+	SampleRatio float64 `mapstructure:"sample_ratio"` // доля запросов, которые попадают в трейсы, от 0 до 1
+}
+
+// Init настраивает глобальный TracerProvider и W3C trace context, возвращает функцию, которая дописывает оставшиеся спаны
+// при остановке сервиса. Trace context пробрасывается и когда экспорт выключен, чтобы не рвать трейсы соседних сервисов
+func Init(c Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+		if c.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	default:
+		return nil, errors.Errorf("unknown tracing exporter %q", c.Exporter)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "filed to create %s tracing exporter", c.Exporter)
+	}
+
+	serviceName := c.ServiceName
+	if serviceName == "" {
+		serviceName = ServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer трейсер сервиса, берется из глобального провайдера, поэтому до Init (и в тестах) спаны никуда не пишутся
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start начинает спан name, дочерний к спану из ctx
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
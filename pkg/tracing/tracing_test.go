@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"testing"
+)
+
+func TestInit(t *testing.T) {
+	testData := []struct {
+		name      string
+		config    Config
+		wantError bool
+	}{
+		{
+			name:   "OK Disabled",
+			config: Config{},
+		},
+		{
+			name:   "OK Stdout",
+			config: Config{Exporter: ExporterStdout, SampleRatio: 1},
+		},
+		{
+			name:   "OK OTLP",
+			config: Config{Exporter: ExporterOTLP, Endpoint: "localhost:4318", Insecure: true, SampleRatio: 0.5},
+		},
+		{
+			name:      "Unknown Exporter",
+			config:    Config{Exporter: "jaeger"},
+			wantError: true,
+		},
+	}
+
+	for _, testCase := range testData {
+		t.Run(testCase.name, func(t *testing.T) {
+			shutdown, err := Init(testCase.config)
+
+			// assert
+			if testCase.wantError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+
+			// W3C trace context пробрасывается всегда, даже если экспорт выключен
+			carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+			ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+			injected := propagation.MapCarrier{}
+			otel.GetTextMapPropagator().Inject(ctx, injected)
+			assert.Equal(t, carrier["traceparent"], injected["traceparent"])
+		})
+	}
+}
@@ -0,0 +1,16 @@
+create table if not exists transactions
+(
+    id          serial primary key,
+    type        varchar(32)  not null,
+    sender_id   int references users (user_id),
+    receiver_id int references users (user_id),
+    sum         float        not null,
+    order_id    varchar(64)  not null default '',
+    service_id  varchar(64)  not null default '',
+    comment     varchar(255) not null default '',
+    source      varchar(64)  not null default '',
+    created_at  timestamp    not null default now()
+);
+
+create index if not exists transactions_sender_id_idx on transactions (sender_id);
+create index if not exists transactions_receiver_id_idx on transactions (receiver_id);
@@ -0,0 +1,2 @@
+alter table transactions
+    drop column if exists reversed_id;
\ No newline at end of file
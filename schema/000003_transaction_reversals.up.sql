@@ -0,0 +1,4 @@
+alter table transactions
+    add column if not exists reversed_id int references transactions (id);
+
+create index if not exists transactions_reversed_id_idx on transactions (reversed_id);
@@ -0,0 +1,2 @@
+alter table users
+    drop column if exists credit_limit;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- null - для юзера действует лимит по умолчанию из конфига
+alter table users
+    add column if not exists credit_limit float check (credit_limit >= 0);
@@ -0,0 +1,2 @@
+drop index if exists transactions_sender_id_type_created_at_idx;
+drop table user_limits
\ No newline at end of file
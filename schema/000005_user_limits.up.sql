@@ -0,0 +1,13 @@
+-- персональные лимиты юзера, null - действует лимит из конфига
+create table if not exists user_limits
+(
+    user_id             int primary key references users (user_id),
+    max_transaction_sum float check (max_transaction_sum >= 0),
+    daily_debit         float check (daily_debit >= 0),
+    monthly_debit       float check (monthly_debit >= 0),
+    daily_transfer      float check (daily_transfer >= 0),
+    monthly_transfer    float check (monthly_transfer >= 0),
+    hourly_transfers    int check (hourly_transfers >= 0)
+);
+
+create index if not exists transactions_sender_id_type_created_at_idx on transactions (sender_id, type, created_at);
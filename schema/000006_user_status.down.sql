@@ -0,0 +1,4 @@
+drop table user_status_changes;
+
+alter table users
+    drop column if exists status;
\ No newline at end of file
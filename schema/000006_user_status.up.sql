@@ -0,0 +1,15 @@
+-- active - обычный юзер, frozen - нельзя списывать и переводить, closed - нельзя ничего
+alter table users
+    add column if not exists status varchar(16) not null default 'active' check (status in ('active', 'frozen', 'closed'));
+
+-- история смены статусов с причиной
+create table if not exists user_status_changes
+(
+    id         serial primary key,
+    user_id    int          not null references users (user_id),
+    status     varchar(16)  not null,
+    reason     varchar(255) not null,
+    created_at timestamp    not null default now()
+);
+
+create index if not exists user_status_changes_user_id_idx on user_status_changes (user_id);
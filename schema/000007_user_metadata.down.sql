@@ -0,0 +1,7 @@
+drop trigger if exists users_updated_at on users;
+drop function if exists users_set_updated_at;
+
+alter table users
+    drop column if exists external_ref,
+    drop column if exists created_at,
+    drop column if exists updated_at;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- external_ref - идентификатор юзера во внешней системе, необязательный
+alter table users
+    add column if not exists external_ref varchar(64) not null default '',
+    add column if not exists created_at   timestamp   not null default now(),
+    add column if not exists updated_at   timestamp   not null default now();
+
+-- updated_at обновляется при любом изменении юзера (баланс, лимит, статус)
+create or replace function users_set_updated_at() returns trigger as
+$$
+begin
+    new.updated_at = now();
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger users_updated_at
+    before update
+    on users
+    for each row
+execute procedure users_set_updated_at();
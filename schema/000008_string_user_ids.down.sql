@@ -0,0 +1,26 @@
+-- откатится только если все id целые
+alter table transactions
+    drop constraint if exists transactions_sender_id_fkey,
+    drop constraint if exists transactions_receiver_id_fkey;
+alter table user_limits
+    drop constraint if exists user_limits_user_id_fkey;
+alter table user_status_changes
+    drop constraint if exists user_status_changes_user_id_fkey;
+
+alter table users
+    alter column user_id type int using user_id::int;
+alter table transactions
+    alter column sender_id type int using sender_id::int,
+    alter column receiver_id type int using receiver_id::int;
+alter table user_limits
+    alter column user_id type int using user_id::int;
+alter table user_status_changes
+    alter column user_id type int using user_id::int;
+
+alter table transactions
+    add constraint transactions_sender_id_fkey foreign key (sender_id) references users (user_id),
+    add constraint transactions_receiver_id_fkey foreign key (receiver_id) references users (user_id);
+alter table user_limits
+    add constraint user_limits_user_id_fkey foreign key (user_id) references users (user_id);
+alter table user_status_changes
+    add constraint user_status_changes_user_id_fkey foreign key (user_id) references users (user_id);
@@ -0,0 +1,26 @@
+-- user_id становится строкой (UUID или внешний id), старые целые id сохраняются как есть в текстовом виде
+alter table transactions
+    drop constraint if exists transactions_sender_id_fkey,
+    drop constraint if exists transactions_receiver_id_fkey;
+alter table user_limits
+    drop constraint if exists user_limits_user_id_fkey;
+alter table user_status_changes
+    drop constraint if exists user_status_changes_user_id_fkey;
+
+alter table users
+    alter column user_id type varchar(64) using user_id::text;
+alter table transactions
+    alter column sender_id type varchar(64) using sender_id::text,
+    alter column receiver_id type varchar(64) using receiver_id::text;
+alter table user_limits
+    alter column user_id type varchar(64) using user_id::text;
+alter table user_status_changes
+    alter column user_id type varchar(64) using user_id::text;
+
+alter table transactions
+    add constraint transactions_sender_id_fkey foreign key (sender_id) references users (user_id),
+    add constraint transactions_receiver_id_fkey foreign key (receiver_id) references users (user_id);
+alter table user_limits
+    add constraint user_limits_user_id_fkey foreign key (user_id) references users (user_id);
+alter table user_status_changes
+    add constraint user_status_changes_user_id_fkey foreign key (user_id) references users (user_id);
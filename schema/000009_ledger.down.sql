@@ -0,0 +1,6 @@
+drop trigger if exists postings_append_only on postings;
+drop trigger if exists transactions_append_only on transactions;
+drop function if exists forbid_ledger_change();
+drop table if exists postings;
+delete from transactions where type = 'opening_balance';
+drop function if exists postings_check_balanced();
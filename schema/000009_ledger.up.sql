@@ -0,0 +1,93 @@
+-- двойная запись: каждая операция из transactions - запись журнала, деньги по ней движутся проводками между счетами.
+-- Счет - кошелек юзера (account = 'user', user_id) или системный: cash_in - откуда приходят начисления,
+-- revenue - куда уходят списания, fees - комиссии. Проводки одной операции в сумме дают 0
+create table if not exists postings
+(
+    id             serial primary key,
+    transaction_id int         not null references transactions (id),
+    account        varchar(16) not null check (account in ('user', 'cash_in', 'revenue', 'fees')),
+    user_id        varchar(64) references users (user_id),
+    amount         float       not null, -- плюс - на счет, минус - со счета
+    created_at     timestamp   not null default now(),
+    check ((account = 'user') = (user_id is not null))
+);
+
+create index if not exists postings_transaction_id_idx on postings (transaction_id);
+create index if not exists postings_user_id_idx on postings (user_id);
+
+-- проводки для уже существующих операций: со стороны, где нет юзера, деньги приходят из cash_in или уходят в revenue
+insert into postings (transaction_id, account, user_id, amount, created_at)
+select id,
+       case when sender_id is not null then 'user' when type = 'add_funds' then 'cash_in' else 'revenue' end,
+       sender_id,
+       -sum,
+       created_at
+from transactions;
+insert into postings (transaction_id, account, user_id, amount, created_at)
+select id,
+       case when receiver_id is not null then 'user' when type = 'write_off_funds' then 'revenue' else 'cash_in' end,
+       receiver_id,
+       sum,
+       created_at
+from transactions;
+
+-- балансы, которые были до истории операций (000002), проводками не покрыты: на разницу между балансом юзера и суммой
+-- его проводок заводится операция opening_balance из cash_in на кошелек (или обратно, если разница отрицательная)
+with opening as (
+    select user_id, balance - coalesce((select sum(amount) from postings where postings.user_id = users.user_id), 0) as diff
+    from users
+),
+     opened as (
+         insert into transactions (type, sender_id, receiver_id, sum)
+             select 'opening_balance',
+                    case when diff < 0 then user_id end,
+                    case when diff > 0 then user_id end,
+                    abs(diff)
+             from opening
+             where abs(diff) > 0.005
+             returning id, sender_id, receiver_id, sum, created_at
+     )
+insert into postings (transaction_id, account, user_id, amount, created_at)
+select id, 'user', coalesce(receiver_id, sender_id), case when receiver_id is not null then sum else -sum end, created_at
+from opened
+union all
+select id, 'cash_in', null, case when receiver_id is not null then -sum else sum end, created_at
+from opened;
+
+-- проверка в конце транзакции, что проводки операции сходятся в 0 (с точностью до долей копейки, суммы во float)
+create or replace function postings_check_balanced() returns trigger as
+$$
+begin
+    if abs((select sum(amount) from postings where transaction_id = new.transaction_id)) > 0.005 then
+        raise exception 'postings of transaction % do not sum to zero', new.transaction_id;
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+
+create constraint trigger postings_balanced
+    after insert
+    on postings
+    deferrable initially deferred
+    for each row
+execute procedure postings_check_balanced();
+
+-- журнал только дополняется: исправление - новая операция (например, reversal), а не правка старой
+create or replace function forbid_ledger_change() returns trigger as
+$$
+begin
+    raise exception '% is append-only', tg_table_name;
+end;
+$$ language plpgsql;
+
+create trigger transactions_append_only
+    before update or delete
+    on transactions
+    for each row
+execute procedure forbid_ledger_change();
+
+create trigger postings_append_only
+    before update or delete
+    on postings
+    for each row
+execute procedure forbid_ledger_change();
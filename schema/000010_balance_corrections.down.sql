@@ -0,0 +1,2 @@
+drop trigger if exists balance_corrections_append_only on balance_corrections;
+drop table if exists balance_corrections;
@@ -0,0 +1,18 @@
+-- исправления сверки: источник правды - журнал, поэтому если кэш баланса в users.balance разошелся с проводками,
+-- исправляется кэш, а не журнал. Каждое исправление остается здесь, таблица только дополняется
+create table if not exists balance_corrections
+(
+    id             serial primary key,
+    user_id        varchar(64) not null references users (user_id),
+    balance        float       not null, -- users.balance до исправления
+    ledger_balance float       not null, -- сумма проводок по кошельку, users.balance после исправления
+    created_at     timestamp   not null default now()
+);
+
+create index if not exists balance_corrections_user_id_idx on balance_corrections (user_id);
+
+create trigger balance_corrections_append_only
+    before update or delete
+    on balance_corrections
+    for each row
+execute procedure forbid_ledger_change();
@@ -0,0 +1,3 @@
+drop trigger if exists audit_log_no_truncate on audit_log;
+drop trigger if exists audit_log_append_only on audit_log;
+drop table if exists audit_log;
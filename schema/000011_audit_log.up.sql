@@ -0,0 +1,31 @@
+-- журнал аудита: операции с деньгами и действия админов. Каждая запись хранит хэш предыдущей (prev_hash) и свой хэш
+-- от своих полей и prev_hash, поэтому правка или удаление записи в обход триггеров ломает цепочку и находится verify
+create table if not exists audit_log
+(
+    id             bigserial primary key,
+    created_at     timestamp    not null,
+    request_id     varchar(64)  not null default '',
+    actor          varchar(128) not null, -- кто выполнил действие: клиент api, cli или system
+    action         varchar(32)  not null, -- тип операции (add_funds, reversal, ...) или действие админа (set_status, ...)
+    user_ids       text[]       not null default '{}',
+    transaction_id int references transactions (id),
+    details        text         not null default '{}', -- json как есть, байт в байт: от него считается хэш
+    prev_hash      varchar(64)  not null default '', -- у первой записи пустой
+    hash           varchar(64)  not null unique
+);
+
+create index if not exists audit_log_actor_idx on audit_log (actor, created_at);
+create index if not exists audit_log_user_ids_idx on audit_log using gin (user_ids);
+create index if not exists audit_log_created_at_idx on audit_log (created_at);
+
+create trigger audit_log_append_only
+    before update or delete
+    on audit_log
+    for each row
+execute procedure forbid_ledger_change();
+
+create trigger audit_log_no_truncate
+    before truncate
+    on audit_log
+    for each statement
+execute procedure forbid_ledger_change();
@@ -0,0 +1,2 @@
+alter table transactions
+    alter column created_at type timestamp;
@@ -0,0 +1,5 @@
+-- created_at был timestamp без зоны: now() писал в него время в TimeZone сессии, а время из Go при сравнении с ним
+-- теряло зону, поэтому окна лимитов (сутки и месяц по UTC) сдвигались на смещение TimeZone сервера бд.
+-- Старые значения переводятся из TimeZone сессии, в которой они и были записаны
+alter table transactions
+    alter column created_at type timestamptz;
@@ -0,0 +1,12 @@
+-- голова цепочки журнала аудита: хэш последней записи в единственной строке. AppendAudit блокирует ее перед записью,
+-- и в repeatable read запись, начатая до коммита параллельной, падает с ошибкой сериализации, а не продолжает цепочку от
+-- устаревшего prev_hash. Advisory lock от этого не спасал: снимок транзакции брался до того, как lock был получен
+create table if not exists audit_head
+(
+    id   boolean primary key default true check (id), -- строка всегда одна
+    hash varchar(64) not null
+);
+
+insert into audit_head (hash)
+select coalesce((select hash from audit_log order by id desc limit 1), '')
+on conflict do nothing;